@@ -8,6 +8,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -26,12 +28,28 @@ import (
 type LoadAction func(context.Context, config.DesignAction) (host.Action, error)
 
 var ActionLoaders = map[string]LoadAction{
-	"init-nodes":   initNodesActionFunc,
-	"start-nodes":  startNodesActionFunc,
-	"custom-nodes": customNodesActionFunc,
-	"stop-nodes":   stopNodesActionFunc,
-	"kill":         killActionFunc,
-	"host-command": hostCommandActionFunc,
+	"init-nodes":        initNodesActionFunc,
+	"start-nodes":       startNodesActionFunc,
+	"custom-nodes":      customNodesActionFunc,
+	"stop-nodes":        stopNodesActionFunc,
+	"kill":              killActionFunc,
+	"host-command":      hostCommandActionFunc,
+	"wait":              waitActionFunc,
+	"exec-nodes":        execNodesActionFunc,
+	"http":              httpActionFunc,
+	"load":              loadActionFunc,
+	"stop-load":         stopLoadActionFunc,
+	"chaos":             chaosActionFunc,
+	"stop-chaos":        stopChaosActionFunc,
+	"consistency":       consistencyActionFunc,
+	"storage-fault":     storageFaultActionFunc,
+	"disk-fault":        diskFaultActionFunc,
+	"skew-clock":        skewClockActionFunc,
+	"snapshot-nodes":    snapshotNodesActionFunc,
+	"restore-nodes":     restoreNodesActionFunc,
+	"reset-nodes":       resetNodesActionFunc,
+	"add-nodes":         addNodesActionFunc,
+	"reconfigure-nodes": reconfigureNodesActionFunc,
 }
 
 var initNodesActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
@@ -128,23 +146,155 @@ var killActionFunc = func(ctx context.Context, design config.DesignAction) (host
 
 	var err error
 	if es, found := design.Extra["error"]; found {
-		err = errors.Errorf(es.(string))
+		err = KillError{Err: errors.Errorf(es.(string))}
 	}
 
 	return KillAction{exitChan: exitChan, err: err}, nil
 }
 
+var waitActionFunc = func(_ context.Context, design config.DesignAction) (host.Action, error) {
+	d, found, err := findDurationFromDesign(design, "duration")
+	switch {
+	case err != nil:
+		return nil, err
+	case found:
+	case len(design.Args) > 0:
+		i, err := time.ParseDuration(design.Args[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid duration")
+		}
+		d = i
+	default:
+		return nil, errors.Errorf("empty duration")
+	}
+
+	return WaitAction{duration: d}, nil
+}
+
+var execNodesActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	var nodes []string
+	switch i, err := findNodesFromDesign(design); {
+	case err != nil:
+		return nil, err
+	case len(i) < 1:
+		return nil, errors.Errorf("empty nodes")
+	default:
+		nodes = i
+	}
+
+	if len(design.Args) < 1 {
+		return nil, errors.Errorf("empty command")
+	}
+
+	timeout := defaultExecNodesTimeout
+	switch d, found, err := findDurationFromDesign(design, "timeout"); {
+	case err != nil:
+		return nil, err
+	case found:
+		timeout = d
+	}
+
+	exitCode, _, err := findIntFromDesign(design, "exit-code")
+	if err != nil {
+		return nil, err
+	}
+
+	register, _, err := findStringFromDesign(design, "register")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewExecNodesAction(ctx, nodes, design.Args, timeout, exitCode, register)
+}
+
 var hostCommandActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
-	return NewHostCommandAction(ctx, design.Args)
+	return NewHostCommandAction(ctx, design)
+}
+
+var httpActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	de, err := config.ParseDesignHTTP(design.Extra)
+	if err != nil {
+		return nil, err
+	}
+
+	register, _, err := findStringFromDesign(design, "register")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHTTPAction(ctx, de, register)
+}
+
+var loadActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	return NewStartLoadAction(ctx, design)
+}
+
+var stopLoadActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	var loads *host.Loads
+	if err := host.LoadLoadsContextValue(ctx, &loads); err != nil {
+		return nil, err
+	}
+
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
+		return nil, err
+	}
+
+	name, _, err := findStringFromDesign(design, "load")
+	if err != nil {
+		return nil, err
+	}
+
+	return StopLoadAction{loads: loads, lo: lo, name: name}, nil
+}
+
+var chaosActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	return NewChaosAction(ctx, design)
+}
+
+var stopChaosActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	var chaoses *Chaoses
+	if err := LoadChaosesContextValue(ctx, &chaoses); err != nil {
+		return nil, err
+	}
+
+	name, _, err := findStringFromDesign(design, "chaos")
+	if err != nil {
+		return nil, err
+	}
+
+	return StopChaosAction{chaoses: chaoses, name: name}, nil
+}
+
+var consistencyActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	var cdesign config.Design
+	if err := config.LoadDesignContextValue(ctx, &cdesign); err != nil {
+		return nil, err
+	}
+
+	de, err := config.ParseDesignConsistency(design.Extra, cdesign.Consistency)
+	if err != nil {
+		return nil, err
+	}
+
+	register, _, err := findStringFromDesign(design, "register")
+	if err != nil {
+		return nil, err
+	}
+
+	return NewConsistencyAction(ctx, de, register)
 }
 
 type BaseNodesAction struct {
 	*logging.Logging
-	name  string
-	nodes []*host.Node
-	lo    *host.LogSaver
-	vars  *config.Vars
-	args  []string
+	name   string
+	nodes  []*host.Node
+	lo     *host.LogSaver
+	vars   *config.Vars
+	args   []string
+	states *host.NodeStates
+	expect config.DesignExpect
+	exit   chan error
 }
 
 func NewBaseNodesAction(ctx context.Context, name string, aliases []string, args []string) (*BaseNodesAction, error) {
@@ -168,6 +318,15 @@ func NewBaseNodesAction(ctx context.Context, name string, aliases []string, args
 		return nil, err
 	}
 
+	var design config.Design
+	if err := config.LoadDesignContextValue(ctx, &design); err != nil {
+		return nil, err
+	}
+
+	// NOTE exit chan is only for the exit code expect
+	var exitChan chan error
+	_ = LoadExitChanContextValue(ctx, &exitChan)
+
 	nodes, err := filterNodes(hosts, aliases)
 	if err != nil {
 		return nil, err
@@ -177,11 +336,14 @@ func NewBaseNodesAction(ctx context.Context, name string, aliases []string, args
 		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
 			return c.Str("module", fmt.Sprintf("%s-action", name)).Strs("nodes", aliases)
 		}),
-		name:  name,
-		nodes: nodes,
-		lo:    lo,
-		vars:  vars,
-		args:  args,
+		name:   name,
+		nodes:  nodes,
+		lo:     lo,
+		vars:   vars,
+		args:   args,
+		states: hosts.NodeStates(),
+		expect: design.Expect,
+		exit:   exitChan,
 	}
 
 	_ = action.SetLogging(log)
@@ -233,11 +395,27 @@ func (ac *BaseNodesAction) createContainer(
 
 func (*BaseNodesAction) startContainer(ctx context.Context, node *host.Node, id string) error {
 	client := node.Host().DockerClient()
-	return client.ContainerStart(
+	if err := client.ContainerStart(
 		ctx,
 		id,
 		dockerTypes.ContainerStartOptions{},
-	)
+	); err != nil {
+		return err
+	}
+
+	// NOTE the ip of node is used by clock server to find the clock of node
+	r, err := client.ContainerInspect(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if r.NetworkSettings != nil {
+		if n, found := r.NetworkSettings.Networks[host.NetworkName(node.Host().TestName())]; found {
+			node.SetIP(n.IPAddress)
+		}
+	}
+
+	return nil
 }
 
 func (ac *BaseNodesAction) containerLogs(ctx context.Context, node *host.Node, id string) error {
@@ -307,29 +485,46 @@ func (*BaseNodesAction) waitContainer(
 	}
 }
 
-func (*BaseNodesAction) mainConfig(node *host.Node, commands []string, t string) *container.Config {
+// expectedExit stops contest when the exited node is expected by the exit
+// code expect; the clean exit, exit code 0 does not make any error, so it
+// can not be caught by stderr.
+func (ac *BaseNodesAction) expectedExit(alias string, exitCode int64) {
+	if ac.exit == nil || !ac.expect.MatchExitCode(alias, exitCode) {
+		return
+	}
+
+	go func() {
+		ac.exit <- host.NewNodeExitError(alias, exitCode)
+	}()
+}
+
+func (ac *BaseNodesAction) mainConfig(node *host.Node, commands []string, t string) *container.Config {
 	portSet := nat.PortSet{}
 	for source := range node.PortMap() {
 		portSet[source] = struct{}{}
 	}
 
 	return &container.Config{
-		Cmd:        commands,
-		WorkingDir: "/",
-		Tty:        false,
-		Image:      host.DefaultNodeImage,
-		Labels: map[string]string{
-			host.ContainerLabel:          host.ContainerLabelNode,
-			host.ContainerLabelNodeAlias: node.Alias(),
-			host.ContainerLabelNodeType:  t,
-		},
+		Cmd:          commands,
+		WorkingDir:   "/",
+		Tty:          false,
+		Image:        host.DefaultNodeImage,
+		Labels:       ac.containerLabels(node, t),
 		ExposedPorts: portSet,
 	}
 }
 
+func (*BaseNodesAction) containerLabels(node *host.Node, t string) map[string]string {
+	labels := host.ContainerLabels(node.Host().TestName(), host.ContainerLabelNode)
+	labels[host.ContainerLabelNodeAlias] = node.Alias()
+	labels[host.ContainerLabelNodeType] = t
+
+	return labels
+}
+
 func (*BaseNodesAction) hostConfig(node *host.Node) (*container.HostConfig, error) {
 	sharedDir := node.Host().BaseDir()
-	dataDir := filepath.Join(sharedDir, node.Alias())
+	dataDir := node.DataDir()
 	if _, err := os.Stat(dataDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(dataDir, 0o700); err != nil {
 			return nil, errors.Errorf("failed to create data directory, %q", dataDir)
@@ -369,8 +564,9 @@ func (*BaseNodesAction) hostConfig(node *host.Node) (*container.HostConfig, erro
 			},
 		},
 		PortBindings: node.PortMap(),
+		NetworkMode:  container.NetworkMode(host.NetworkName(node.Host().TestName())),
 		Links: []string{
-			node.Host().MongodbContainerID() + ":storage",
+			node.Host().MongodbContainerID(node.Alias()) + ":storage",
 		},
 	}, nil
 }
@@ -446,7 +642,7 @@ func (ac *StartNodesAction) run(ctx context.Context, node *host.Node, id string)
 			ctx,
 			node,
 			cmds,
-			host.NodeRunContainerName(node.Alias()),
+			host.NodeRunContainerName(node.Host().TestName(), node.Alias()),
 			"run",
 		)
 		if err != nil {
@@ -461,10 +657,14 @@ func (ac *StartNodesAction) run(ctx context.Context, node *host.Node, id string)
 		return err
 	}
 
+	run := ac.states.Set(node.Alias(), config.NodeStateRunning, ac.name)
+
 	go func() {
 		msg, err := ac.waitContainer(context.Background(), node, id, container.WaitConditionNotRunning)
 		if err != nil {
 			ac.Log().Error().Err(err).Msg("failed to wait container")
+		} else if ac.states.Exit(node.Alias(), run, msg.StatusCode, ac.name) {
+			ac.expectedExit(node.Alias(), msg.StatusCode)
 		}
 
 		if msg.Err != nil {
@@ -513,10 +713,28 @@ func (ac *StopNodesAction) Run(ctx context.Context) error {
 		if !found {
 			return nil
 		}
-		return node.Host().DockerClient().ContainerStop(ctx, id, nil)
+
+		if err := node.Host().DockerClient().ContainerStop(ctx, id, nil); err != nil {
+			return err
+		}
+
+		return ac.exited(ctx, node, id)
 	})
 }
 
+// exited sets the node state to exited with the exit code of stopped
+// container.
+func (ac *StopNodesAction) exited(ctx context.Context, node *host.Node, id string) error {
+	r, err := node.Host().DockerClient().ContainerInspect(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	_ = ac.states.Exit(node.Alias(), 0, int64(r.State.ExitCode), ac.name)
+
+	return nil
+}
+
 func (ac StopNodesAction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(ac.Map())
 }
@@ -556,7 +774,7 @@ func (ac *InitNodesAction) run(ctx context.Context, node *host.Node, id string)
 			ctx,
 			node,
 			host.DefaultContainerCmdNodeInit,
-			host.NodeInitContainerName(node.Alias()),
+			host.NodeInitContainerName(node.Host().TestName(), node.Alias()),
 			"init",
 		)
 		if err != nil {
@@ -569,6 +787,8 @@ func (ac *InitNodesAction) run(ctx context.Context, node *host.Node, id string)
 		return err
 	}
 
+	_ = ac.states.Set(node.Alias(), config.NodeStateInitializing, ac.name)
+
 	if err := ac.containerLogs(ctx, node, id); err != nil {
 		return err
 	}
@@ -580,8 +800,12 @@ func (ac *InitNodesAction) run(ctx context.Context, node *host.Node, id string)
 
 	if msg.Err != nil {
 		msg.Msg = "init node stopped with error"
+
+		_ = ac.states.Exit(node.Alias(), 0, msg.StatusCode, ac.name)
 	} else {
 		msg.Msg = "init node stopped without error"
+
+		_ = ac.states.Set(node.Alias(), config.NodeStateInitialized, ac.name)
 	}
 
 	e, err := host.NewNodeLogEntryWithInterface(node.Alias(), msg, msg.StatusCode != 0)
@@ -598,6 +822,19 @@ func (ac InitNodesAction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(ac.Map())
 }
 
+// KillError is the error from kill action.
+type KillError struct {
+	Err error
+}
+
+func (e KillError) Error() string {
+	return e.Err.Error()
+}
+
+func (e KillError) Unwrap() error {
+	return e.Err
+}
+
 type KillAction struct {
 	exitChan chan error
 	err      error
@@ -619,180 +856,837 @@ func (KillAction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]interface{}{"name": "stop"})
 }
 
-type CustomNodesAction struct {
+type WaitAction struct {
+	duration time.Duration
+}
+
+func (WaitAction) Name() string {
+	return "wait"
+}
+
+func (ac WaitAction) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(ac.duration):
+		return nil
+	}
+}
+
+func (ac WaitAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"name": ac.Name(), "duration": ac.duration.String()})
+}
+
+var defaultExecNodesTimeout = time.Second * 30
+
+// ExecNodesAction runs command inside the running node containers. The output
+// is saved as node log entry and can be registered.
+type ExecNodesAction struct {
 	*BaseNodesAction
+	timeout  time.Duration
+	exitCode int
+	register string
 }
 
-func NewCustomNodesAction(ctx context.Context, aliases []string, args []string) (*CustomNodesAction, error) {
-	b, err := NewBaseNodesAction(ctx, "custom-nodes", aliases, args)
+func NewExecNodesAction(
+	ctx context.Context,
+	aliases []string,
+	args []string,
+	timeout time.Duration,
+	exitCode int,
+	register string,
+) (*ExecNodesAction, error) {
+	b, err := NewBaseNodesAction(ctx, "exec-nodes", aliases, args)
 	if err != nil {
 		return nil, err
 	}
-	return &CustomNodesAction{
+
+	return &ExecNodesAction{
 		BaseNodesAction: b,
+		timeout:         timeout,
+		exitCode:        exitCode,
+		register:        register,
 	}, nil
 }
 
-func (ac *CustomNodesAction) Run(ctx context.Context) error {
-	ids, err := filterRunningContainers(ctx, ac.nodes, true)
+func (ac *ExecNodesAction) Run(ctx context.Context) error {
+	ids, err := filterRunningContainers(ctx, ac.nodes, false)
 	if err != nil {
 		return err
 	}
 
-	return host.RunWaitGroup(len(ac.nodes), func(i int) error {
-		id, found := ids[ac.nodes[i].Alias()]
-		if !found {
-			return nil
-		}
-
-		return ac.run(ctx, ac.nodes[i], id)
-	})
-}
-
-func (ac *CustomNodesAction) run(ctx context.Context, node *host.Node, id string) error {
 	args, err := ac.compileArgs()
 	if err != nil {
 		return err
 	}
 
-	cmds := host.CustomNodesActionContainerCmd(args)
-	if len(id) < 1 {
-		id, err = ac.createContainer(
-			ctx,
-			node,
-			cmds,
-			host.NodeCustomContainerName(node.Alias()),
-			"custom",
-		)
-		if err != nil {
-			return err
+	return host.RunWaitGroup(len(ac.nodes), func(i int) error {
+		node := ac.nodes[i]
+
+		id := ids[node.Alias()]
+		if len(id) < 1 {
+			return errors.Errorf("node, %q is not running", node.Alias())
 		}
-	}
 
-	ac.Log().Debug().Strs("commands", cmds).Msg("trying to run custom node")
+		return ac.run(ctx, node, id, args)
+	})
+}
 
-	if err = ac.startContainer(ctx, node, id); err != nil {
-		return err
-	}
+func (ac *ExecNodesAction) run(ctx context.Context, node *host.Node, id string, args []string) error {
+	nctx, cancel := context.WithTimeout(ctx, ac.timeout)
+	defer cancel()
 
-	if err = ac.containerLogs(ctx, node, id); err != nil {
-		return err
-	}
+	ac.Log().Debug().Str("node", node.Alias()).Strs("commands", args).Msg("trying to exec in node")
 
-	msg, err := ac.waitContainer(ctx, node, id, container.WaitConditionNotRunning)
+	stdout, stderr, exitCode, err := host.ContainerExec(nctx, node.Host().DockerClient(), id, args)
 	if err != nil {
-		ac.Log().Error().Err(err).Msg("failed to wait container")
+		return errors.Wrapf(err, "failed to exec in node, %q", node.Alias())
 	}
 
-	if msg.Err != nil {
-		msg.Msg = "custom node stopped with error"
-	} else {
-		msg.Msg = "custom node stopped without error"
+	result := map[string]interface{}{
+		"stdout":    strings.TrimSpace(string(stdout)),
+		"stderr":    strings.TrimSpace(string(stderr)),
+		"exit_code": exitCode,
 	}
 
-	if e, err := host.NewNodeLogEntryWithInterface(node.Alias(), msg, msg.StatusCode != 0); err != nil {
+	if e, err := host.NewNodeLogEntryWithInterface(node.Alias(), map[string]interface{}{
+		"m":         "exec node finished",
+		"command":   args,
+		"stdout":    result["stdout"],
+		"stderr":    result["stderr"],
+		"exit_code": exitCode,
+	}, false); err != nil {
 		ac.Log().Error().Err(err).Msg("failed to make log entry")
 	} else {
 		ac.lo.LogEntryChan() <- e
 	}
 
+	if len(ac.register) > 0 {
+		ac.vars.Set(fmt.Sprintf("Register.%s.%s", ac.register, node.Alias()), result)
+	}
+
+	if exitCode != ac.exitCode {
+		return errors.Errorf(
+			"exec in node, %q exited with unexpected code, %d; expected %d: %q",
+			node.Alias(), exitCode, ac.exitCode, result["stderr"],
+		)
+	}
+
 	return nil
 }
 
-func (ac CustomNodesAction) MarshalJSON() ([]byte, error) {
-	return json.Marshal(ac.Map())
+func (ac ExecNodesAction) MarshalJSON() ([]byte, error) {
+	m := ac.Map()
+	m["args"] = ac.args
+	m["timeout"] = ac.timeout.String()
+	m["exit_code"] = ac.exitCode
+
+	return json.Marshal(m)
 }
 
-type HostCommandAction struct {
+// HTTPAction requests to the http endpoint, like the API of node. The response
+// is saved as contest log entry and can be registered.
+type HTTPAction struct {
 	*logging.Logging
-	command string
-	vars    *config.Vars
-	local   host.Host
+	design   config.DesignHTTP
+	register string
+	vars     *config.Vars
+	lo       *host.LogSaver
 }
 
-func NewHostCommandAction(ctx context.Context, args []string) (host.Action, error) {
-	if len(args) < 1 {
-		return nil, errors.Errorf("empty command")
-	}
-
+func NewHTTPAction(ctx context.Context, design config.DesignHTTP, register string) (*HTTPAction, error) {
 	var log *logging.Logging
 	if err := config.LoadLogContextValue(ctx, &log); err != nil {
 		return nil, err
 	}
 
-	var hosts *host.Hosts
-	var local host.Host
-	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
-		return nil, err
-	} else if err := hosts.TraverseHosts(func(h host.Host) (bool, error) {
-		// NOTE at this time, only local host is allowed exec command
-		if i, ok := h.(*host.LocalHost); ok {
-			local = i
-
-			return false, nil
-		}
-
-		return true, nil
-	}); err != nil {
+	var vars *config.Vars
+	if err := config.LoadVarsContextValue(ctx, &vars); err != nil {
 		return nil, err
-	} else if local == nil {
-		return nil, errors.Errorf("local host not found for HostCommandAction")
 	}
 
-	var vars *config.Vars
-	if err := config.LoadVarsContextValue(ctx, &vars); err != nil {
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
 		return nil, err
 	}
 
-	action := &HostCommandAction{
+	ac := &HTTPAction{
 		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
-			a := args[0]
-			if len(a) > 20 {
-				a = a[:20]
-			}
-
-			return c.Str("module", "host-command-action").Str("command", a)
+			return c.Str("module", "http-action").Str("url", design.URL)
 		}),
-		command: args[0],
-		vars:    vars,
-		local:   local,
+		design:   design,
+		register: register,
+		vars:     vars,
+		lo:       lo,
 	}
 
-	_ = action.SetLogging(log)
-
-	return action, nil
-}
+	_ = ac.SetLogging(log)
 
-func (*HostCommandAction) Name() string {
-	return "host-command"
+	return ac, nil
 }
 
-func (ac *HostCommandAction) Map() map[string]interface{} {
-	return map[string]interface{}{
-		"name": ac.Name(),
-		"args": []string{ac.command},
-	}
+func (*HTTPAction) Name() string {
+	return "http"
 }
 
-func (ac *HostCommandAction) Run(ctx context.Context) error {
-	_, _ = fmt.Fprintf(os.Stderr, "> input command: \n%s\n", ac.command)
-
-	i, err := config.CompileTemplate(ac.command, ac.vars)
+func (ac *HTTPAction) Run(ctx context.Context) error {
+	res, err := host.HTTPRequest(ctx, ac.design, ac.vars)
 	if err != nil {
 		return err
 	}
 
-	compiled := string(i)
+	m := res.Map()
+	if len(ac.register) > 0 {
+		ac.vars.Set(fmt.Sprintf("Register.%s", ac.register), m)
+	}
 
-	ac.Log().Debug().Str("command", compiled).Msg("command compiled")
-	if ac.Log().GetLevel() <= zerolog.DebugLevel {
-		_, _ = fmt.Fprintf(os.Stderr, "< compiled command: \n%s\n", compiled)
+	if b, err := json.Marshal(map[string]interface{}{
+		"m":      "http request finished",
+		"method": ac.design.Method,
+		"url":    ac.design.URL,
+		"status": res.Status,
+		"body":   res.Body,
+	}); err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+	} else {
+		ac.lo.LogEntryChan() <- host.NewContestLogEntry(b, false)
 	}
 
+	if err := ac.design.CheckResponse(res.Status, res.Body); err != nil {
+		return errors.Wrapf(err, "unexpected http response from %s %q", ac.design.Method, ac.design.URL)
+	}
+
+	ac.Log().Debug().Int("status", res.Status).Msg("http request finished")
+
+	return nil
+}
+
+func (ac HTTPAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":    ac.Name(),
+		"method":  ac.design.Method,
+		"url":     ac.design.URL,
+		"timeout": ac.design.Timeout.String(),
+		"status":  ac.design.Status,
+	})
+}
+
+var defaultLoadName = "load"
+
+// StartLoadAction starts the load in background; the load runs the templated
+// command or the http request at the target rate or concurrency.
+type StartLoadAction struct {
+	*logging.Logging
+	name        string
+	rate        float64
+	concurrency int
+	duration    time.Duration
+	command     string
+	http        *config.DesignHTTP
+	vars        *config.Vars
+	local       host.Host
+	mg          *host.Mongodb
+	loads       *host.Loads
+}
+
+func NewStartLoadAction(ctx context.Context, design config.DesignAction) (*StartLoadAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	ac := &StartLoadAction{name: defaultLoadName}
+	if err := ac.loadOptions(design); err != nil {
+		return nil, err
+	}
+
+	if err := config.LoadVarsContextValue(ctx, &ac.vars); err != nil {
+		return nil, err
+	}
+
+	if err := host.LoadMongodbContextValue(ctx, &ac.mg); err != nil {
+		return nil, err
+	}
+
+	if err := host.LoadLoadsContextValue(ctx, &ac.loads); err != nil {
+		return nil, err
+	}
+
+	if ac.http == nil {
+		i, err := findLocalHost(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find host for StartLoadAction")
+		}
+		ac.local = i
+	}
+
+	ac.Logging = logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+		return c.Str("module", "load-action").Str("load", ac.name)
+	})
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (ac *StartLoadAction) loadOptions(design config.DesignAction) error {
+	switch s, found, err := findStringFromDesign(design, "load"); {
+	case err != nil:
+		return err
+	case found && len(s) > 0:
+		ac.name = s
+	}
+
+	rate, _, err := findFloatFromDesign(design, "rate")
+	if err != nil {
+		return err
+	}
+	ac.rate = rate
+
+	concurrency, _, err := findIntFromDesign(design, "concurrency")
+	if err != nil {
+		return err
+	}
+	ac.concurrency = concurrency
+
+	if ac.rate <= 0 && ac.concurrency < 1 {
+		return errors.Errorf("load needs rate or concurrency")
+	}
+
+	duration, _, err := findDurationFromDesign(design, "duration")
+	if err != nil {
+		return err
+	}
+	ac.duration = duration
+
+	i, found := design.Extra["http"]
+	switch {
+	case found && len(design.Args) > 0:
+		return errors.Errorf("load can not have both args and http")
+	case found:
+		m, ok := i.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("http is not map type, %T", i)
+		}
+
+		de, err := config.ParseDesignHTTP(m)
+		if err != nil {
+			return err
+		}
+		ac.http = &de
+	case len(design.Args) < 1:
+		return errors.Errorf("load needs args or http")
+	default:
+		ac.command = design.Args[0]
+	}
+
+	return nil
+}
+
+func (*StartLoadAction) Name() string {
+	return "load"
+}
+
+func (ac *StartLoadAction) Run(ctx context.Context) error {
+	f := ac.commandRequest
+	if ac.http != nil {
+		f = ac.httpRequest
+	}
+
+	lo, err := host.NewLoad(ac.name, ac.rate, ac.concurrency, ac.duration, f, ac.mg)
+	if err != nil {
+		return err
+	}
+	_ = lo.SetLogging(ac.Logging)
+
+	if err := ac.loads.Add(lo); err != nil {
+		return err
+	}
+
+	return lo.Start(ctx)
+}
+
+func (ac *StartLoadAction) commandRequest(ctx context.Context) (map[string]interface{}, error) {
+	i, err := config.CompileTemplate(ac.command, ac.vars)
+	if err != nil {
+		return nil, err
+	}
+
+	var exitCode int
+	_, stderr, err := ac.local.ShellExec(ctx, "/bin/sh", []string{"-c", string(i)})
+	if err != nil {
+		var exitError *exec.ExitError
+		if !errors.As(err, &exitError) {
+			return nil, err
+		}
+
+		exitCode = exitError.ExitCode()
+	}
+
+	if exitCode != 0 {
+		b, _ := ioutil.ReadAll(stderr)
+
+		return map[string]interface{}{"exit_code": exitCode}, errors.Errorf(
+			"command exited with %d: %q", exitCode, strings.TrimSpace(string(b)))
+	}
+
+	return map[string]interface{}{"exit_code": exitCode}, nil
+}
+
+func (ac *StartLoadAction) httpRequest(ctx context.Context) (map[string]interface{}, error) {
+	res, err := host.HTTPRequest(ctx, *ac.http, ac.vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"status": res.Status}, ac.http.CheckResponse(res.Status, res.Body)
+}
+
+func (ac StartLoadAction) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"name":        ac.Name(),
+		"load":        ac.name,
+		"rate":        ac.rate,
+		"concurrency": ac.concurrency,
+		"duration":    ac.duration.String(),
+	}
+
+	if ac.http != nil {
+		m["http"] = fmt.Sprintf("%s %s", ac.http.Method, ac.http.URL)
+	} else {
+		m["args"] = []string{ac.command}
+	}
+
+	return json.Marshal(m)
+}
+
+// StopLoadAction stops the load by name; without name, all the loads are
+// stopped.
+type StopLoadAction struct {
+	loads *host.Loads
+	lo    *host.LogSaver
+	name  string
+}
+
+func (StopLoadAction) Name() string {
+	return "stop-load"
+}
+
+func (ac StopLoadAction) Run(context.Context) error {
+	var names []string
+	if len(ac.name) > 0 {
+		names = []string{ac.name}
+	}
+
+	summaries, err := ac.loads.Stop(names...)
+	if err != nil {
+		return err
+	}
+
+	for i := range summaries {
+		saveLoadSummary(ac.lo, "load stopped", summaries[i])
+	}
+
+	return nil
+}
+
+func (ac StopLoadAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"name": ac.Name(), "load": ac.name})
+}
+
+// ConsistencyAction compares the records of node storages; the first
+// divergence is returned as error.
+type ConsistencyAction struct {
+	*logging.Logging
+	design   config.DesignConsistency
+	register string
+	hosts    *host.Hosts
+	vars     *config.Vars
+	lo       *host.LogSaver
+}
+
+func NewConsistencyAction(
+	ctx context.Context, design config.DesignConsistency, register string,
+) (*ConsistencyAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	ac := &ConsistencyAction{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "consistency-action")
+		}),
+		design:   design,
+		register: register,
+	}
+
+	if err := host.LoadHostsContextValue(ctx, &ac.hosts); err != nil {
+		return nil, err
+	}
+
+	if err := config.LoadVarsContextValue(ctx, &ac.vars); err != nil {
+		return nil, err
+	}
+
+	if err := host.LoadLogSaverContextValue(ctx, &ac.lo); err != nil {
+		return nil, err
+	}
+
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (*ConsistencyAction) Name() string {
+	return "consistency"
+}
+
+func (ac *ConsistencyAction) Run(ctx context.Context) error {
+	// NOTE the nodes are found at run time; the nodes can be added by add-nodes.
+	nodes, err := host.ConsistencyNodes(ac.hosts, ac.design)
+	if err != nil {
+		return err
+	}
+
+	result, err := checkConsistency(ctx, ac.design, nodes, ac.hosts, ac.vars, ac.lo)
+
+	if len(ac.register) > 0 {
+		ac.vars.Set(fmt.Sprintf("Register.%s", ac.register), result.Map())
+	}
+
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("consistency failed")
+
+		return err
+	}
+
+	ac.Log().Debug().Interface("result", result).Msg("consistency checked")
+
+	return nil
+}
+
+func (ac ConsistencyAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":    ac.Name(),
+		"nodes":   ac.design.Nodes,
+		"storage": ac.design.Storage,
+		"col":     ac.design.Col,
+		"fields":  ac.design.Fields,
+	})
+}
+
+// checkConsistency checks consistency and saves the result as contest log
+// entry.
+func checkConsistency(
+	ctx context.Context,
+	design config.DesignConsistency,
+	nodes []string,
+	hosts *host.Hosts,
+	vars *config.Vars,
+	lo *host.LogSaver,
+) (host.ConsistencyResult, error) {
+	getStorage, closeStorages := host.NewStorageGetter()
+	defer closeStorages()
+
+	result, err := host.CheckConsistency(ctx, design, nodes, hosts, vars, getStorage)
+
+	m := result.Map()
+	m["m"] = "consistency checked"
+	m["nodes"] = nodes
+
+	var ce host.ConsistencyError
+	if errors.As(err, &ce) {
+		m["diverged"] = map[string]interface{}{"height": ce.Height, "field": ce.Field, "values": ce.Values}
+	}
+
+	if err != nil {
+		m["error"] = err.Error()
+	}
+
+	if b, e := json.Marshal(m); e == nil {
+		lo.LogEntryChan() <- host.NewContestLogEntry(b, err != nil)
+	}
+
+	return result, err
+}
+
+type CustomNodesAction struct {
+	*BaseNodesAction
+}
+
+func NewCustomNodesAction(ctx context.Context, aliases []string, args []string) (*CustomNodesAction, error) {
+	b, err := NewBaseNodesAction(ctx, "custom-nodes", aliases, args)
+	if err != nil {
+		return nil, err
+	}
+	return &CustomNodesAction{
+		BaseNodesAction: b,
+	}, nil
+}
+
+func (ac *CustomNodesAction) Run(ctx context.Context) error {
+	ids, err := filterRunningContainers(ctx, ac.nodes, true)
+	if err != nil {
+		return err
+	}
+
+	return host.RunWaitGroup(len(ac.nodes), func(i int) error {
+		id, found := ids[ac.nodes[i].Alias()]
+		if !found {
+			return nil
+		}
+
+		return ac.run(ctx, ac.nodes[i], id)
+	})
+}
+
+func (ac *CustomNodesAction) run(ctx context.Context, node *host.Node, id string) error {
+	args, err := ac.compileArgs()
+	if err != nil {
+		return err
+	}
+
+	cmds := host.CustomNodesActionContainerCmd(args)
+	if len(id) < 1 {
+		id, err = ac.createContainer(
+			ctx,
+			node,
+			cmds,
+			host.NodeCustomContainerName(node.Host().TestName(), node.Alias()),
+			"custom",
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	ac.Log().Debug().Strs("commands", cmds).Msg("trying to run custom node")
+
+	if err = ac.startContainer(ctx, node, id); err != nil {
+		return err
+	}
+
+	if err = ac.containerLogs(ctx, node, id); err != nil {
+		return err
+	}
+
+	msg, err := ac.waitContainer(ctx, node, id, container.WaitConditionNotRunning)
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("failed to wait container")
+	}
+
+	if msg.Err != nil {
+		msg.Msg = "custom node stopped with error"
+	} else {
+		msg.Msg = "custom node stopped without error"
+	}
+
+	if e, err := host.NewNodeLogEntryWithInterface(node.Alias(), msg, msg.StatusCode != 0); err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+	} else {
+		ac.lo.LogEntryChan() <- e
+	}
+
+	return nil
+}
+
+func (ac CustomNodesAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ac.Map())
+}
+
+var defaultHostCommandTimeout = time.Second * 30
+
+// HostCommandAction runs shell command in local host. The result of command is
+// saved as contest log entry.
+type HostCommandAction struct {
+	*logging.Logging
+	command        string
+	vars           *config.Vars
+	local          host.Host
+	lo             *host.LogSaver
+	timeout        time.Duration
+	background     bool
+	expectExitCode int
+	expectStdout   *regexp.Regexp
+	register       string
+}
+
+func NewHostCommandAction(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	args := design.Args
+	if len(args) < 1 {
+		return nil, errors.Errorf("empty command")
+	}
+
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	local, err := findLocalHost(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find host for HostCommandAction")
+	}
+
+	var vars *config.Vars
+	if err := config.LoadVarsContextValue(ctx, &vars); err != nil {
+		return nil, err
+	}
+
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
+		return nil, err
+	}
+
+	action := &HostCommandAction{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			a := args[0]
+			if len(a) > 20 {
+				a = a[:20]
+			}
+
+			return c.Str("module", "host-command-action").Str("command", a)
+		}),
+		command: args[0],
+		vars:    vars,
+		local:   local,
+		lo:      lo,
+	}
+
+	if err := action.loadOptions(design); err != nil {
+		return nil, err
+	}
+
+	_ = action.SetLogging(log)
+
+	return action, nil
+}
+
+func (ac *HostCommandAction) loadOptions(design config.DesignAction) error {
+	switch d, found, err := findDurationFromDesign(design, "timeout"); {
+	case err != nil:
+		return err
+	case found:
+		ac.timeout = d
+	}
+
+	if i, found := design.Extra["background"]; found {
+		b, ok := i.(bool)
+		if !ok {
+			return errors.Errorf("background is not bool type, %T", i)
+		}
+		ac.background = b
+	}
+
+	if ac.timeout < 1 && !ac.background {
+		// NOTE background command runs until contest stops without timeout
+		ac.timeout = defaultHostCommandTimeout
+	}
+
+	register, _, err := findStringFromDesign(design, "register")
+	if err != nil {
+		return err
+	}
+	ac.register = register
+
+	i, found := design.Extra["expect"]
+	switch {
+	case !found:
+		return nil
+	case ac.background:
+		// NOTE background command is not waited, so the result can not be
+		// expected
+		return errors.Errorf("expect can not be used with background")
+	}
+
+	expect, ok := i.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("expect is not map type, %T", i)
+	}
+
+	ed := config.DesignAction{Extra: expect}
+
+	code, _, err := findIntFromDesign(ed, "exit-code")
+	if err != nil {
+		return err
+	}
+	ac.expectExitCode = code
+
+	switch s, found, err := findStringFromDesign(ed, "stdout"); {
+	case err != nil:
+		return err
+	case found:
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return errors.Wrap(err, "invalid expect stdout")
+		}
+		ac.expectStdout = re
+	}
+
+	return nil
+}
+
+func (*HostCommandAction) Name() string {
+	return "host-command"
+}
+
+func (ac *HostCommandAction) Map() map[string]interface{} {
+	m := map[string]interface{}{
+		"name":       ac.Name(),
+		"args":       []string{ac.command},
+		"timeout":    ac.timeout.String(),
+		"background": ac.background,
+		"exit_code":  ac.expectExitCode,
+	}
+
+	if ac.expectStdout != nil {
+		m["stdout"] = ac.expectStdout.String()
+	}
+
+	return m
+}
+
+func (ac *HostCommandAction) Run(ctx context.Context) error {
+	_, _ = fmt.Fprintf(os.Stderr, "> input command: \n%s\n", ac.command)
+
+	i, err := config.CompileTemplate(ac.command, ac.vars)
+	if err != nil {
+		return err
+	}
+
+	compiled := string(i)
+
+	ac.Log().Debug().Str("command", compiled).Msg("command compiled")
+	if ac.Log().GetLevel() <= zerolog.DebugLevel {
+		_, _ = fmt.Fprintf(os.Stderr, "< compiled command: \n%s\n", compiled)
+	}
+
+	if !ac.background {
+		return ac.run(ctx, compiled)
+	}
+
+	ac.Log().Debug().Msg("running command in background")
+
+	go func() {
+		if err := ac.run(ctx, compiled); err != nil {
+			ac.Log().Error().Err(err).Msg("background command failed")
+
+			ac.saveBackgroundError(compiled, err)
+		}
+	}()
+
+	return nil
+}
+
+func (ac *HostCommandAction) run(ctx context.Context, compiled string) error {
 	ac.Log().Debug().Msg("running command")
 
-	nctx, cancel := context.WithTimeout(ctx, time.Second*30)
-	defer cancel()
+	nctx := ctx
+	if ac.timeout > 0 {
+		i, cancel := context.WithTimeout(ctx, ac.timeout)
+		defer cancel()
+
+		nctx = i
+	}
 
 	stdout, stderr, err := ac.local.ShellExec(nctx, "/bin/sh", []string{"-c", compiled})
 	stdoutOut, _ := ioutil.ReadAll(stdout)
@@ -800,17 +1694,32 @@ func (ac *HostCommandAction) Run(ctx context.Context) error {
 	_, _ = fmt.Fprintf(os.Stderr, "= stdout: \n%s\n", string(stdoutOut))
 	_, _ = fmt.Fprintf(os.Stderr, "= stderr: \n%s\n", string(stderrOut))
 
+	var exitCode int
 	if err != nil {
-		l := ac.Log().Error().Err(err).Str("stderr", string(stderrOut))
-
 		var exitError *exec.ExitError
-		if errors.As(err, &exitError) {
-			l = l.Int("exit_code", exitError.ExitCode())
+		if !errors.As(err, &exitError) {
+			ac.Log().Error().Err(err).Str("stderr", string(stderrOut)).Msg("failed to run command")
+
+			return errors.Wrapf(err, "failed to run command, %q", string(stderrOut))
 		}
 
-		l.Msg("failed to run command")
+		exitCode = exitError.ExitCode()
+	}
+
+	ac.saveResult(compiled, stdoutOut, stderrOut, exitCode)
 
-		return errors.Wrapf(err, "failed to run command, %q", string(stderrOut))
+	if exitCode != ac.expectExitCode {
+		ac.Log().Error().Err(err).Str("stderr", string(stderrOut)).Int("exit_code", exitCode).
+			Msg("failed to run command")
+
+		return errors.Errorf(
+			"failed to run command, unexpected exit code, %d; expected %d: %q",
+			exitCode, ac.expectExitCode, string(stderrOut),
+		)
+	}
+
+	if ac.expectStdout != nil && !ac.expectStdout.Match(stdoutOut) {
+		return errors.Errorf("stdout of command does not match with %q", ac.expectStdout.String())
 	}
 
 	ac.Log().Debug().Msg("command finished")
@@ -818,6 +1727,53 @@ func (ac *HostCommandAction) Run(ctx context.Context) error {
 	return nil
 }
 
+func (ac *HostCommandAction) saveResult(compiled string, stdout, stderr []byte, exitCode int) {
+	so := strings.TrimSpace(string(stdout))
+
+	if len(ac.register) > 0 {
+		var v interface{} = so
+
+		var j interface{}
+		if err := json.Unmarshal([]byte(so), &j); err == nil {
+			v = j
+		}
+
+		ac.vars.Set(fmt.Sprintf("Register.%s", ac.register), v)
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"m":         "host command finished",
+		"command":   compiled,
+		"stdout":    so,
+		"stderr":    strings.TrimSpace(string(stderr)),
+		"exit_code": exitCode,
+	})
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	ac.lo.LogEntryChan() <- host.NewContestLogEntry(b, false)
+}
+
+// saveBackgroundError saves the failure of background command as error log
+// entry; the failure of background command does not stop contest.
+func (ac *HostCommandAction) saveBackgroundError(compiled string, runErr error) {
+	b, err := json.Marshal(map[string]interface{}{
+		"m":       "background host command failed",
+		"command": compiled,
+		"error":   runErr.Error(),
+	})
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	ac.lo.LogEntryChan() <- host.NewContestLogEntry(b, true)
+}
+
 func (ac HostCommandAction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(ac.Map())
 }
@@ -844,3 +1800,75 @@ func findNodesFromDesign(design config.DesignAction) ([]string, error) {
 
 	return nodes, nil
 }
+
+func findStringFromDesign(design config.DesignAction, key string) (string, bool, error) {
+	i, found := design.Extra[key]
+	if !found {
+		return "", false, nil
+	}
+
+	s, ok := i.(string)
+	if !ok {
+		return "", true, errors.Errorf("%s is not string type, %T", key, i)
+	}
+
+	return strings.TrimSpace(s), true, nil
+}
+
+func findIntFromDesign(design config.DesignAction, key string) (int, bool, error) {
+	i, found := design.Extra[key]
+	if !found {
+		return 0, false, nil
+	}
+
+	n, ok := i.(int)
+	if !ok {
+		return 0, true, errors.Errorf("%s is not int type, %T", key, i)
+	}
+
+	return n, true, nil
+}
+
+func findFloatFromDesign(design config.DesignAction, key string) (float64, bool, error) {
+	i, found := design.Extra[key]
+	if !found {
+		return 0, false, nil
+	}
+
+	switch t := i.(type) {
+	case int:
+		return float64(t), true, nil
+	case float64:
+		return t, true, nil
+	default:
+		return 0, true, errors.Errorf("%s is not number type, %T", key, i)
+	}
+}
+
+func findBoolFromDesign(design config.DesignAction, key string) (bool, bool, error) {
+	i, found := design.Extra[key]
+	if !found {
+		return false, false, nil
+	}
+
+	b, ok := i.(bool)
+	if !ok {
+		return false, true, errors.Errorf("%s is not bool type, %T", key, i)
+	}
+
+	return b, true, nil
+}
+
+func findDurationFromDesign(design config.DesignAction, key string) (time.Duration, bool, error) {
+	s, found, err := findStringFromDesign(design, key)
+	if err != nil || !found {
+		return 0, found, err
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, true, errors.Wrapf(err, "invalid %s", key)
+	}
+
+	return d, true, nil
+}
@@ -0,0 +1,67 @@
+package cmds
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+)
+
+type testHostCommandAction struct {
+	suite.Suite
+}
+
+func (t *testHostCommandAction) TestLoadOptions() {
+	cases := []struct {
+		name       string
+		extra      map[string]interface{}
+		background bool
+		timeout    time.Duration
+		exitCode   int
+		err        string
+	}{
+		{name: "default", timeout: defaultHostCommandTimeout},
+		{
+			name:     "expect",
+			extra:    map[string]interface{}{"expect": map[string]interface{}{"exit-code": 3}},
+			timeout:  defaultHostCommandTimeout,
+			exitCode: 3,
+		},
+		{name: "background", extra: map[string]interface{}{"background": true}, background: true},
+		{
+			name: "background with expect",
+			extra: map[string]interface{}{
+				"background": true,
+				"expect":     map[string]interface{}{"exit-code": 3},
+			},
+			err: "expect can not be used with background",
+		},
+		{name: "not bool background", extra: map[string]interface{}{"background": "true"}, err: "not bool type"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			ac := &HostCommandAction{}
+
+			err := ac.loadOptions(config.DesignAction{Name: "host-command", Extra: c.extra})
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.background, ac.background)
+			t.Equal(c.timeout, ac.timeout)
+			t.Equal(c.exitCode, ac.expectExitCode)
+		})
+	}
+}
+
+func TestHostCommandAction(t *testing.T) {
+	suite.Run(t, new(testHostCommandAction))
+}
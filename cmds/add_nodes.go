@@ -0,0 +1,345 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+var addNodesActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	configs, err := findNodeConfigFromDesign(design)
+	if err != nil {
+		return nil, err
+	}
+
+	ac, err := NewAddNodesAction(ctx, configs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ac.loadOptions(design); err != nil {
+		return nil, err
+	}
+
+	return ac, nil
+}
+
+// AddNodesAction adds new nodes at runtime. The node config is compiled with
+// the current vars, including registers, and the added nodes can be started
+// like the other nodes. The nodes config of the existing nodes is regenerated
+// with the added nodes by reconfigure-nodes unless "nodes-config" is false;
+// the running nodes load it when they are restarted, or with "restart", they
+// are restarted.
+type AddNodesAction struct {
+	*logging.Logging
+	ctx         context.Context
+	configs     map[string]string
+	aliases     []string
+	nodesConfig bool
+	restart     bool
+	args        []string
+	lo          *host.LogSaver
+	vars        *config.Vars
+}
+
+func NewAddNodesAction(ctx context.Context, configs map[string]string) (*AddNodesAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return nil, err
+	}
+
+	ac := &AddNodesAction{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "add-nodes-action")
+		}),
+		ctx:         ctx,
+		configs:     configs,
+		nodesConfig: true,
+	}
+
+	for alias := range configs {
+		if hosts.NodeExists(alias) {
+			return nil, errors.Errorf("node, %q already exists", alias)
+		}
+
+		ac.aliases = append(ac.aliases, alias)
+	}
+	sort.Strings(ac.aliases)
+
+	if len(ac.aliases) < 1 {
+		return nil, errors.Errorf("empty node-config for add-nodes")
+	}
+
+	if err := host.LoadLogSaverContextValue(ctx, &ac.lo); err != nil {
+		return nil, err
+	}
+
+	if err := config.LoadVarsContextValue(ctx, &ac.vars); err != nil {
+		return nil, err
+	}
+
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (ac *AddNodesAction) loadOptions(design config.DesignAction) error {
+	switch b, found, err := findBoolFromDesign(design, "nodes-config"); {
+	case err != nil:
+		return err
+	case found:
+		ac.nodesConfig = b
+	}
+
+	switch b, found, err := findBoolFromDesign(design, "restart"); {
+	case err != nil:
+		return err
+	case found:
+		ac.restart = b
+	}
+
+	if ac.restart && !ac.nodesConfig {
+		return errors.Errorf("restart can be used only with nodes-config")
+	}
+
+	ac.args = design.Args
+
+	return nil
+}
+
+func (*AddNodesAction) Name() string {
+	return "add-nodes"
+}
+
+func (ac *AddNodesAction) Run(ctx context.Context) error {
+	var design config.Design
+	if err := config.LoadDesignContextValue(ac.ctx, &design); err != nil {
+		return err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ac.ctx, &hosts); err != nil {
+		return err
+	}
+
+	h, err := findLocalHost(ac.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, alias := range ac.aliases {
+		if err := ac.add(h, design, alias); err != nil {
+			return errors.Wrapf(err, "failed to add node, %q", alias)
+		}
+
+		hosts.NodeStates().Add(alias)
+	}
+
+	if !ac.nodesConfig {
+		return nil
+	}
+
+	return ac.reconfigureNodes(ctx)
+}
+
+// reconfigureNodes regenerates the nodes config of all the nodes; the config
+// file of the existing nodes is rewritten with the added nodes.
+func (ac *AddNodesAction) reconfigureNodes(ctx context.Context) error {
+	a, err := NewReconfigureNodesAction(ac.ctx, config.DesignAction{
+		Name: "reconfigure-nodes",
+		Args: ac.args,
+		Extra: map[string]interface{}{
+			"nodes-config": true,
+			"restart":      ac.restart,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(a.Run(ctx), "failed to reconfigure nodes")
+}
+
+func (ac *AddNodesAction) add(h host.Host, design config.Design, alias string) error {
+	node, err := h.AddNode(alias, ac.configs[alias], ac.vars)
+	if err != nil {
+		return err
+	}
+
+	if err := ac.lo.AddNode(alias); err != nil {
+		return err
+	} else if err := createNodeLogFile(node.LogFile()); err != nil {
+		return err
+	}
+
+	ac.vars.Set(fmt.Sprintf("Design.Node.%s", alias), node.ConfigMap())
+
+	shared := map[string]interface{}{}
+	for k, n := range h.Nodes() {
+		if i, found := n.Shared()["nodes-config"]; found {
+			shared[k] = i
+		}
+	}
+
+	nodesConfig, err := renderNodesConfig(design.NodesConfig, ac.vars, alias, shared)
+	if err != nil {
+		return errors.Wrap(err, "failed to generate nodes config")
+	}
+
+	if err := node.SaveConfig(nodesConfig); err != nil {
+		return err
+	}
+
+	ac.Log().Debug().Str("node", alias).Msg("node added")
+
+	if e, err := host.NewNodeLogEntryWithInterface(alias, map[string]interface{}{
+		"m":    "node added",
+		"host": h.Host(),
+	}, false); err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+	} else {
+		ac.lo.LogEntryChan() <- e
+	}
+
+	return nil
+}
+
+func (ac *AddNodesAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":         ac.Name(),
+		"nodes":        ac.aliases,
+		"nodes-config": ac.nodesConfig,
+		"restart":      ac.restart,
+	})
+}
+
+func findNodeConfigFromDesign(design config.DesignAction) (map[string]string, error) {
+	i, found := design.Extra["node-config"]
+	if !found {
+		return nil, errors.Errorf("empty node-config")
+	}
+
+	m, ok := i.(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("node-config is not map type, %T", i)
+	}
+
+	configs := map[string]string{}
+	for alias := range m {
+		switch t := m[alias].(type) {
+		case nil:
+			configs[alias] = ""
+		case string:
+			configs[alias] = t
+		default:
+			return nil, errors.Errorf("node config should be string, not %T", t)
+		}
+	}
+
+	return configs, nil
+}
+
+// lazyAction loads the action when it runs; the nodes added by add-nodes do
+// not exist when the sequences are parsed.
+type lazyAction struct {
+	ctx    context.Context
+	design config.DesignAction
+}
+
+func (ac lazyAction) Name() string {
+	return ac.design.Name
+}
+
+func (ac lazyAction) Run(ctx context.Context) error {
+	action, err := loadSequenceAction(ac.ctx, ac.design)
+	if err != nil {
+		return err
+	}
+
+	return action.Run(ctx)
+}
+
+func (ac lazyAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":  ac.design.Name,
+		"extra": ac.design.Extra,
+		"lazy":  true,
+	})
+}
+
+// isLazyAction checks whether the action has the nodes, which are added by
+// add-nodes. The added nodes are collected from the sequences once before
+// parsing them.
+func isLazyAction(ctx context.Context, design config.DesignAction) (bool, error) {
+	if design.Name == "add-nodes" {
+		return false, nil
+	}
+
+	aliases, err := findNodesFromDesign(design)
+	if err != nil || len(aliases) < 1 {
+		return false, err
+	}
+
+	var added map[string]struct{}
+	switch err := LoadAddedNodesContextValue(ctx, &added); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	for i := range aliases {
+		if _, found := added[aliases[i]]; found {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func addedNodesFromSequences(designs []config.DesignSequence, added map[string]struct{}) error {
+	for i := range designs {
+		de := designs[i]
+
+		var subs [][]config.DesignSequence
+		switch {
+		case de.Repeat != nil:
+			subs = append(subs, de.Repeat.Sequences)
+		case de.Until != nil:
+			subs = append(subs, de.Until.Sequences)
+		case de.If != nil:
+			subs = append(subs, de.If.Then, de.If.Else)
+		case de.Action.Name == "add-nodes":
+			configs, err := findNodeConfigFromDesign(de.Action)
+			if err != nil {
+				return err
+			}
+
+			for alias := range configs {
+				added[alias] = struct{}{}
+			}
+		}
+
+		for j := range subs {
+			if err := addedNodesFromSequences(subs[j], added); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package cmds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+)
+
+type testAddNodes struct {
+	suite.Suite
+}
+
+func (t *testAddNodes) TestLoadOptions() {
+	cases := []struct {
+		name        string
+		extra       map[string]interface{}
+		nodesConfig bool
+		restart     bool
+		err         string
+	}{
+		{name: "default", nodesConfig: true},
+		{name: "restart", extra: map[string]interface{}{"restart": true}, nodesConfig: true, restart: true},
+		{name: "without nodes-config", extra: map[string]interface{}{"nodes-config": false}},
+		{
+			name:  "restart without nodes-config",
+			extra: map[string]interface{}{"nodes-config": false, "restart": true},
+			err:   "restart can be used only with nodes-config",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			ac := &AddNodesAction{nodesConfig: true}
+
+			err := ac.loadOptions(config.DesignAction{Name: "add-nodes", Extra: c.extra})
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.nodesConfig, ac.nodesConfig)
+			t.Equal(c.restart, ac.restart)
+		})
+	}
+}
+
+func (t *testAddNodes) TestIsLazyAction() {
+	addNodes := config.DesignSequence{Action: config.DesignAction{
+		Name:  "add-nodes",
+		Extra: map[string]interface{}{"node-config": map[string]interface{}{"no3": nil}},
+	}}
+
+	added := map[string]struct{}{}
+	t.NoError(addedNodesFromSequences([]config.DesignSequence{
+		{Repeat: &config.DesignRepeat{Count: 1, Sequences: []config.DesignSequence{addNodes}}},
+	}, added))
+	t.Equal(map[string]struct{}{"no3": {}}, added)
+
+	ctx := context.WithValue(context.Background(), ContextValueAddedNodes, added)
+
+	cases := []struct {
+		name     string
+		ctx      context.Context
+		design   config.DesignAction
+		expected bool
+	}{
+		{
+			name:     "added node",
+			ctx:      ctx,
+			design:   config.DesignAction{Name: "start-nodes", Extra: map[string]interface{}{"nodes": []interface{}{"no3"}}},
+			expected: true,
+		},
+		{
+			name:   "existing node",
+			ctx:    ctx,
+			design: config.DesignAction{Name: "start-nodes", Extra: map[string]interface{}{"nodes": []interface{}{"no0"}}},
+		},
+		{name: "without nodes", ctx: ctx, design: config.DesignAction{Name: "start-nodes"}},
+		{name: "add-nodes", ctx: ctx, design: addNodes.Action},
+		{
+			name:   "no added nodes",
+			ctx:    context.Background(),
+			design: config.DesignAction{Name: "start-nodes", Extra: map[string]interface{}{"nodes": []interface{}{"no3"}}},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			lazy, err := isLazyAction(c.ctx, c.design)
+			t.NoError(err)
+			t.Equal(c.expected, lazy)
+		})
+	}
+}
+
+func TestAddNodes(t *testing.T) {
+	suite.Run(t, new(testAddNodes))
+}
@@ -0,0 +1,631 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+const (
+	ChaosFaultStop    = "stop"
+	ChaosFaultPause   = "pause"
+	ChaosFaultRestart = "restart"
+	chaosRecoverStart = "start"
+	chaosRecoverPause = "unpause"
+)
+
+var (
+	defaultChaosName     = "chaos"
+	defaultChaosInterval = time.Second * 10
+	defaultChaosDown     = time.Second * 10
+	chaosFaults          = []string{ChaosFaultStop, ChaosFaultPause, ChaosFaultRestart}
+)
+
+type chaosDown struct {
+	fault string
+	until time.Time
+}
+
+// ChaosAction injects the random faults to the nodes by interval until it is
+// stopped. The schedule is decided only by the seed, so the same seed makes
+// the same faults. The number of down nodes does not exceed max-down; the
+// nodes, which are stopped, paused or exited by the other reasons are also
+// counted.
+type ChaosAction struct {
+	sync.Mutex
+	*logging.Logging
+	name     string
+	aliases  []string
+	args     []string
+	interval time.Duration
+	down     time.Duration
+	duration time.Duration
+	maxDown  int
+	rates    map[string]uint
+	seed     int64
+	ctx      context.Context // NOTE context to make node actions
+	lo       *host.LogSaver
+	chaoses  *Chaoses
+	states   *host.NodeStates
+	downs    map[string]chaosDown
+	cancel   func()
+	done     chan struct{}
+	step     uint64
+}
+
+func NewChaosAction(ctx context.Context, design config.DesignAction) (*ChaosAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return nil, err
+	}
+
+	ac := &ChaosAction{
+		name:     defaultChaosName,
+		interval: defaultChaosInterval,
+		down:     defaultChaosDown,
+		args:     design.Args,
+		ctx:      ctx,
+		states:   hosts.NodeStates(),
+		downs:    map[string]chaosDown{},
+	}
+
+	if err := ac.loadOptions(design, hosts); err != nil {
+		return nil, err
+	}
+
+	ac.seed = config.SubSeed("chaos-" + ac.name)
+
+	if err := host.LoadLogSaverContextValue(ctx, &ac.lo); err != nil {
+		return nil, err
+	}
+
+	if err := LoadChaosesContextValue(ctx, &ac.chaoses); err != nil {
+		return nil, err
+	}
+
+	ac.Logging = logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+		return c.Str("module", "chaos-action").Str("chaos", ac.name)
+	})
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (ac *ChaosAction) loadOptions(design config.DesignAction, hosts *host.Hosts) error {
+	switch s, found, err := findStringFromDesign(design, "chaos"); {
+	case err != nil:
+		return err
+	case found && len(s) > 0:
+		ac.name = s
+	}
+
+	aliases, err := findNodesFromDesign(design)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := filterNodes(hosts, aliases)
+	if err != nil {
+		return err
+	}
+
+	ac.aliases = make([]string, len(nodes))
+	for i := range nodes {
+		ac.aliases[i] = nodes[i].Alias()
+	}
+	sort.Strings(ac.aliases)
+
+	if len(ac.aliases) < 1 {
+		return errors.Errorf("empty nodes for chaos")
+	}
+
+	for _, i := range []struct {
+		key string
+		d   *time.Duration
+	}{
+		{key: "interval", d: &ac.interval},
+		{key: "down", d: &ac.down},
+		{key: "duration", d: &ac.duration},
+	} {
+		switch d, found, err := findDurationFromDesign(design, i.key); {
+		case err != nil:
+			return err
+		case found:
+			*i.d = d
+		}
+	}
+
+	if ac.interval < 1 {
+		return errors.Errorf("chaos interval should be over zero")
+	}
+
+	if err := ac.loadRates(design); err != nil {
+		return err
+	}
+
+	return ac.loadMaxDown(design)
+}
+
+func (ac *ChaosAction) loadMaxDown(design config.DesignAction) error {
+	// NOTE by default, quorum of byzantine fault tolerance is preserved.
+	ac.maxDown = (len(ac.aliases) - 1) / 3
+	switch i, found, err := findIntFromDesign(design, "max-down"); {
+	case err != nil:
+		return err
+	case found:
+		if i < 0 {
+			return errors.Errorf("max-down should not be negative, %d", i)
+		}
+		ac.maxDown = i
+	}
+
+	// NOTE every fault, including restart, takes the node down, so with
+	// max-down 0 no fault is injected.
+	if ac.maxDown < 1 && ac.sumRates() > 0 {
+		return errors.Errorf("max-down of %d nodes is 0, no fault can be injected; set max-down over 0",
+			len(ac.aliases))
+	}
+
+	return nil
+}
+
+func (ac *ChaosAction) loadRates(design config.DesignAction) error {
+	ac.rates = map[string]uint{}
+
+	i, found := design.Extra["rates"]
+	if !found {
+		for _, f := range chaosFaults {
+			ac.rates[f] = 1
+		}
+
+		return nil
+	}
+
+	m, ok := i.(map[string]interface{})
+	if !ok {
+		return errors.Errorf("rates is not map type, %T", i)
+	}
+
+	var sum uint
+	for k := range m {
+		var known bool
+		for _, f := range chaosFaults {
+			if k == f {
+				known = true
+
+				break
+			}
+		}
+
+		if !known {
+			return errors.Errorf("unknown chaos fault, %q", k)
+		}
+
+		r, ok := m[k].(int)
+		if !ok || r < 0 {
+			return errors.Errorf("rate of %q should be positive int, %v", k, m[k])
+		}
+
+		ac.rates[k] = uint(r)
+		sum += uint(r)
+	}
+
+	if sum < 1 {
+		return errors.Errorf("empty chaos rates")
+	}
+
+	return nil
+}
+
+func (ac *ChaosAction) Name() string {
+	return "chaos"
+}
+
+func (ac *ChaosAction) Run(ctx context.Context) error {
+	if err := ac.chaoses.Add(ac); err != nil {
+		return err
+	}
+
+	var nctx context.Context
+	var cancel func()
+	if ac.duration > 0 {
+		nctx, cancel = context.WithTimeout(ctx, ac.duration)
+	} else {
+		nctx, cancel = context.WithCancel(ctx)
+	}
+
+	ac.Lock()
+	ac.cancel = cancel
+	ac.done = make(chan struct{})
+	ac.Unlock()
+
+	ac.saveFault("chaos started", "", "", nil)
+	ac.Log().Info().Int64("seed", ac.seed).Strs("nodes", ac.aliases).Int("max_down", ac.maxDown).
+		Msg("chaos started")
+
+	go func() {
+		ac.run(nctx)
+
+		close(ac.done)
+
+		ac.chaoses.remove(ac)
+	}()
+
+	return nil
+}
+
+// Stop stops the chaos and recovers the down nodes.
+func (ac *ChaosAction) Stop() {
+	ac.Lock()
+	cancel, done := ac.cancel, ac.done
+	ac.Unlock()
+
+	if cancel == nil {
+		return
+	}
+
+	cancel()
+	<-done
+}
+
+func (ac *ChaosAction) run(ctx context.Context) {
+	r := rand.New(rand.NewSource(ac.seed)) // nolint:gosec
+
+	faultTicker := time.NewTicker(ac.interval)
+	defer faultTicker.Stop()
+
+	recoverTicker := time.NewTicker(time.Millisecond * 100)
+	defer recoverTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			ac.recoverAll()
+
+			ac.saveFault("chaos stopped", "", "", nil)
+
+			return
+		case <-recoverTicker.C:
+			ac.recover(ctx, false)
+		case <-faultTicker.C:
+			ac.inject(ctx, r)
+		}
+	}
+}
+
+func (ac *ChaosAction) inject(ctx context.Context, r *rand.Rand) {
+	ac.step++
+
+	fault, n := ac.next(r)
+
+	alias, found := ac.pickNode(n)
+	if !found {
+		ac.Log().Debug().Str("fault", fault).Msg("too many down nodes; fault skipped")
+
+		return
+	}
+
+	var err error
+	switch fault {
+	case ChaosFaultStop:
+		err = ac.stopNode(ctx, alias)
+	case ChaosFaultPause:
+		err = ac.pauseNode(ctx, alias, true)
+	case ChaosFaultRestart:
+		if err = ac.stopNode(ctx, alias); err == nil {
+			err = ac.startNode(ctx, alias)
+		}
+	}
+
+	ac.saveFault("chaos fault", fault, alias, err)
+
+	if err != nil {
+		ac.Log().Error().Err(err).Str("fault", fault).Str("node", alias).Msg("failed to inject fault")
+
+		return
+	}
+
+	if fault != ChaosFaultRestart {
+		ac.downs[alias] = chaosDown{fault: fault, until: time.Now().Add(ac.down)}
+	}
+}
+
+// pickNode picks the node, which is not down, from the n'th node. If the
+// number of down nodes reaches max-down, no node is picked.
+func (ac *ChaosAction) pickNode(n int) (string, bool) {
+	var downs int
+	for i := range ac.aliases {
+		if ac.isDown(ac.aliases[i]) {
+			downs++
+		}
+	}
+
+	if downs >= ac.maxDown {
+		return "", false
+	}
+
+	for i := range ac.aliases {
+		if a := ac.aliases[(n+i)%len(ac.aliases)]; !ac.isDown(a) {
+			return a, true
+		}
+	}
+
+	return "", false
+}
+
+// isDown returns true if node is down by chaos or node is stopped, paused or
+// exited by the other reasons.
+func (ac *ChaosAction) isDown(alias string) bool {
+	if _, found := ac.downs[alias]; found {
+		return true
+	}
+
+	if ac.states == nil {
+		return false
+	}
+
+	switch r, _ := ac.states.State(alias); r.State {
+	case config.NodeStatePaused, config.NodeStateExited:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ac *ChaosAction) recover(ctx context.Context, all bool) {
+	aliases := make([]string, 0, len(ac.downs))
+	for alias := range ac.downs {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		d := ac.downs[alias]
+		if !all && time.Now().Before(d.until) {
+			continue
+		}
+
+		var err error
+		var fault string
+		switch d.fault {
+		case ChaosFaultStop:
+			fault = chaosRecoverStart
+			err = ac.startNode(ctx, alias)
+		case ChaosFaultPause:
+			fault = chaosRecoverPause
+			err = ac.pauseNode(ctx, alias, false)
+		}
+
+		ac.saveFault("chaos fault", fault, alias, err)
+
+		if err != nil {
+			ac.Log().Error().Err(err).Str("fault", fault).Str("node", alias).Msg("failed to recover node")
+		}
+
+		delete(ac.downs, alias)
+	}
+}
+
+func (ac *ChaosAction) recoverAll() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+	defer cancel()
+
+	ac.recover(ctx, true)
+}
+
+// next draws the fault and the index of node. The random numbers are always
+// drawn, so the schedule does not depend on the state of nodes.
+func (ac *ChaosAction) next(r *rand.Rand) (string, int) {
+	fault := ac.pickFault(r.Intn(ac.sumRates()))
+
+	return fault, r.Intn(len(ac.aliases))
+}
+
+func (ac *ChaosAction) sumRates() int {
+	var sum int
+	for _, f := range chaosFaults {
+		sum += int(ac.rates[f])
+	}
+
+	return sum
+}
+
+func (ac *ChaosAction) pickFault(n int) string {
+	for _, f := range chaosFaults {
+		if n < int(ac.rates[f]) {
+			return f
+		}
+		n -= int(ac.rates[f])
+	}
+
+	return chaosFaults[len(chaosFaults)-1]
+}
+
+func (ac *ChaosAction) stopNode(ctx context.Context, alias string) error {
+	a, err := NewStopNodesAction(ac.ctx, []string{alias})
+	if err != nil {
+		return err
+	}
+
+	return a.Run(ctx)
+}
+
+func (ac *ChaosAction) startNode(ctx context.Context, alias string) error {
+	a, err := NewStartNodesAction(ac.ctx, []string{alias}, ac.args)
+	if err != nil {
+		return err
+	}
+
+	return a.Run(ctx)
+}
+
+func (ac *ChaosAction) pauseNode(ctx context.Context, alias string, pause bool) error {
+	b, err := NewBaseNodesAction(ac.ctx, "pause-nodes", []string{alias}, nil)
+	if err != nil {
+		return err
+	}
+
+	node := b.nodes[0]
+
+	ids, err := filterRunningContainers(ctx, b.nodes, false)
+	if err != nil {
+		return err
+	}
+
+	id := ids[alias]
+	if len(id) < 1 {
+		return errors.Errorf("node, %q is not running", alias)
+	}
+
+	state := config.NodeStateRunning
+	if pause {
+		state = config.NodeStatePaused
+
+		err = node.Host().DockerClient().ContainerPause(ctx, id)
+	} else {
+		err = node.Host().DockerClient().ContainerUnpause(ctx, id)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_ = b.states.Set(alias, state, ac.Name())
+
+	return nil
+}
+
+func (ac *ChaosAction) saveFault(m, fault, alias string, faultErr error) {
+	e := map[string]interface{}{
+		"m":     m,
+		"chaos": ac.name,
+		"seed":  ac.seed,
+		"step":  ac.step,
+	}
+
+	if len(fault) > 0 {
+		e["fault"] = fault
+		e["node"] = alias
+	}
+
+	if faultErr != nil {
+		e["error"] = faultErr.Error()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	ac.lo.LogEntryChan() <- host.NewContestLogEntry(b, faultErr != nil)
+}
+
+func (ac *ChaosAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":     ac.Name(),
+		"chaos":    ac.name,
+		"nodes":    ac.aliases,
+		"interval": ac.interval.String(),
+		"down":     ac.down.String(),
+		"duration": ac.duration.String(),
+		"max-down": ac.maxDown,
+		"rates":    ac.rates,
+		"seed":     ac.seed,
+	})
+}
+
+type StopChaosAction struct {
+	chaoses *Chaoses
+	name    string
+}
+
+func (StopChaosAction) Name() string {
+	return "stop-chaos"
+}
+
+func (ac StopChaosAction) Run(context.Context) error {
+	var names []string
+	if len(ac.name) > 0 {
+		names = []string{ac.name}
+	}
+
+	return ac.chaoses.Stop(names...)
+}
+
+func (ac StopChaosAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"name": ac.Name(), "chaos": ac.name})
+}
+
+// Chaoses keeps the running chaos by name; the finished chaos is removed.
+type Chaoses struct {
+	sync.RWMutex
+	m map[string]*ChaosAction
+}
+
+func NewChaoses() *Chaoses {
+	return &Chaoses{m: map[string]*ChaosAction{}}
+}
+
+func (cs *Chaoses) Add(ac *ChaosAction) error {
+	cs.Lock()
+	defer cs.Unlock()
+
+	if _, found := cs.m[ac.name]; found {
+		return errors.Errorf("chaos, %q already exists", ac.name)
+	}
+
+	cs.m[ac.name] = ac
+
+	return nil
+}
+
+// Stop stops the chaos by name; if no names given, all the chaos are stopped.
+func (cs *Chaoses) Stop(names ...string) error {
+	cs.Lock()
+	defer cs.Unlock()
+
+	if len(names) < 1 {
+		for name := range cs.m {
+			names = append(names, name)
+		}
+	}
+
+	for _, name := range names {
+		ac, found := cs.m[name]
+		if !found {
+			return errors.Errorf("chaos, %q not found", name)
+		}
+
+		ac.Stop()
+		delete(cs.m, name)
+	}
+
+	return nil
+}
+
+// remove removes the finished chaos.
+func (cs *Chaoses) remove(ac *ChaosAction) {
+	cs.Lock()
+	defer cs.Unlock()
+
+	if i, found := cs.m[ac.name]; found && i == ac {
+		delete(cs.m, ac.name)
+	}
+}
@@ -0,0 +1,272 @@
+package cmds
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+type testChaos struct {
+	suite.Suite
+}
+
+func (t *testChaos) newChaos(n int, extra map[string]interface{}) (*ChaosAction, error) {
+	ac := &ChaosAction{name: defaultChaosName}
+	for i := 0; i < n; i++ {
+		ac.aliases = append(ac.aliases, "no"+string(rune('0'+i)))
+	}
+
+	design := config.DesignAction{Name: "chaos", Extra: extra}
+	if err := ac.loadRates(design); err != nil {
+		return nil, err
+	}
+
+	return ac, ac.loadMaxDown(design)
+}
+
+func (t *testChaos) TestPickFault() {
+	ac := &ChaosAction{rates: map[string]uint{ChaosFaultStop: 1, ChaosFaultPause: 2, ChaosFaultRestart: 3}}
+	t.Equal(6, ac.sumRates())
+
+	expected := []string{
+		ChaosFaultStop,
+		ChaosFaultPause, ChaosFaultPause,
+		ChaosFaultRestart, ChaosFaultRestart, ChaosFaultRestart,
+	}
+
+	for i := range expected {
+		t.Equal(expected[i], ac.pickFault(i), "n=%d", i)
+	}
+
+	ac = &ChaosAction{rates: map[string]uint{ChaosFaultStop: 0, ChaosFaultPause: 1, ChaosFaultRestart: 0}}
+	t.Equal(1, ac.sumRates())
+	t.Equal(ChaosFaultPause, ac.pickFault(0))
+}
+
+func (t *testChaos) TestLoadRates() {
+	cases := []struct {
+		name     string
+		rates    interface{}
+		expected map[string]uint
+		err      string
+	}{
+		{
+			name:     "default",
+			expected: map[string]uint{ChaosFaultStop: 1, ChaosFaultPause: 1, ChaosFaultRestart: 1},
+		},
+		{
+			name:     "rates",
+			rates:    map[string]interface{}{ChaosFaultStop: 3, ChaosFaultRestart: 1},
+			expected: map[string]uint{ChaosFaultStop: 3, ChaosFaultRestart: 1},
+		},
+		{name: "not map", rates: []interface{}{1}, err: "not map type"},
+		{name: "unknown fault", rates: map[string]interface{}{"kill": 1}, err: "unknown chaos fault"},
+		{name: "negative", rates: map[string]interface{}{ChaosFaultStop: -1}, err: "should be positive int"},
+		{name: "not int", rates: map[string]interface{}{ChaosFaultStop: "1"}, err: "should be positive int"},
+		{name: "empty", rates: map[string]interface{}{ChaosFaultStop: 0}, err: "empty chaos rates"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			extra := map[string]interface{}{"max-down": 1}
+			if c.rates != nil {
+				extra["rates"] = c.rates
+			}
+
+			ac, err := t.newChaos(4, extra)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.expected, ac.rates)
+		})
+	}
+}
+
+func (t *testChaos) TestMaxDown() {
+	cases := []struct {
+		name     string
+		nodes    int
+		maxDown  interface{}
+		expected int
+		err      string
+	}{
+		{name: "default 4 nodes", nodes: 4, expected: 1},
+		{name: "default 7 nodes", nodes: 7, expected: 2},
+		{name: "default 3 nodes", nodes: 3, err: "max-down of 3 nodes is 0"},
+		{name: "default 1 node", nodes: 1, err: "max-down of 1 nodes is 0"},
+		{name: "3 nodes", nodes: 3, maxDown: 1, expected: 1},
+		{name: "zero", nodes: 4, maxDown: 0, err: "max-down of 4 nodes is 0"},
+		{name: "negative", nodes: 4, maxDown: -1, err: "should not be negative"},
+		{name: "not int", nodes: 4, maxDown: "1", err: "not int type"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			extra := map[string]interface{}{}
+			if c.maxDown != nil {
+				extra["max-down"] = c.maxDown
+			}
+
+			ac, err := t.newChaos(c.nodes, extra)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.expected, ac.maxDown)
+		})
+	}
+}
+
+func (t *testChaos) draws(ac *ChaosAction, seed int64, n int) ([]string, []int) {
+	r := rand.New(rand.NewSource(seed)) // nolint:gosec
+
+	faults := make([]string, n)
+	nodes := make([]int, n)
+	for i := 0; i < n; i++ {
+		faults[i], nodes[i] = ac.next(r)
+	}
+
+	return faults, nodes
+}
+
+func (t *testChaos) TestSeed() {
+	ac, err := t.newChaos(4, nil)
+	t.NoError(err)
+
+	faultsA, nodesA := t.draws(ac, 33, 100)
+	faultsB, nodesB := t.draws(ac, 33, 100)
+	t.Equal(faultsA, faultsB)
+	t.Equal(nodesA, nodesB)
+
+	faultsC, nodesC := t.draws(ac, 34, 100)
+	t.False(reflect.DeepEqual(faultsA, faultsC) && reflect.DeepEqual(nodesA, nodesC))
+
+	counts := map[string]int{}
+	for i := range faultsA {
+		counts[faultsA[i]]++
+		t.True(nodesA[i] >= 0 && nodesA[i] < len(ac.aliases))
+	}
+
+	for _, f := range chaosFaults {
+		t.True(counts[f] > 0, "fault, %q not drawn", f)
+	}
+}
+
+func (t *testChaos) TestSeedRates() {
+	ac, err := t.newChaos(4, map[string]interface{}{
+		"rates": map[string]interface{}{ChaosFaultStop: 1, ChaosFaultPause: 0, ChaosFaultRestart: 9},
+	})
+	t.NoError(err)
+
+	faults, _ := t.draws(ac, 33, 1000)
+
+	counts := map[string]int{}
+	for i := range faults {
+		counts[faults[i]]++
+	}
+
+	t.Equal(0, counts[ChaosFaultPause])
+	t.True(counts[ChaosFaultStop] > 0)
+	t.True(counts[ChaosFaultRestart] > counts[ChaosFaultStop]*5)
+}
+
+func (t *testChaos) TestPickNode() {
+	cases := []struct {
+		name     string
+		downs    []string
+		states   map[string]string
+		n        int
+		expected string
+	}{
+		{name: "no down", n: 1, expected: "no1"},
+		{name: "skip chaos down", downs: []string{"no1"}, n: 1, expected: "no2"},
+		{
+			name:     "skip exited",
+			states:   map[string]string{"no1": config.NodeStateExited},
+			n:        1,
+			expected: "no2",
+		},
+		{
+			name:     "wrap around",
+			states:   map[string]string{"no6": config.NodeStatePaused},
+			n:        6,
+			expected: "no0",
+		},
+		{name: "chaos downs reach max-down", downs: []string{"no0", "no1"}, n: 3},
+		{
+			name:   "exited and paused reach max-down",
+			states: map[string]string{"no0": config.NodeStateExited, "no4": config.NodeStatePaused},
+			n:      3,
+		},
+		{
+			name:   "chaos down and exited reach max-down",
+			downs:  []string{"no0"},
+			states: map[string]string{"no5": config.NodeStateExited},
+			n:      3,
+		},
+		{
+			name:     "running is not down",
+			downs:    []string{"no0"},
+			states:   map[string]string{"no1": config.NodeStateRunning, "no2": config.NodeStateNotStarted},
+			n:        1,
+			expected: "no1",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			ac, err := t.newChaos(7, nil)
+			t.NoError(err)
+			t.Equal(2, ac.maxDown)
+
+			ac.states = host.NewNodeStates(nil, config.NewVars(nil))
+			for alias, state := range c.states {
+				_ = ac.states.Set(alias, state, "")
+			}
+
+			ac.downs = map[string]chaosDown{}
+			for _, alias := range c.downs {
+				ac.downs[alias] = chaosDown{fault: ChaosFaultStop}
+			}
+
+			alias, found := ac.pickNode(c.n)
+			t.Equal(len(c.expected) > 0, found)
+			t.Equal(c.expected, alias)
+		})
+	}
+}
+
+func (t *testChaos) TestChaosesRemove() {
+	cs := NewChaoses()
+
+	a := &ChaosAction{name: "a"}
+	t.NoError(cs.Add(a))
+	t.Error(cs.Add(&ChaosAction{name: "a"}))
+
+	cs.remove(&ChaosAction{name: "a"}) // NOTE other chaos of same name is not removed
+	t.Error(cs.Add(&ChaosAction{name: "a"}))
+
+	cs.remove(a)
+	t.NoError(cs.Add(&ChaosAction{name: "a"}))
+}
+
+func TestChaos(t *testing.T) {
+	suite.Run(t, new(testChaos))
+}
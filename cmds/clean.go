@@ -0,0 +1,71 @@
+package cmds
+
+import (
+	"context"
+	"os"
+
+	dockerClient "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	mitumcmds "github.com/spikeekips/mitum/launch/cmds"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/host"
+)
+
+// CleanCommand removes the containers and networks of the finished contest;
+// with "--all", those of all the contests in the docker host are removed.
+type CleanCommand struct {
+	*logging.Logging
+	*mitumcmds.LogFlags
+	TestName string `arg:"" name:"test name" optional:"" help:"test name of contest"`
+	All      bool   `name:"all" help:"clean the containers of all the contests"`
+	Force    bool   `name:"force" help:"clean the still running containers"`
+}
+
+func NewCleanCommand() (CleanCommand, error) {
+	cmd := CleanCommand{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "command-clean")
+		}),
+		LogFlags: &mitumcmds.LogFlags{},
+	}
+
+	return cmd, nil
+}
+
+func (cmd *CleanCommand) Run(util.Version) error {
+	i, err := mitumcmds.SetupLoggingFromFlags(cmd.LogFlags, os.Stdout)
+	if err != nil {
+		return err
+	}
+	_ = cmd.SetLogging(i)
+
+	switch {
+	case cmd.All && len(cmd.TestName) > 0:
+		return errors.Errorf("test name and --all can not be given together")
+	case !cmd.All && len(cmd.TestName) < 1:
+		return errors.Errorf("test name or --all should be given")
+	}
+
+	client, err := dockerClient.NewClientWithOpts(dockerClient.FromEnv)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = client.Close()
+	}()
+
+	l := cmd.Log().With().Str("test_name", cmd.TestName).Bool("all", cmd.All).Bool("force", cmd.Force).Logger()
+	l.Debug().Msg("trying to clean containers")
+
+	if err := host.CleanContainers(context.Background(), client, cmd.TestName, false, cmd.Force); err != nil {
+		return errors.Wrap(err, "failed to clean containers")
+	}
+
+	l.Info().Msg("containers cleaned")
+
+	return nil
+}
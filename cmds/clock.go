@@ -0,0 +1,167 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+var skewClockActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	return NewSkewClockAction(ctx, design)
+}
+
+// SkewClockAction changes the clocks of nodes. Only the nodes, which have
+// clock in design can be skewed; the omitted offset keeps the current
+// effective offset and the omitted drift keeps the current one. The changed
+// clock is applied to node when node queries the time server, within 2
+// minutes.
+type SkewClockAction struct {
+	*logging.Logging
+	extra   map[string]interface{}
+	aliases []string
+	clocks  []*host.Clock
+	lo      *host.LogSaver
+}
+
+func NewSkewClockAction(ctx context.Context, design config.DesignAction) (*SkewClockAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return nil, err
+	}
+
+	ac := &SkewClockAction{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "skew-clock-action")
+		}),
+		extra: map[string]interface{}{},
+	}
+
+	for _, k := range []string{"offset", "drift"} {
+		if i, found := design.Extra[k]; found {
+			ac.extra[k] = i
+		}
+	}
+
+	if len(ac.extra) < 1 {
+		return nil, errors.Errorf("empty offset and drift for skew-clock")
+	}
+
+	if err := ac.loadNodes(design, hosts); err != nil {
+		return nil, err
+	}
+
+	if err := host.LoadLogSaverContextValue(ctx, &ac.lo); err != nil {
+		return nil, err
+	}
+
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (ac *SkewClockAction) loadNodes(design config.DesignAction, hosts *host.Hosts) error {
+	aliases, err := findNodesFromDesign(design)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := filterNodes(hosts, aliases)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Alias() < nodes[j].Alias() })
+
+	for i := range nodes {
+		node := nodes[i]
+
+		c := node.Clock()
+		if c == nil {
+			if len(aliases) < 1 {
+				continue
+			}
+
+			return errors.Errorf("node, %q does not have clock; set clocks in design", node.Alias())
+		}
+
+		// NOTE check the values before running
+		if _, err := config.ParseDesignClock(ac.extra, c.Design()); err != nil {
+			return err
+		}
+
+		ac.aliases = append(ac.aliases, node.Alias())
+		ac.clocks = append(ac.clocks, c)
+	}
+
+	if len(ac.clocks) < 1 {
+		return errors.Errorf("empty nodes for skew-clock")
+	}
+
+	return nil
+}
+
+func (*SkewClockAction) Name() string {
+	return "skew-clock"
+}
+
+func (ac *SkewClockAction) Run(context.Context) error {
+	for i := range ac.clocks {
+		c := ac.clocks[i]
+
+		if _, err := c.Skew(ac.extra); err != nil {
+			return err
+		}
+
+		ac.saveClock(ac.aliases[i], c)
+	}
+
+	return nil
+}
+
+func (ac *SkewClockAction) saveClock(alias string, c *host.Clock) {
+	de := c.Design()
+
+	e := map[string]interface{}{
+		"m":         "clock skewed",
+		"node":      alias,
+		"offset":    de.Offset.String(),
+		"drift":     de.Drift,
+		"effective": c.Offset().String(),
+	}
+
+	ac.Log().Debug().Interface("clock", e).Msg("clock skewed")
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	ac.lo.LogEntryChan() <- host.NewContestLogEntry(b, false)
+}
+
+func (ac *SkewClockAction) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"name":  ac.Name(),
+		"nodes": ac.aliases,
+	}
+
+	for k := range ac.extra {
+		m[k] = ac.extra[k]
+	}
+
+	return json.Marshal(m)
+}
@@ -8,8 +8,10 @@ import (
 )
 
 var (
-	ContextValueExitError util.ContextKey = "exit_error"
-	ContextValueExitChan  util.ContextKey = "exit_chan"
+	ContextValueExitError  util.ContextKey = "exit_error"
+	ContextValueExitChan   util.ContextKey = "exit_chan"
+	ContextValueChaoses    util.ContextKey = "chaoses"
+	ContextValueAddedNodes util.ContextKey = "added_nodes"
 )
 
 func LoadExitErrorContextValue(ctx context.Context, l *error) error {
@@ -28,3 +30,11 @@ func LoadExitErrorContextValue(ctx context.Context, l *error) error {
 func LoadExitChanContextValue(ctx context.Context, l *chan error) error {
 	return util.LoadFromContextValue(ctx, ContextValueExitChan, l)
 }
+
+func LoadChaosesContextValue(ctx context.Context, l **Chaoses) error {
+	return util.LoadFromContextValue(ctx, ContextValueChaoses, l)
+}
+
+func LoadAddedNodesContextValue(ctx context.Context, l *map[string]struct{}) error {
+	return util.LoadFromContextValue(ctx, ContextValueAddedNodes, l)
+}
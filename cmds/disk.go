@@ -0,0 +1,222 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+const (
+	DiskFaultFill      = "fill"
+	DiskFaultUnfill    = "unfill"
+	DiskFaultReadOnly  = "readonly"
+	DiskFaultReadWrite = "readwrite"
+	DiskFaultIOError   = "io-error"
+	DiskFaultRecoverIO = "recover-io"
+)
+
+var diskFaults = []string{
+	DiskFaultFill, DiskFaultUnfill, DiskFaultReadOnly, DiskFaultReadWrite, DiskFaultIOError, DiskFaultRecoverIO,
+}
+
+var diskFaultActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	return NewDiskFaultAction(ctx, design)
+}
+
+// DiskFaultAction injects the fault to the data directory of nodes. fill and
+// readonly are allowed for tmpfs and loop disk, io-error is allowed only for
+// loop disk.
+type DiskFaultAction struct {
+	*logging.Logging
+	fault   string
+	size    uint64 // NOTE size to fill; 0 fills the available space
+	aliases []string
+	disks   []*host.Disk
+	lo      *host.LogSaver
+}
+
+func NewDiskFaultAction(ctx context.Context, design config.DesignAction) (*DiskFaultAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return nil, err
+	}
+
+	ac := &DiskFaultAction{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "disk-fault-action")
+		}),
+	}
+
+	if err := ac.loadOptions(design, hosts); err != nil {
+		return nil, err
+	}
+
+	if err := host.LoadLogSaverContextValue(ctx, &ac.lo); err != nil {
+		return nil, err
+	}
+
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (ac *DiskFaultAction) loadOptions(design config.DesignAction, hosts *host.Hosts) error {
+	switch s, found, err := findStringFromDesign(design, "fault"); {
+	case err != nil:
+		return err
+	case !found || len(s) < 1:
+		return errors.Errorf("empty disk fault")
+	default:
+		var known bool
+		for _, f := range diskFaults {
+			if s == f {
+				known = true
+
+				break
+			}
+		}
+
+		if !known {
+			return errors.Errorf("unknown disk fault, %q", s)
+		}
+
+		ac.fault = s
+	}
+
+	switch s, found, err := findStringFromDesign(design, "size"); {
+	case err != nil:
+		return err
+	case found:
+		i, err := config.ParseByteSize(s)
+		if err != nil {
+			return err
+		}
+		ac.size = i
+	}
+
+	aliases, err := findNodesFromDesign(design)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := filterNodes(hosts, aliases)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Alias() < nodes[j].Alias() })
+
+	for i := range nodes {
+		node := nodes[i]
+
+		d := node.Disk()
+		if d == nil {
+			return errors.Errorf("disk of node, %q not found", node.Alias())
+		}
+
+		switch t := d.Design().Type; {
+		case t == config.DiskTypeBind && ac.fault != DiskFaultUnfill:
+			// NOTE bind disk is the directory of host, so the fault affects the
+			// host
+			return errors.Errorf("%s is not allowed for bind disk of node, %q", ac.fault, node.Alias())
+		case (ac.fault == DiskFaultIOError || ac.fault == DiskFaultRecoverIO) && t != config.DiskTypeLoop:
+			return errors.Errorf("%s is allowed only for loop disk; node, %q", ac.fault, node.Alias())
+		}
+
+		ac.aliases = append(ac.aliases, node.Alias())
+		ac.disks = append(ac.disks, d)
+	}
+
+	if len(ac.disks) < 1 {
+		return errors.Errorf("empty nodes for disk fault")
+	}
+
+	return nil
+}
+
+func (*DiskFaultAction) Name() string {
+	return "disk-fault"
+}
+
+func (ac *DiskFaultAction) Run(ctx context.Context) error {
+	err := host.RunWaitGroup(len(ac.disks), func(i int) error {
+		err := ac.inject(ctx, ac.disks[i])
+		ac.saveFault(ac.aliases[i], err)
+
+		return err
+	})
+	if err != nil {
+		ac.Log().Error().Err(err).Str("fault", ac.fault).Msg("failed to inject disk fault")
+
+		return err
+	}
+
+	ac.Log().Debug().Str("fault", ac.fault).Strs("nodes", ac.aliases).Msg("disk fault injected")
+
+	return nil
+}
+
+func (ac *DiskFaultAction) inject(ctx context.Context, d *host.Disk) error {
+	switch ac.fault {
+	case DiskFaultFill:
+		return d.Fill(ctx, ac.size)
+	case DiskFaultUnfill:
+		return d.Unfill(ctx)
+	case DiskFaultReadOnly:
+		return d.SetReadOnly(ctx, true)
+	case DiskFaultReadWrite:
+		return d.SetReadOnly(ctx, false)
+	case DiskFaultIOError:
+		return d.SetIOError(ctx, true)
+	case DiskFaultRecoverIO:
+		return d.SetIOError(ctx, false)
+	default:
+		return errors.Errorf("unknown disk fault, %q", ac.fault)
+	}
+}
+
+func (ac *DiskFaultAction) saveFault(alias string, faultErr error) {
+	e := map[string]interface{}{
+		"m":     "disk fault",
+		"fault": ac.fault,
+		"node":  alias,
+	}
+
+	if ac.fault == DiskFaultFill {
+		e["size"] = ac.size
+	}
+
+	if faultErr != nil {
+		e["error"] = faultErr.Error()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	ac.lo.LogEntryChan() <- host.NewContestLogEntry(b, faultErr != nil)
+}
+
+func (ac *DiskFaultAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":  ac.Name(),
+		"fault": ac.fault,
+		"nodes": ac.aliases,
+		"size":  ac.size,
+	})
+}
@@ -0,0 +1,52 @@
+package cmds
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+// checkExpect compares the exit cause of contest with the expect of design.
+// It returns nil when the exit cause is expected. The exit code of node is
+// not from the stderr output, but from the exit of container by exitCode.
+func checkExpect(
+	expect config.DesignExpect, exitError error, exitCode func(string) (int64, bool),
+) error {
+	if expect.IsEmpty() {
+		return exitError
+	}
+
+	if exitError == nil {
+		return errors.Errorf("expected %s exit, but contest finished without error", expect.Type)
+	}
+
+	var ke KillError
+	var xe host.NodeExitError
+	var ne host.NodeStderrError
+
+	switch {
+	case errors.As(exitError, &ke):
+		if expect.MatchKill(ke.Error()) {
+			return nil
+		}
+	case errors.As(exitError, &xe):
+		if expect.MatchExitCode(xe.Node, xe.ExitCode) {
+			return nil
+		}
+	case errors.As(exitError, &ne):
+		if expect.MatchStderr(ne.Node, ne.Err) {
+			return nil
+		}
+
+		if expect.Type != config.ExpectExitCodeType || exitCode == nil {
+			break
+		}
+
+		if code, exited := exitCode(ne.Node); exited && expect.MatchExitCode(ne.Node, code) {
+			return nil
+		}
+	}
+
+	return errors.Wrapf(exitError, "expected %s exit, but not matched", expect.Type)
+}
@@ -0,0 +1,141 @@
+package cmds
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+type testCheckExpect struct {
+	suite.Suite
+}
+
+func (t *testCheckExpect) TestExpect() {
+	exited := map[string]int64{"no0": 3, "no1": 0}
+	exitCode := func(alias string) (int64, bool) {
+		i, found := exited[alias]
+
+		return i, found
+	}
+
+	stderr := host.NewNodeStderrError("no0", []byte(`{"m": "panic: showme"}`))
+
+	cases := []struct {
+		name    string
+		expect  config.DesignExpect
+		err     error
+		matched bool
+	}{
+		{
+			name:    "empty expect without error",
+			matched: true,
+		},
+		{
+			name: "empty expect with error",
+			err:  errors.Errorf("showme"),
+		},
+		{
+			name:   "without error",
+			expect: config.DesignExpect{Type: config.ExpectKillType, Pattern: "showme"},
+		},
+		{
+			name:    "kill",
+			expect:  config.DesignExpect{Type: config.ExpectKillType, Pattern: "^show"},
+			err:     KillError{Err: errors.Errorf("showme")},
+			matched: true,
+		},
+		{
+			name:   "kill not matched",
+			expect: config.DesignExpect{Type: config.ExpectKillType, Pattern: "findme"},
+			err:    KillError{Err: errors.Errorf("showme")},
+		},
+		{
+			name:   "kill by stderr",
+			expect: config.DesignExpect{Type: config.ExpectKillType, Pattern: "showme"},
+			err:    stderr,
+		},
+		{
+			name:    "stderr",
+			expect:  config.DesignExpect{Type: config.ExpectStderrType, Pattern: "panic: "},
+			err:     stderr,
+			matched: true,
+		},
+		{
+			name:    "stderr of node",
+			expect:  config.DesignExpect{Type: config.ExpectStderrType, Node: "no0", Pattern: "panic: "},
+			err:     stderr,
+			matched: true,
+		},
+		{
+			name:   "stderr of other node",
+			expect: config.DesignExpect{Type: config.ExpectStderrType, Node: "no1", Pattern: "panic: "},
+			err:    stderr,
+		},
+		{
+			name:   "stderr by kill",
+			expect: config.DesignExpect{Type: config.ExpectStderrType, Pattern: "showme"},
+			err:    KillError{Err: errors.Errorf("showme")},
+		},
+		{
+			name:    "exit code from exit",
+			expect:  config.DesignExpect{Type: config.ExpectExitCodeType, Node: "no0", Code: 3},
+			err:     stderr,
+			matched: true,
+		},
+		{
+			name:   "exit code not matched",
+			expect: config.DesignExpect{Type: config.ExpectExitCodeType, Node: "no0", Code: 1},
+			err:    stderr,
+		},
+		{
+			name:   "exit code, but not exited",
+			expect: config.DesignExpect{Type: config.ExpectExitCodeType, Code: 0},
+			err:    host.NewNodeStderrError("no2", []byte(`{"status_code": 0}`)),
+		},
+		{
+			name:    "clean exit",
+			expect:  config.DesignExpect{Type: config.ExpectExitCodeType, Node: "no1", Code: 0},
+			err:     host.NewNodeExitError("no1", 0),
+			matched: true,
+		},
+		{
+			name:   "clean exit of other node",
+			expect: config.DesignExpect{Type: config.ExpectExitCodeType, Node: "no0", Code: 0},
+			err:    host.NewNodeExitError("no1", 0),
+		},
+		{
+			name:    "wrapped exit",
+			expect:  config.DesignExpect{Type: config.ExpectExitCodeType, Code: 0},
+			err:     errors.Wrap(host.NewNodeExitError("no1", 0), "findme"),
+			matched: true,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			t.NoError(c.expect.IsValid(nil))
+
+			err := checkExpect(c.expect, c.err, exitCode)
+			if c.matched {
+				t.NoError(err)
+
+				return
+			}
+
+			t.Error(err)
+			if c.err != nil && !c.expect.IsEmpty() {
+				t.Contains(err.Error(), "but not matched")
+				t.Contains(err.Error(), c.err.Error())
+			}
+		})
+	}
+}
+
+func TestCheckExpect(t *testing.T) {
+	suite.Run(t, new(testCheckExpect))
+}
@@ -0,0 +1,166 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+const (
+	HookNameBackgroundActions = "background_actions"
+	HookNameStopLoads         = "stop_loads"
+	HookNameStopChaos         = "stop_chaos"
+	HookNameConsistency       = "consistency"
+)
+
+// HookBackgroundActions prepares the registries of the actions, which run in
+// background.
+func HookBackgroundActions(ctx context.Context) (context.Context, error) {
+	ctx = context.WithValue(ctx, host.ContextValueLoads, host.NewLoads())
+
+	return context.WithValue(ctx, ContextValueChaoses, NewChaoses()), nil
+}
+
+// HookStopChaos stops the running chaos before the other close hooks, so the
+// down nodes are recovered.
+func HookStopChaos(ctx context.Context) (context.Context, error) {
+	var chaoses *Chaoses
+	switch err := LoadChaosesContextValue(ctx, &chaoses); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	return ctx, chaoses.Stop()
+}
+
+// HookStopLoads stops the running loads and prints the summary of all the
+// loads.
+func HookStopLoads(ctx context.Context) (context.Context, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return ctx, err
+	}
+
+	var loads *host.Loads
+	switch err := host.LoadLoadsContextValue(ctx, &loads); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
+		return ctx, err
+	}
+
+	summaries, err := loads.Stop()
+	if err != nil {
+		return ctx, err
+	}
+
+	for i := range summaries {
+		s := summaries[i]
+
+		log.Log().Info().Interface("summary", s.Map()).Msg("load summary")
+
+		_, _ = fmt.Fprintf(os.Stderr,
+			"= load summary, %q: requests=%d errors=%d elapsed=%s throughput=%.2f/s p50=%s p90=%s p99=%s max=%s\n",
+			s.Name, s.Requests, s.Errors, s.Elapsed, s.Throughput, s.P50, s.P90, s.P99, s.Max,
+		)
+
+		saveLoadSummary(lo, "load summary", s)
+	}
+
+	return ctx, nil
+}
+
+func saveLoadSummary(lo *host.LogSaver, m string, s host.LoadSummary) {
+	sm := s.Map()
+	sm["m"] = m
+
+	b, err := json.Marshal(sm)
+	if err != nil {
+		return
+	}
+
+	lo.LogEntryChan() <- host.NewContestLogEntry(b, false)
+}
+
+// HookConsistency checks the consistency of node storages at shutdown, if
+// enabled. The divergence becomes the exit error.
+func HookConsistency(ctx context.Context) (context.Context, error) {
+	var design config.Design
+	switch err := config.LoadDesignContextValue(ctx, &design); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	case !design.Consistency.AtShutdown:
+		return ctx, nil
+	}
+
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return ctx, err
+	}
+
+	var vars *config.Vars
+	if err := config.LoadVarsContextValue(ctx, &vars); err != nil {
+		return ctx, err
+	}
+
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
+		return ctx, err
+	}
+
+	var hosts *host.Hosts
+	switch err := host.LoadHostsContextValue(ctx, &hosts); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	cctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	nodes, err := host.ConsistencyNodes(hosts, design.Consistency)
+	if err != nil {
+		return ctx, err
+	}
+
+	result, err := checkConsistency(cctx, design.Consistency, nodes, hosts, vars, lo)
+	if err == nil {
+		log.Log().Info().Interface("result", result).Msg("nodes are consistent")
+		_, _ = fmt.Fprintf(os.Stderr, "= consistency: %v consistent until height %d\n", nodes, result.Checked)
+
+		return ctx, nil
+	}
+
+	log.Log().Error().Err(err).Msg("consistency failed at shutdown")
+	_, _ = fmt.Fprintf(os.Stderr, "= consistency failed: %v\n", err)
+
+	var exitError error
+	if err := LoadExitErrorContextValue(ctx, &exitError); err != nil {
+		return ctx, err
+	}
+
+	if exitError != nil {
+		return ctx, nil
+	}
+
+	return context.WithValue(ctx, ContextValueExitError, err), nil
+}
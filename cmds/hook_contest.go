@@ -2,11 +2,22 @@ package cmds
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"os"
 
+	"github.com/pkg/errors"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
 	"github.com/spikeekips/contest/host"
 )
 
-const HookNameContestReady = "contest_ready"
+const (
+	HookNameContestReady = "contest_ready"
+	HookNameNodeStates   = "node_states"
+)
 
 func HookContestReady(ctx context.Context) (context.Context, error) {
 	var ls *host.LogSaver
@@ -18,3 +29,53 @@ func HookContestReady(ctx context.Context) (context.Context, error) {
 
 	return ctx, nil
 }
+
+// HookNodeStates prints the last states of nodes and saves the state history
+// of all the nodes.
+func HookNodeStates(ctx context.Context) (context.Context, error) {
+	var hosts *host.Hosts
+	switch err := host.LoadHostsContextValue(ctx, &hosts); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return ctx, err
+	}
+
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
+		return ctx, err
+	}
+
+	states := hosts.NodeStates()
+
+	nodes := map[string]interface{}{}
+	for _, alias := range states.Aliases() {
+		r, restarts := states.State(alias)
+
+		_, _ = fmt.Fprintf(os.Stderr, "= node state, %q: state=%s exit_code=%d restarts=%d\n",
+			alias, r.State, r.ExitCode, restarts)
+
+		nodes[alias] = map[string]interface{}{
+			"state":     r.State,
+			"exit_code": r.ExitCode,
+			"restarts":  restarts,
+			"history":   states.History(alias),
+		}
+	}
+
+	log.Log().Info().Interface("nodes", nodes).Msg("node states")
+
+	b, err := json.Marshal(map[string]interface{}{"m": "node states", "nodes": nodes})
+	if err != nil {
+		return ctx, err
+	}
+
+	lo.LogEntryChan() <- host.NewContestLogEntry(b, false)
+
+	return ctx, nil
+}
@@ -0,0 +1,77 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+const HookNameDiagnostics = "diagnostics"
+
+// HookDiagnostics saves the diagnostics bundle into the test directory when
+// contest exits with error; the running nodes are quit by SIGQUIT before
+// collecting, so the goroutine dumps can be found in the log entries.
+func HookDiagnostics(ctx context.Context) (context.Context, error) {
+	var exitError error
+	if err := LoadExitErrorContextValue(ctx, &exitError); err != nil {
+		return ctx, err
+	}
+
+	if exitError == nil {
+		return ctx, nil
+	}
+
+	var logDir string
+	switch err := config.LoadLogDirContextValue(ctx, &logDir); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return ctx, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil && !errors.Is(err, util.ContextValueNotFoundError) {
+		return ctx, err
+	}
+
+	var mg *host.Mongodb
+	if err := host.LoadMongodbContextValue(ctx, &mg); err != nil && !errors.Is(err, util.ContextValueNotFoundError) {
+		return ctx, err
+	}
+
+	var vars *config.Vars
+	if err := config.LoadVarsContextValue(ctx, &vars); err != nil && !errors.Is(err, util.ContextValueNotFoundError) {
+		return ctx, err
+	}
+
+	di := host.NewDiagnostics(hosts, mg, vars)
+	_ = di.SetLogging(log)
+
+	quit := di.Quit(ctx)
+	di.Collect(ctx, exitError)
+
+	f := filepath.Join(logDir, host.DiagnosticsFile)
+	if err := di.Save(f); err != nil {
+		log.Log().Error().Err(err).Msg("failed to save diagnostics")
+
+		return ctx, nil
+	}
+
+	log.Log().Info().Str("file", f).Int("quit_nodes", quit).Msg("diagnostics saved")
+	_, _ = fmt.Fprintf(os.Stderr, "= diagnostics: %s\n", f)
+
+	return ctx, nil
+}
@@ -0,0 +1,57 @@
+package cmds
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+const (
+	HookNameDockerEvents     = "docker_events"
+	HookNameStopDockerEvents = "stop_docker_events"
+)
+
+// HookDockerEvents starts to save the docker events of the contest
+// containers.
+func HookDockerEvents(ctx context.Context) (context.Context, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return ctx, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return ctx, err
+	}
+
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
+		return ctx, err
+	}
+
+	de := host.NewDockerEvents(hosts, lo)
+	_ = de.SetLogging(log)
+
+	if err := de.Start(); err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, host.ContextValueDockerEvents, de), nil
+}
+
+func HookStopDockerEvents(ctx context.Context) (context.Context, error) {
+	var de *host.DockerEvents
+	switch err := host.LoadDockerEventsContextValue(ctx, &de); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	return ctx, de.Stop()
+}
@@ -0,0 +1,106 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+const (
+	HookNameStatsSampler = "stats_sampler"
+	HookNameStopStats    = "stop_stats"
+)
+
+// HookStatsSampler starts to sample the resource usage of the node
+// containers.
+func HookStatsSampler(ctx context.Context) (context.Context, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return ctx, err
+	}
+
+	var design config.Design
+	if err := config.LoadDesignContextValue(ctx, &design); err != nil {
+		return ctx, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return ctx, err
+	}
+
+	var mg *host.Mongodb
+	if err := host.LoadMongodbContextValue(ctx, &mg); err != nil {
+		return ctx, err
+	}
+
+	ss := host.NewStatsSampler(hosts, mg, design.Stats.Interval)
+	_ = ss.SetLogging(log)
+
+	if err := ss.Start(); err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, host.ContextValueStatsSampler, ss), nil
+}
+
+// HookStopStats stops the stats sampler and reports the resource summary of
+// nodes.
+func HookStopStats(ctx context.Context) (context.Context, error) {
+	var ss *host.StatsSampler
+	switch err := host.LoadStatsSamplerContextValue(ctx, &ss); {
+	case errors.Is(err, util.ContextValueNotFoundError):
+		return ctx, nil
+	case err != nil:
+		return ctx, err
+	}
+
+	if err := ss.Stop(); err != nil {
+		return ctx, err
+	}
+
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return ctx, err
+	}
+
+	var lo *host.LogSaver
+	if err := host.LoadLogSaverContextValue(ctx, &lo); err != nil {
+		return ctx, err
+	}
+
+	summaries := ss.Summaries()
+	if len(summaries) < 1 {
+		return ctx, nil
+	}
+
+	nodes := map[string]interface{}{}
+	for i := range summaries {
+		s := summaries[i]
+
+		_, _ = fmt.Fprintf(os.Stderr,
+			"= resource summary, %q: samples=%d cpu_avg=%.1f%% cpu_peak=%.1f%% memory_avg=%.1fMiB memory_peak=%.1fMiB\n",
+			s.Node, s.Samples, s.CPUAvg, s.CPUPeak, float64(s.MemoryAvg)/(1<<20), float64(s.MemoryPeak)/(1<<20))
+
+		nodes[s.Node] = s.Map()
+	}
+
+	log.Log().Info().Interface("nodes", nodes).Msg("resource summary")
+
+	b, err := json.Marshal(map[string]interface{}{"m": "resource summary", "nodes": nodes})
+	if err != nil {
+		return ctx, err
+	}
+
+	lo.LogEntryChan() <- host.NewContestLogEntry(b, false)
+
+	return ctx, nil
+}
@@ -44,7 +44,11 @@ func ProcessConfig(ctx context.Context) (context.Context, error) {
 	var designYAML config.DesignYAML
 	if err := yaml.Unmarshal(configSource, &designYAML); err != nil {
 		return ctx, err
-	} else if de, err := designYAML.Merge(); err != nil {
+	}
+
+	designYAML.BaseDir = flags["DesignDir"].(string)
+
+	if de, err := designYAML.Merge(); err != nil {
 		return ctx, err
 	} else if err := de.IsValid(nil); err != nil {
 		return ctx, err
@@ -54,6 +58,13 @@ func ProcessConfig(ctx context.Context) (context.Context, error) {
 
 	log.Log().Info().Interface("design", design).Msg("design loaded")
 
+	traverseSequences(design.Sequences, "sequences", func(path string, sq config.DesignSequence) {
+		if len(sq.Macro) > 0 {
+			log.Log().Info().Str("path", path).Str("macro", sq.Macro).Interface("sequence", sq).
+				Msg("sequence expanded from macro")
+		}
+	})
+
 	if design.Skip {
 		return ctx, util.IgnoreError.Errorf("exit silently")
 	}
@@ -61,6 +72,28 @@ func ProcessConfig(ctx context.Context) (context.Context, error) {
 	return context.WithValue(ctx, config.ContextValueDesign, design), nil
 }
 
+// traverseSequences traverses all the sequences, including the sequences in
+// repeat, until and if; path is the location of sequence, like
+// "sequences[1].repeat[0]".
+func traverseSequences(sqs []config.DesignSequence, path string, callback func(string, config.DesignSequence)) {
+	for i := range sqs {
+		sq := sqs[i]
+		p := fmt.Sprintf("%s[%d]", path, i)
+
+		callback(p, sq)
+
+		switch {
+		case sq.Repeat != nil:
+			traverseSequences(sq.Repeat.Sequences, p+".repeat", callback)
+		case sq.Until != nil:
+			traverseSequences(sq.Until.Sequences, p+".until", callback)
+		case sq.If != nil:
+			traverseSequences(sq.If.Then, p+".then", callback)
+			traverseSequences(sq.If.Else, p+".else", callback)
+		}
+	}
+}
+
 func HookConfigStorage(ctx context.Context) (context.Context, error) {
 	var design config.Design
 	if err := config.LoadDesignContextValue(ctx, &design); err != nil {
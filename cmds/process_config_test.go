@@ -0,0 +1,63 @@
+package cmds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+)
+
+type testProcessConfig struct {
+	suite.Suite
+}
+
+func (t *testProcessConfig) TestTraverseSequences() {
+	sqs := []config.DesignSequence{
+		{Macro: "a"},
+		{Repeat: &config.DesignRepeat{Count: 2, Sequences: []config.DesignSequence{
+			{},
+			{Macro: "a/b"},
+		}}},
+		{Until: &config.DesignUntil{Sequences: []config.DesignSequence{{Macro: "c"}}}},
+		{If: &config.DesignIf{
+			Then: []config.DesignSequence{{Macro: "d"}},
+			Else: []config.DesignSequence{{If: &config.DesignIf{Then: []config.DesignSequence{{Macro: "e"}}}}},
+		}},
+	}
+
+	macros := map[string]string{}
+	var paths []string
+	traverseSequences(sqs, "sequences", func(path string, sq config.DesignSequence) {
+		paths = append(paths, path)
+
+		if len(sq.Macro) > 0 {
+			macros[path] = sq.Macro
+		}
+	})
+
+	t.Equal([]string{
+		"sequences[0]",
+		"sequences[1]",
+		"sequences[1].repeat[0]",
+		"sequences[1].repeat[1]",
+		"sequences[2]",
+		"sequences[2].until[0]",
+		"sequences[3]",
+		"sequences[3].then[0]",
+		"sequences[3].else[0]",
+		"sequences[3].else[0].then[0]",
+	}, paths)
+
+	t.Equal(map[string]string{
+		"sequences[0]":                 "a",
+		"sequences[1].repeat[1]":       "a/b",
+		"sequences[2].until[0]":        "c",
+		"sequences[3].then[0]":         "d",
+		"sequences[3].else[0].then[0]": "e",
+	}, macros)
+}
+
+func TestProcessConfig(t *testing.T) {
+	suite.Run(t, new(testProcessConfig))
+}
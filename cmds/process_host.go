@@ -49,6 +49,11 @@ func ProcessHosts(ctx context.Context) (context.Context, error) {
 		return ctx, err
 	}
 
+	var vars *config.Vars
+	if err := config.LoadVarsContextValue(ctx, &vars); err != nil {
+		return ctx, err
+	}
+
 	nodes := make([]string, len(design.NodeConfig))
 	var i int
 	for k := range design.NodeConfig {
@@ -58,8 +63,9 @@ func ProcessHosts(ctx context.Context) (context.Context, error) {
 
 	designHosts, selected := spreadNodes(design.Hosts, nodes)
 
-	hosts := host.NewHosts(lo)
+	hosts := host.NewHosts(lo, vars)
 	_ = hosts.SetLogging(log)
+	_ = hosts.NodeStates().SetLogging(log)
 
 	for i := range designHosts {
 		de := designHosts[i]
@@ -155,6 +161,25 @@ func HookCleanStoppedNodeContainers(ctx context.Context) (context.Context, error
 		return ctx, err
 	}
 
+	// NOTE the containers of the finished contests, which were not cleaned, are
+	// also removed; the running contests are not touched.
+	if err := hosts.TraverseHosts(func(h host.Host) (bool, error) {
+		tests, err := h.CleanStale(context.Background())
+		if err != nil {
+			log.Log().Warn().Err(err).Str("host", h.Host()).Msg("failed to clean stale containers")
+
+			return true, nil
+		}
+
+		if len(tests) > 0 {
+			log.Log().Warn().Str("host", h.Host()).Strs("tests", tests).Msg("stale containers cleaned")
+		}
+
+		return true, nil
+	}); err != nil {
+		return ctx, err
+	}
+
 	return ctx, nil
 }
 
@@ -28,7 +28,7 @@ func HookBase(ctx context.Context) (context.Context, error) {
 		return ctx, err
 	}
 
-	testName := config.ULID().String()
+	testName := config.NewTestName()
 
 	logDir := flags["LogDir"].(string)
 	if len(logDir) < 1 {
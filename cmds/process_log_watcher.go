@@ -55,16 +55,20 @@ func ProcessLogWatcher(ctx context.Context) (context.Context, error) {
 		return ctx, err
 	}
 
-	sqs := make([]*host.Sequence, len(design.Sequences))
-	for i := range design.Sequences {
-		sq, err := parseSequence(ctx, design.Sequences[i])
-		if err != nil {
-			return ctx, err
-		}
-		sqs[i] = sq
+	// NOTE the actions of the nodes, which are added by add-nodes, are loaded
+	// when they run
+	added := map[string]struct{}{}
+	if err := addedNodesFromSequences(design.Sequences, added); err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, ContextValueAddedNodes, added)
+
+	steps, err := parseSteps(ctx, design.Sequences)
+	if err != nil {
+		return ctx, err
 	}
 
-	lw, err := host.NewLogWatcher(mg, sqs, exitChan, vars)
+	lw, err := host.NewLogWatcher(mg, steps, exitChan, vars)
 	if err != nil {
 		return ctx, err
 	}
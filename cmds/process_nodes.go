@@ -3,6 +3,7 @@ package cmds
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/spikeekips/mitum/launch/pm"
@@ -60,15 +61,18 @@ func ProcessNodes(ctx context.Context) (context.Context, error) {
 		return ctx, errors.Wrap(err, "failed to generate nodes config")
 	}
 
+	if err := checkContestStorage(design, hosts); err != nil {
+		return ctx, err
+	}
+
 	if err := hosts.TraverseNodes(func(node *host.Node) (bool, error) {
 		vars.Set(fmt.Sprintf("Design.Node.%s", node.Alias()), node.ConfigMap())
 
-		err := saveNodeConfig(node.ConfigFile(), node.ConfigData(), nodesConfig[node.Alias()])
-		if err != nil {
+		if err := node.SaveConfig(nodesConfig[node.Alias()]); err != nil {
 			return false, err
 		}
 
-		err = createNodeLogFile(node.LogFile())
+		err := createNodeLogFile(node.LogFile())
 
 		return err == nil, err
 	}); err != nil {
@@ -79,3 +83,41 @@ func ProcessNodes(ctx context.Context) (context.Context, error) {
 
 	return context.WithValue(ctx, config.ContextValueVars, vars), nil
 }
+
+// checkContestStorage checks the contest storage is not the node storage; the
+// faults of node storage should not affect the contest logs.
+func checkContestStorage(design config.Design, hosts *host.Hosts) error {
+	contest := map[string]struct{}{}
+	for _, h := range design.Storage.Hosts {
+		contest[normalizeStorageHost(h)] = struct{}{}
+	}
+
+	return hosts.TraverseNodes(func(node *host.Node) (bool, error) {
+		uri, err := node.StorageURI()
+		if err != nil {
+			return false, err
+		}
+
+		cs, err := config.CheckMongodbURI(uri)
+		if err != nil {
+			return false, err
+		}
+
+		for _, h := range cs.Hosts {
+			if _, found := contest[normalizeStorageHost(h)]; found {
+				return false, errors.Errorf(
+					"contest storage, %q should be separated from node storage, %q", design.Storage.String(), uri)
+			}
+		}
+
+		return true, nil
+	})
+}
+
+func normalizeStorageHost(h string) string {
+	if !strings.Contains(h, ":") {
+		h += ":27017"
+	}
+
+	return strings.Replace(h, "localhost:", "127.0.0.1:", 1)
+}
@@ -0,0 +1,350 @@
+package cmds
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+	"gopkg.in/yaml.v3"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+var reconfigureNodesActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	return NewReconfigureNodesAction(ctx, design)
+}
+
+// ReconfigureNodesAction patches the node config of nodes and rewrites the
+// config files. The patch is the map or the template string of yaml, which is
+// merged into the node config; like the node design, the keys prefixed with
+// "_" are merged into the shared values. With "nodes-config", the nodes config
+// of every node is regenerated. The running nodes, whose config file is
+// changed, are restarted unless "restart" is false.
+type ReconfigureNodesAction struct {
+	*logging.Logging
+	ctx         context.Context
+	aliases     []string
+	patch       map[string]interface{}
+	template    string
+	nodesConfig bool
+	restart     bool
+	args        []string
+	lo          *host.LogSaver
+	vars        *config.Vars
+}
+
+func NewReconfigureNodesAction(ctx context.Context, design config.DesignAction) (*ReconfigureNodesAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return nil, err
+	}
+
+	ac := &ReconfigureNodesAction{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "reconfigure-nodes-action")
+		}),
+		ctx:     ctx,
+		restart: true,
+		args:    design.Args,
+	}
+
+	if err := ac.loadOptions(design, hosts); err != nil {
+		return nil, err
+	}
+
+	if err := host.LoadLogSaverContextValue(ctx, &ac.lo); err != nil {
+		return nil, err
+	}
+
+	if err := config.LoadVarsContextValue(ctx, &ac.vars); err != nil {
+		return nil, err
+	}
+
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (ac *ReconfigureNodesAction) loadOptions(design config.DesignAction, hosts *host.Hosts) error {
+	switch t := design.Extra["patch"].(type) {
+	case nil:
+	case map[string]interface{}:
+		ac.patch = t
+	case string:
+		ac.template = t
+	default:
+		return errors.Errorf("patch should be map or string, not %T", t)
+	}
+
+	switch b, found, err := findBoolFromDesign(design, "nodes-config"); {
+	case err != nil:
+		return err
+	case found:
+		ac.nodesConfig = b
+	}
+
+	switch b, found, err := findBoolFromDesign(design, "restart"); {
+	case err != nil:
+		return err
+	case found:
+		ac.restart = b
+	}
+
+	if len(ac.patch) < 1 && len(ac.template) < 1 && !ac.nodesConfig {
+		return errors.Errorf("empty patch for reconfigure-nodes")
+	}
+
+	aliases, err := findNodesFromDesign(design)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := filterNodes(hosts, aliases)
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		ac.aliases = append(ac.aliases, nodes[i].Alias())
+	}
+	sort.Strings(ac.aliases)
+
+	return nil
+}
+
+func (*ReconfigureNodesAction) Name() string {
+	return "reconfigure-nodes"
+}
+
+func (ac *ReconfigureNodesAction) Run(ctx context.Context) error {
+	var design config.Design
+	if err := config.LoadDesignContextValue(ac.ctx, &design); err != nil {
+		return err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ac.ctx, &hosts); err != nil {
+		return err
+	}
+
+	allNodes, err := filterNodes(hosts, nil)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := filterNodes(hosts, ac.aliases)
+	if err != nil {
+		return err
+	}
+
+	olds := map[string][]byte{}
+	for i := range allNodes {
+		olds[allNodes[i].Alias()] = allNodes[i].ConfigData()
+	}
+
+	diffs := map[string][]config.MapDiff{}
+	for i := range nodes {
+		node := nodes[i]
+
+		d, err := ac.patchNode(node)
+		if err != nil {
+			return errors.Wrapf(err, "failed to patch node, %q", node.Alias())
+		}
+
+		diffs[node.Alias()] = d
+
+		ac.vars.Set(fmt.Sprintf("Design.Node.%s", node.Alias()), node.ConfigMap())
+	}
+
+	targets := nodes
+	if ac.nodesConfig {
+		targets = allNodes
+	}
+
+	changed, err := ac.saveConfigs(design, allNodes, targets, olds)
+	if err != nil {
+		return err
+	}
+
+	var restarted []string
+	if ac.restart && len(changed) > 0 {
+		i, err := ac.restartNodes(ctx, changed)
+		if err != nil {
+			return err
+		}
+
+		restarted = i
+	}
+
+	for i := range targets {
+		alias := targets[i].Alias()
+		if _, found := diffs[alias]; !found && !changed[alias] {
+			continue
+		}
+
+		ac.saveReconfigured(alias, diffs[alias], changed[alias], restarted)
+	}
+
+	return nil
+}
+
+func (ac *ReconfigureNodesAction) patchNode(node *host.Node) ([]config.MapDiff, error) {
+	patch := ac.patch
+	if len(ac.template) > 0 {
+		vars := ac.vars.Clone(map[string]interface{}{
+			"Self": map[string]interface{}{
+				"Alias": node.Alias(),
+				"Host":  node.Host().Host(),
+			},
+		})
+
+		b, err := config.CompileTemplate(ac.template, vars)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := yaml.Unmarshal(b, &patch); err != nil {
+			return nil, errors.Wrap(err, "invalid patch")
+		}
+	}
+
+	if len(patch) < 1 {
+		return nil, nil
+	}
+
+	return node.Patch(patch)
+}
+
+// saveConfigs rewrites the config files of targets, which are changed; the
+// nodes config is regenerated from the shared values of all nodes if
+// "nodes-config" is set.
+func (ac *ReconfigureNodesAction) saveConfigs(
+	design config.Design, allNodes, targets []*host.Node, olds map[string][]byte,
+) (map[string]bool, error) {
+	shared := map[string]interface{}{}
+	for i := range allNodes {
+		if j, found := allNodes[i].Shared()["nodes-config"]; found {
+			shared[allNodes[i].Alias()] = j
+		}
+	}
+
+	changed := map[string]bool{}
+	for i := range targets {
+		node := targets[i]
+
+		nodesConfig := node.NodesConfig()
+		if ac.nodesConfig {
+			b, err := renderNodesConfig(design.NodesConfig, ac.vars, node.Alias(), shared)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to generate nodes config")
+			}
+
+			nodesConfig = b
+		}
+
+		if bytes.Equal(olds[node.Alias()], node.ConfigData()) && bytes.Equal(nodesConfig, node.NodesConfig()) {
+			continue
+		}
+
+		if err := node.SaveConfig(nodesConfig); err != nil {
+			return nil, err
+		}
+
+		changed[node.Alias()] = true
+	}
+
+	return changed, nil
+}
+
+func (ac *ReconfigureNodesAction) restartNodes(ctx context.Context, changed map[string]bool) ([]string, error) {
+	var aliases []string
+	for alias := range changed {
+		aliases = append(aliases, alias)
+	}
+
+	b, err := NewBaseNodesAction(ac.ctx, ac.Name(), aliases, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := filterRunningContainers(ctx, b.nodes, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var running []string
+	for alias := range ids {
+		if len(ids[alias]) > 0 {
+			running = append(running, alias)
+		}
+	}
+
+	if len(running) < 1 {
+		return nil, nil
+	}
+
+	sort.Strings(running)
+
+	if a, err := NewStopNodesAction(ac.ctx, running); err != nil {
+		return nil, err
+	} else if err := a.Run(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to stop nodes")
+	}
+
+	if a, err := NewStartNodesAction(ac.ctx, running, ac.args); err != nil {
+		return nil, err
+	} else if err := a.Run(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to start nodes")
+	}
+
+	ac.Log().Debug().Strs("nodes", running).Msg("reconfigured nodes restarted")
+
+	return running, nil
+}
+
+func (ac *ReconfigureNodesAction) saveReconfigured(alias string, diffs []config.MapDiff, changed bool, restarted []string) {
+	var isRestarted bool
+	for i := range restarted {
+		if restarted[i] == alias {
+			isRestarted = true
+
+			break
+		}
+	}
+
+	ac.Log().Debug().Str("node", alias).Interface("diff", diffs).Bool("restarted", isRestarted).
+		Msg("node reconfigured")
+
+	if e, err := host.NewNodeLogEntryWithInterface(alias, map[string]interface{}{
+		"m":            "node reconfigured",
+		"diff":         diffs,
+		"config_saved": changed,
+		"restarted":    isRestarted,
+	}, false); err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+	} else {
+		ac.lo.LogEntryChan() <- e
+	}
+}
+
+func (ac *ReconfigureNodesAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":         ac.Name(),
+		"nodes":        ac.aliases,
+		"patch":        ac.patch,
+		"template":     ac.template,
+		"nodes-config": ac.nodesConfig,
+		"restart":      ac.restart,
+	})
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -41,8 +43,11 @@ func init() {
 		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameBase, HookBase),
 		pm.NewHook(pm.HookPrefixPost, ProcessNameConfig, HookNameVars, HookVars),
 		pm.NewHook(pm.HookPrefixPost, ProcessNameConfig, HookNameConfigStorage, HookConfigStorage),
+		pm.NewHook(pm.HookPrefixPost, ProcessNameLogSaver, HookNameBackgroundActions, HookBackgroundActions),
 		pm.NewHook(pm.HookPrefixPost, ProcessNameHosts,
 			HookNameCleanStoppedNodeContainers, HookCleanStoppedNodeContainers),
+		pm.NewHook(pm.HookPrefixPost, ProcessNameHosts, HookNameDockerEvents, HookDockerEvents),
+		pm.NewHook(pm.HookPrefixPost, ProcessNameHosts, HookNameStatsSampler, HookStatsSampler),
 		pm.NewHook(pm.HookPrefixPost, ProcessNameNodes, HookNameContestReady, HookContestReady),
 	}
 
@@ -66,18 +71,39 @@ func init() {
 type RunCommand struct {
 	*logging.Logging
 	*mitumcmds.LogFlags
-	RunnerFile     string             `arg:"" name:"runner-file" type:"existingfile"`
-	Design         mitumcmds.FileLoad `arg:"" name:"contest design file" help:"contest design file"`
-	ContestLogDir  string             `name:"contest-log-dir" help:"contest logs directory"`
-	Force          bool               `name:"force" help:"kill the still running node containers"`
-	CleanAfter     bool               `name:"clean-after" help:"clean node containers after exit"`
-	ExitAfter      time.Duration      `name:"exit-after" help:"exit contest"`
-	ConfigOnly     bool               `name:"config-only" help:"exit after config"`
+	RunnerFile     string        `arg:"" name:"runner-file" type:"existingfile"`
+	Design         DesignFile    `arg:"" name:"contest design file" help:"contest design file"`
+	ContestLogDir  string        `name:"contest-log-dir" help:"contest logs directory"`
+	Force          bool          `name:"force" help:"kill the still running node containers"`
+	CleanAfter     bool          `name:"clean-after" help:"clean node containers after exit"`
+	ExitAfter      time.Duration `name:"exit-after" help:"exit contest"`
+	ConfigOnly     bool          `name:"config-only" help:"exit after config"`
+	Seed           int64         `name:"seed" help:"random seed for keys, ulids, test name and random choices"`
 	version        util.Version
 	runProcesses   *pm.Processes
 	closeProcesses *pm.Processes
 }
 
+// DesignFile loads the design file and keeps the directory of it; the relative
+// paths in design are from the directory. With "-", design is loaded from
+// stdin and the directory is the current directory.
+type DesignFile struct {
+	mitumcmds.FileLoad
+	dir string
+}
+
+func (v *DesignFile) UnmarshalText(b []byte) error {
+	if err := v.FileLoad.UnmarshalText(b); err != nil {
+		return err
+	}
+
+	if s := strings.TrimSpace(string(b)); s != "-" {
+		v.dir = filepath.Dir(filepath.Clean(s))
+	}
+
+	return nil
+}
+
 func NewRunCommand() (RunCommand, error) {
 	cmd := RunCommand{
 		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
@@ -111,6 +137,14 @@ func (cmd *RunCommand) Run(version util.Version) error {
 	}
 	cmd.version = version
 
+	if cmd.Seed == 0 {
+		cmd.Seed = config.NewSeed()
+	}
+	config.SetSeed(cmd.Seed)
+
+	cmd.Log().Info().Int64("seed", cmd.Seed).Msg("seed set")
+	_, _ = fmt.Fprintf(os.Stderr, "= seed: %d\n", cmd.Seed)
+
 	var exitError error
 	if err := cmd.run(); err != nil {
 		if errors.Is(err, util.IgnoreError) {
@@ -128,6 +162,8 @@ func (cmd *RunCommand) Run(version util.Version) error {
 		return nil
 	}
 
+	exitError = cmd.checkExpect(exitError)
+
 	if err := cmd.close(cmd.runProcesses.Context(), exitError); err != nil {
 		if exitError == nil {
 			exitError = err
@@ -187,6 +223,51 @@ func (cmd *RunCommand) run() error {
 	}
 }
 
+func (cmd *RunCommand) checkExpect(exitError error) error {
+	var design config.Design
+	if err := config.LoadDesignContextValue(cmd.runProcesses.Context(), &design); err != nil {
+		return exitError
+	}
+
+	if design.Expect.IsEmpty() {
+		return exitError
+	}
+
+	err := checkExpect(design.Expect, exitError, cmd.nodeExitCode)
+	if err != nil {
+		cmd.Log().Error().Err(err).Interface("expect", design.Expect).Msg("contest exited unexpectedly")
+
+		return err
+	}
+
+	cmd.Log().Info().Interface("expect", design.Expect).AnErr("exit_error", exitError).
+		Msg("contest exited as expected")
+
+	return nil
+}
+
+// expectExitWait is the time to wait for the exit of node, which wrote to
+// stderr before exiting.
+var expectExitWait = time.Second * 3
+
+// nodeExitCode waits for the exit of the current run of node and returns the
+// exit code from the node states.
+func (cmd *RunCommand) nodeExitCode(alias string) (int64, bool) {
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(cmd.runProcesses.Context(), &hosts); err != nil {
+		return 0, false
+	}
+
+	select {
+	case <-time.After(expectExitWait):
+		return 0, false
+	case <-hosts.NodeStates().Exited(alias):
+		r, _ := hosts.NodeStates().State(alias)
+
+		return r.ExitCode, r.State == config.NodeStateExited
+	}
+}
+
 func (cmd *RunCommand) close(ctx context.Context, exitError error) error {
 	ctx = context.WithValue(ctx, ContextValueExitError, exitError)
 
@@ -201,11 +282,13 @@ func (cmd *RunCommand) processRun(ctx context.Context) error {
 
 	ctx = context.WithValue(ctx, config.ContextValueLog, cmd.Logging)
 	ctx = context.WithValue(ctx, config.ContextValueFlags, map[string]interface{}{
-		"Design":     []byte(cmd.Design),
+		"Design":     []byte(cmd.Design.FileLoad),
+		"DesignDir":  cmd.Design.dir,
 		"LogDir":     cmd.ContestLogDir,
 		"RunnerFile": cmd.RunnerFile,
 		"Force":      cmd.Force,
 		"CleanAfter": cmd.CleanAfter,
+		"Seed":       cmd.Seed,
 	})
 
 	cmd.runProcesses.SetContext(ctx)
@@ -258,6 +341,13 @@ func (cmd *RunCommand) prepareProcesses() {
 	closeProcesses := pm.NewProcesses()
 
 	closeHooks := []pm.Hook{
+		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameStopChaos, HookStopChaos),
+		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameStopLoads, HookStopLoads),
+		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameStopStats, HookStopStats),
+		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameConsistency, HookConsistency),
+		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameNodeStates, HookNodeStates),
+		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameDiagnostics, HookDiagnostics),
+		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameStopDockerEvents, HookStopDockerEvents),
 		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameStopLogHandlers, HookStopLogHandlers),
 		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameCloseHosts, HookCloseHosts),
 		pm.NewHook(pm.HookPrefixPost, pm.INITProcess, HookNameCloseMongodb, HookCloseMongodb),
@@ -0,0 +1,29 @@
+package cmds
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type testDesignFile struct {
+	suite.Suite
+}
+
+func (t *testDesignFile) TestDir() {
+	dir := filepath.Join(t.T().TempDir(), "designs")
+	t.NoError(ioutil.WriteFile(dir+".yml", []byte("sequences:"), 0o600))
+
+	var v DesignFile
+	t.NoError(v.UnmarshalText([]byte(dir + ".yml")))
+	t.Equal("sequences:", string(v.FileLoad))
+	t.Equal(filepath.Dir(dir), v.dir)
+
+	t.Error(v.UnmarshalText([]byte(dir + "-findme.yml")))
+}
+
+func TestDesignFile(t *testing.T) {
+	suite.Run(t, new(testDesignFile))
+}
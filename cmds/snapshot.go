@@ -0,0 +1,255 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+var snapshotNodesActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	nodes, err := findNodesFromDesign(design)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := findSnapshotFromDesign(design)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSnapshotNodesAction(ctx, nodes, name)
+}
+
+var restoreNodesActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	nodes, err := findNodesFromDesign(design)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := findSnapshotFromDesign(design)
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := findSnapshotFromNodeFromDesign(design)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRestoreNodesAction(ctx, nodes, name, from)
+}
+
+var resetNodesActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	switch nodes, err := findNodesFromDesign(design); {
+	case err != nil:
+		return nil, err
+	case len(nodes) < 1:
+		return nil, errors.Errorf("empty nodes")
+	default:
+		return NewResetNodesAction(ctx, nodes)
+	}
+}
+
+// SnapshotNodesAction archives the data directory and database of nodes
+// under the snapshot name. The nodes should be stopped.
+type SnapshotNodesAction struct {
+	*BaseNodesAction
+	snapshot string
+}
+
+func NewSnapshotNodesAction(ctx context.Context, aliases []string, name string) (*SnapshotNodesAction, error) {
+	b, err := NewBaseNodesAction(ctx, "snapshot-nodes", aliases, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SnapshotNodesAction{BaseNodesAction: b, snapshot: name}, nil
+}
+
+func (ac *SnapshotNodesAction) Run(ctx context.Context) error {
+	if err := checkNodesStopped(ctx, ac.nodes); err != nil {
+		return err
+	}
+
+	return host.RunWaitGroup(len(ac.nodes), func(i int) error {
+		node := ac.nodes[i]
+
+		meta, err := host.SnapshotNode(ctx, node, ac.snapshot)
+		ac.saveSnapshotLog(node.Alias(), "node snapshot created", ac.snapshot, meta, err)
+
+		return errors.Wrapf(err, "failed to snapshot node, %q", node.Alias())
+	})
+}
+
+func (ac *SnapshotNodesAction) MarshalJSON() ([]byte, error) {
+	m := ac.Map()
+	m["snapshot"] = ac.snapshot
+
+	return json.Marshal(m)
+}
+
+// RestoreNodesAction restores the snapshot into nodes. If from is given,
+// the snapshot of from node is restored into all the nodes; it clones the
+// node. The nodes should be stopped.
+type RestoreNodesAction struct {
+	*BaseNodesAction
+	snapshot string
+	from     string
+}
+
+func NewRestoreNodesAction(ctx context.Context, aliases []string, name, from string) (*RestoreNodesAction, error) {
+	if len(from) > 0 {
+		var hosts *host.Hosts
+		if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+			return nil, err
+		}
+
+		if !hosts.NodeExists(from) {
+			return nil, errors.Errorf("unknown from node, %q", from)
+		}
+	}
+
+	b, err := NewBaseNodesAction(ctx, "restore-nodes", aliases, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RestoreNodesAction{BaseNodesAction: b, snapshot: name, from: from}, nil
+}
+
+func (ac *RestoreNodesAction) Run(ctx context.Context) error {
+	if err := checkNodesStopped(ctx, ac.nodes); err != nil {
+		return err
+	}
+
+	return host.RunWaitGroup(len(ac.nodes), func(i int) error {
+		node := ac.nodes[i]
+
+		from := ac.from
+		if len(from) < 1 {
+			from = node.Alias()
+		}
+
+		meta, err := host.RestoreNode(ctx, node, ac.snapshot, from)
+		ac.saveSnapshotLog(node.Alias(), "node snapshot restored", ac.snapshot, meta, err)
+
+		return errors.Wrapf(err, "failed to restore node, %q", node.Alias())
+	})
+}
+
+func (ac *RestoreNodesAction) MarshalJSON() ([]byte, error) {
+	m := ac.Map()
+	m["snapshot"] = ac.snapshot
+	m["from"] = ac.from
+
+	return json.Marshal(m)
+}
+
+// ResetNodesAction wipes the data directory and database of nodes. The nodes
+// should be stopped.
+type ResetNodesAction struct {
+	*BaseNodesAction
+}
+
+func NewResetNodesAction(ctx context.Context, aliases []string) (*ResetNodesAction, error) {
+	b, err := NewBaseNodesAction(ctx, "reset-nodes", aliases, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResetNodesAction{BaseNodesAction: b}, nil
+}
+
+func (ac *ResetNodesAction) Run(ctx context.Context) error {
+	if err := checkNodesStopped(ctx, ac.nodes); err != nil {
+		return err
+	}
+
+	return host.RunWaitGroup(len(ac.nodes), func(i int) error {
+		node := ac.nodes[i]
+
+		err := host.ResetNode(ctx, node)
+		ac.saveSnapshotLog(node.Alias(), "node reset", "", host.SnapshotMeta{}, err)
+
+		return errors.Wrapf(err, "failed to reset node, %q", node.Alias())
+	})
+}
+
+func (ac *ResetNodesAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ac.Map())
+}
+
+func (ac *BaseNodesAction) saveSnapshotLog(alias, m, snapshot string, meta host.SnapshotMeta, err error) {
+	e := map[string]interface{}{"m": m}
+
+	if len(snapshot) > 0 {
+		e["snapshot"] = snapshot
+		e["from"] = meta.Node
+		e["database"] = meta.Database
+	}
+
+	if err != nil {
+		e["error"] = err.Error()
+	}
+
+	if l, err := host.NewNodeLogEntryWithInterface(alias, e, err != nil); err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+	} else {
+		ac.lo.LogEntryChan() <- l
+	}
+}
+
+func findSnapshotFromDesign(design config.DesignAction) (string, error) {
+	switch s, _, err := findStringFromDesign(design, "snapshot"); {
+	case err != nil:
+		return "", err
+	case len(s) < 1:
+		return "", errors.Errorf("empty snapshot")
+	default:
+		return s, checkSnapshotPath("snapshot name", s)
+	}
+}
+
+// findSnapshotFromNodeFromDesign finds the from node of restore; empty from
+// means the node itself.
+func findSnapshotFromNodeFromDesign(design config.DesignAction) (string, error) {
+	switch s, _, err := findStringFromDesign(design, "from"); {
+	case err != nil:
+		return "", err
+	case len(s) < 1:
+		return "", nil
+	default:
+		return s, checkSnapshotPath("from node", s)
+	}
+}
+
+// checkSnapshotPath checks the snapshot name and from node; they are used as
+// directory name under the snapshot directory.
+func checkSnapshotPath(name, s string) error {
+	if s == "." || strings.Contains(s, "..") || strings.ContainsAny(s, `/\`) {
+		return errors.Errorf("invalid %s, %q; path separator and \"..\" are not allowed", name, s)
+	}
+
+	return nil
+}
+
+// checkNodesStopped checks the nodes are not running.
+func checkNodesStopped(ctx context.Context, nodes []*host.Node) error {
+	ids, err := filterRunningContainers(ctx, nodes, false)
+	if err != nil {
+		return err
+	}
+
+	for alias := range ids {
+		if len(ids[alias]) > 0 {
+			return errors.Errorf("node, %q is still running", alias)
+		}
+	}
+
+	return nil
+}
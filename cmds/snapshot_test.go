@@ -0,0 +1,98 @@
+package cmds
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+type testSnapshot struct {
+	suite.Suite
+}
+
+func (t *testSnapshot) TestFindSnapshot() {
+	cases := []struct {
+		name     string
+		snapshot interface{}
+		err      string
+	}{
+		{name: "valid", snapshot: "before-fork"},
+		{name: "dots in name", snapshot: "v0.1"},
+		{name: "empty", snapshot: "", err: "empty snapshot"},
+		{name: "dot", snapshot: ".", err: "invalid snapshot name"},
+		{name: "parent", snapshot: "..", err: "invalid snapshot name"},
+		{name: "parent in name", snapshot: "a..b", err: "invalid snapshot name"},
+		{name: "slash", snapshot: "a/b", err: "invalid snapshot name"},
+		{name: "escape", snapshot: "../../etc", err: "invalid snapshot name"},
+		{name: "absolute", snapshot: "/tmp/a", err: "invalid snapshot name"},
+		{name: "backslash", snapshot: `a\b`, err: "invalid snapshot name"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			design := config.DesignAction{Name: "snapshot", Extra: map[string]interface{}{"snapshot": c.snapshot}}
+
+			s, err := findSnapshotFromDesign(design)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.snapshot, s)
+		})
+	}
+}
+
+func (t *testSnapshot) TestFindSnapshotFromNode() {
+	cases := []struct {
+		name string
+		from interface{}
+		err  string
+	}{
+		{name: "valid", from: "no0"},
+		{name: "empty", from: ""},
+		{name: "not string", from: 1, err: "not string type"},
+		{name: "parent", from: "..", err: "invalid from node"},
+		{name: "escape", from: "../../etc", err: "invalid from node"},
+		{name: "absolute", from: "/tmp/a", err: "invalid from node"},
+		{name: "backslash", from: `a\b`, err: "invalid from node"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			design := config.DesignAction{Name: "restore", Extra: map[string]interface{}{"from": c.from}}
+
+			s, err := findSnapshotFromNodeFromDesign(design)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.from, s)
+		})
+	}
+}
+
+func (t *testSnapshot) TestRestoreUnknownFromNode() {
+	ctx := context.WithValue(context.Background(), host.ContextValueHosts, host.NewHosts(nil, nil))
+
+	_, err := NewRestoreNodesAction(ctx, []string{"no0"}, "before-fork", "no9")
+	t.Error(err)
+	t.Contains(err.Error(), `unknown from node, "no9"`)
+}
+
+func TestSnapshot(t *testing.T) {
+	suite.Run(t, new(testSnapshot))
+}
@@ -0,0 +1,249 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	dockerClient "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/contest/host"
+)
+
+const (
+	StorageFaultStop       = "stop"
+	StorageFaultStart      = "start"
+	StorageFaultPause      = "pause"
+	StorageFaultUnpause    = "unpause"
+	StorageFaultRestart    = "restart"
+	StorageFaultThrottle   = "throttle"
+	StorageFaultUnthrottle = "unthrottle"
+)
+
+var (
+	defaultStorageThrottleCPUs = 0.1
+	storageFaults              = []string{
+		StorageFaultStop, StorageFaultStart, StorageFaultPause, StorageFaultUnpause,
+		StorageFaultRestart, StorageFaultThrottle, StorageFaultUnthrottle,
+	}
+)
+
+var storageFaultActionFunc = func(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	return NewStorageFaultAction(ctx, design)
+}
+
+// StorageFaultAction injects the fault to the mongodb containers, which nodes
+// use as storage. If storage is shared by nodes, selecting any node affects
+// all the nodes.
+type StorageFaultAction struct {
+	*logging.Logging
+	fault   string
+	aliases []string
+	cpus    float64
+	targets map[ /* container id */ string]*dockerClient.Client
+	lo      *host.LogSaver
+}
+
+func NewStorageFaultAction(ctx context.Context, design config.DesignAction) (*StorageFaultAction, error) {
+	var log *logging.Logging
+	if err := config.LoadLogContextValue(ctx, &log); err != nil {
+		return nil, err
+	}
+
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return nil, err
+	}
+
+	ac := &StorageFaultAction{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "storage-fault-action")
+		}),
+		cpus:    defaultStorageThrottleCPUs,
+		targets: map[string]*dockerClient.Client{},
+	}
+
+	if err := ac.loadOptions(design, hosts); err != nil {
+		return nil, err
+	}
+
+	if err := host.LoadLogSaverContextValue(ctx, &ac.lo); err != nil {
+		return nil, err
+	}
+
+	_ = ac.SetLogging(log)
+
+	return ac, nil
+}
+
+func (ac *StorageFaultAction) loadOptions(design config.DesignAction, hosts *host.Hosts) error {
+	switch s, found, err := findStringFromDesign(design, "fault"); {
+	case err != nil:
+		return err
+	case !found || len(s) < 1:
+		return errors.Errorf("empty storage fault")
+	default:
+		var known bool
+		for _, f := range storageFaults {
+			if s == f {
+				known = true
+
+				break
+			}
+		}
+
+		if !known {
+			return errors.Errorf("unknown storage fault, %q", s)
+		}
+
+		ac.fault = s
+	}
+
+	switch i, found, err := findFloatFromDesign(design, "cpus"); {
+	case err != nil:
+		return err
+	case found:
+		if i <= 0 {
+			return errors.Errorf("cpus should be over zero, %v", i)
+		}
+
+		ac.cpus = i
+	}
+
+	aliases, err := findNodesFromDesign(design)
+	if err != nil {
+		return err
+	}
+
+	nodes, err := filterNodes(hosts, aliases)
+	if err != nil {
+		return err
+	}
+
+	for i := range nodes {
+		node := nodes[i]
+
+		id := node.Host().MongodbContainerID(node.Alias())
+		if len(id) < 1 {
+			return errors.Errorf("mongodb container of node, %q not found", node.Alias())
+		}
+
+		ac.aliases = append(ac.aliases, node.Alias())
+		ac.targets[id] = node.Host().DockerClient()
+	}
+
+	sort.Strings(ac.aliases)
+
+	if len(ac.targets) < 1 {
+		return errors.Errorf("empty nodes for storage fault")
+	}
+
+	return nil
+}
+
+func (*StorageFaultAction) Name() string {
+	return "storage-fault"
+}
+
+func (ac *StorageFaultAction) Run(ctx context.Context) error {
+	ids := make([]string, len(ac.targets))
+	var i int
+	for id := range ac.targets {
+		ids[i] = id
+		i++
+	}
+
+	err := host.RunWaitGroup(len(ids), func(i int) error {
+		err := ac.inject(ctx, ac.targets[ids[i]], ids[i], ac.fault)
+		ac.saveFault(ids[i], err)
+
+		return err
+	})
+	if err != nil {
+		ac.Log().Error().Err(err).Str("fault", ac.fault).Msg("failed to inject storage fault")
+
+		return err
+	}
+
+	ac.Log().Debug().Str("fault", ac.fault).Strs("containers", ids).Msg("storage fault injected")
+
+	return nil
+}
+
+func (ac *StorageFaultAction) inject(ctx context.Context, client *dockerClient.Client, id, fault string) error {
+	switch fault {
+	case StorageFaultStop:
+		return client.ContainerStop(ctx, id, nil)
+	case StorageFaultStart:
+		return client.ContainerStart(ctx, id, dockerTypes.ContainerStartOptions{})
+	case StorageFaultPause:
+		return client.ContainerPause(ctx, id)
+	case StorageFaultUnpause:
+		return client.ContainerUnpause(ctx, id)
+	case StorageFaultRestart:
+		if err := ac.inject(ctx, client, id, StorageFaultStop); err != nil {
+			return err
+		}
+
+		return ac.inject(ctx, client, id, StorageFaultStart)
+	case StorageFaultThrottle:
+		return ac.updateCPUQuota(ctx, client, id, int64(ac.cpus*100000))
+	case StorageFaultUnthrottle:
+		return ac.updateCPUQuota(ctx, client, id, -1)
+	default:
+		return errors.Errorf("unknown storage fault, %q", fault)
+	}
+}
+
+// updateCPUQuota updates the cpu quota of container by the default period,
+// 100ms; -1 quota removes the limit.
+func (*StorageFaultAction) updateCPUQuota(
+	ctx context.Context, client *dockerClient.Client, id string, quota int64,
+) error {
+	_, err := client.ContainerUpdate(ctx, id, container.UpdateConfig{
+		Resources: container.Resources{CPUPeriod: 100000, CPUQuota: quota},
+	})
+
+	return err
+}
+
+func (ac *StorageFaultAction) saveFault(id string, faultErr error) {
+	e := map[string]interface{}{
+		"m":            "storage fault",
+		"fault":        ac.fault,
+		"nodes":        ac.aliases,
+		"container_id": id,
+	}
+
+	if ac.fault == StorageFaultThrottle {
+		e["cpus"] = ac.cpus
+	}
+
+	if faultErr != nil {
+		e["error"] = faultErr.Error()
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		ac.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	ac.lo.LogEntryChan() <- host.NewContestLogEntry(b, faultErr != nil)
+}
+
+func (ac *StorageFaultAction) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"name":  ac.Name(),
+		"fault": ac.fault,
+		"nodes": ac.aliases,
+		"cpus":  ac.cpus,
+	})
+}
@@ -74,7 +74,7 @@ func filterRunningContainers(ctx context.Context, nodes []*host.Node, running bo
 			continue
 		}
 
-		if err := host.TraverseContainers(ctx, h.DockerClient(), func(c dockerTypes.Container) (bool, error) {
+		if err := host.TraverseContainers(ctx, h.DockerClient(), h.TestName(), func(c dockerTypes.Container) (bool, error) {
 			if c.Labels[host.ContainerLabelNodeType] != host.ContainerLabelNodeRunType {
 				return true, nil
 			}
@@ -194,13 +194,18 @@ func newHost(ctx context.Context, de config.DesignHost, nodeDesigns map[string]s
 		return nil, err
 	}
 
+	var testName string
+	if err := config.LoadTestNameContextValue(ctx, &testName); err != nil {
+		return nil, err
+	}
+
 	runnerFile := flags["RunnerFile"].(string)
 
 	var h host.Host
 	if de.Local { //nolint // TODO implement RemoteHost
-		h = host.NewLocalHost(de, vars, nodeDesigns, runnerFile, logDir)
+		h = host.NewLocalHost(de, vars, nodeDesigns, runnerFile, logDir, testName)
 	} else {
-		h = host.NewLocalHost(de, vars, nodeDesigns, runnerFile, logDir)
+		h = host.NewLocalHost(de, vars, nodeDesigns, runnerFile, logDir, testName)
 	}
 
 	if l, ok := h.(logging.SetLogging); ok {
@@ -210,13 +215,85 @@ func newHost(ctx context.Context, de config.DesignHost, nodeDesigns map[string]s
 	return h, h.Connect()
 }
 
+// findLocalHost returns the local host; at this time, only local host is
+// allowed to exec command.
+func findLocalHost(ctx context.Context) (host.Host, error) {
+	var hosts *host.Hosts
+	if err := host.LoadHostsContextValue(ctx, &hosts); err != nil {
+		return nil, err
+	}
+
+	var local host.Host
+	if err := hosts.TraverseHosts(func(h host.Host) (bool, error) {
+		if i, ok := h.(*host.LocalHost); ok {
+			local = i
+
+			return false, nil
+		}
+
+		return true, nil
+	}); err != nil {
+		return nil, err
+	} else if local == nil {
+		return nil, errors.Errorf("local host not found")
+	}
+
+	return local, nil
+}
+
+func parseSteps(ctx context.Context, designs []config.DesignSequence) ([]host.Step, error) {
+	steps := make([]host.Step, len(designs))
+	for i := range designs {
+		st, err := parseStep(ctx, designs[i])
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = st
+	}
+
+	return steps, nil
+}
+
+func parseStep(ctx context.Context, design config.DesignSequence) (host.Step, error) {
+	switch {
+	case design.Repeat != nil:
+		steps, err := parseSteps(ctx, design.Repeat.Sequences)
+		if err != nil {
+			return nil, err
+		}
+
+		return host.NewRepeatStep(design.Repeat.Count, design.Repeat.Var, steps), nil
+	case design.Until != nil:
+		condition, err := host.NewCondition(ctx, design.Until.Condition)
+		if err != nil {
+			return nil, err
+		}
+
+		steps, err := parseSteps(ctx, design.Until.Sequences)
+		if err != nil {
+			return nil, err
+		}
+
+		return host.NewUntilStep(condition, design.Until.Max, design.Until.Var, steps), nil
+	case design.If != nil:
+		then, err := parseSteps(ctx, design.If.Then)
+		if err != nil {
+			return nil, err
+		}
+
+		els, err := parseSteps(ctx, design.If.Else)
+		if err != nil {
+			return nil, err
+		}
+
+		return host.NewIfStep(design.If.Expr, then, els), nil
+	default:
+		return parseSequence(ctx, design)
+	}
+}
+
 func parseSequence(ctx context.Context, design config.DesignSequence) (*host.Sequence, error) {
-	condition, err := host.NewCondition(
-		ctx,
-		design.Condition.Query,
-		design.Condition.Storage,
-		design.Condition.Col,
-	)
+	condition, err := host.NewCondition(ctx, design.Condition)
 	if err != nil {
 		return nil, err
 	}
@@ -233,6 +310,17 @@ func parseSequence(ctx context.Context, design config.DesignSequence) (*host.Seq
 }
 
 func parseSequenceAction(ctx context.Context, design config.DesignAction) (host.Action, error) {
+	switch lazy, err := isLazyAction(ctx, design); {
+	case err != nil:
+		return nil, err
+	case lazy:
+		return lazyAction{ctx: ctx, design: design}, nil
+	default:
+		return loadSequenceAction(ctx, design)
+	}
+}
+
+func loadSequenceAction(ctx context.Context, design config.DesignAction) (host.Action, error) {
 	var log *logging.Logging
 	if err := config.LoadLogContextValue(ctx, &log); err != nil {
 		return nil, err
@@ -283,29 +371,14 @@ func generateNodesConfig(ctx context.Context, design config.Design, hosts *host.
 
 	configs := map[string][]byte{}
 	if err := hosts.TraverseNodes(func(node *host.Node) (bool, error) {
-		s := shared["nodes-config"].(map[string]interface{})
-		ns := map[string]interface{}{}
-		for k := range s {
-			if k == node.Alias() {
-				continue
-			}
-
-			ns[k] = s[k]
-		}
-
-		nodesVars := vars.Clone(map[string]interface{}{
-			"NodesConfig": ns,
-			"Alias":       node.Alias(),
-		})
+		s, _ := shared["nodes-config"].(map[string]interface{})
 
-		var bf bytes.Buffer
-		if t, err := template.New("nodes-config").Funcs(nodesVars.FuncMap()).Parse(design.NodesConfig); err != nil {
-			return false, err
-		} else if err := t.Execute(&bf, nodesVars.Map()); err != nil {
+		b, err := renderNodesConfig(design.NodesConfig, vars, node.Alias(), s)
+		if err != nil {
 			return false, err
 		}
 
-		configs[node.Alias()] = bf.Bytes()
+		configs[node.Alias()] = b
 
 		return true, nil
 	}); err != nil {
@@ -315,12 +388,33 @@ func generateNodesConfig(ctx context.Context, design config.Design, hosts *host.
 	return configs, nil
 }
 
-func saveNodeConfig(f string, configData, nodesConfig []byte) error {
-	c := configData
-	c = append(c, '\n')
-	c = append(c, nodesConfig...)
+// renderNodesConfig renders the nodes-config of node with the "nodes-config"
+// of the other nodes.
+func renderNodesConfig(
+	nodesConfig string, vars *config.Vars, alias string, shared map[string]interface{},
+) ([]byte, error) {
+	ns := map[string]interface{}{}
+	for k := range shared {
+		if k == alias {
+			continue
+		}
+
+		ns[k] = shared[k]
+	}
+
+	nodesVars := vars.Clone(map[string]interface{}{
+		"NodesConfig": ns,
+		"Alias":       alias,
+	})
+
+	var bf bytes.Buffer
+	if t, err := template.New("nodes-config").Funcs(nodesVars.FuncMap()).Parse(nodesConfig); err != nil {
+		return nil, err
+	} else if err := t.Execute(&bf, nodesVars.Map()); err != nil {
+		return nil, err
+	}
 
-	return ioutil.WriteFile(f, c, 0o600)
+	return bf.Bytes(), nil
 }
 
 func createNodeLogFile(f string) error {
@@ -4,6 +4,7 @@ import (
 	"net"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -31,6 +32,7 @@ func init() {
 type Design struct {
 	StorageString    string
 	Storage          connstring.ConnString `json:"-"`
+	StoragePerNode   bool                  // NOTE if true, each node has it's own mongodb container
 	Hosts            []DesignHost
 	NodeConfig       map[ /* node alias */ string]string
 	CommonNodeConfig string
@@ -38,6 +40,11 @@ type Design struct {
 	Sequences        []DesignSequence
 	ExitOnError      bool
 	Skip             bool
+	Expect           DesignExpect
+	Consistency      DesignConsistency
+	Disks            map[ /* node alias */ string]DesignDisk
+	Clocks           map[ /* node alias */ string]DesignClock
+	Stats            DesignStats
 }
 
 func (de *Design) IsValid([]byte) error {
@@ -59,13 +66,45 @@ func (de *Design) IsValid([]byte) error {
 		}
 	}
 
+	for alias := range de.Disks {
+		disk := de.Disks[alias]
+		if err := disk.IsValid(nil); err != nil {
+			return errors.Wrapf(err, "invalid disk of node, %q", alias)
+		}
+		de.Disks[alias] = disk
+	}
+
+	for alias := range de.Clocks {
+		if err := de.Clocks[alias].IsValid(nil); err != nil {
+			return errors.Wrapf(err, "invalid clock of node, %q", alias)
+		}
+	}
+
+	for i := range de.Hosts {
+		de.Hosts[i].StoragePerNode = de.StoragePerNode
+		de.Hosts[i].Disks = de.Disks
+		de.Hosts[i].Clocks = de.Clocks
+	}
+
 	for i := range de.Sequences {
 		if err := de.Sequences[i].IsValid(nil); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	if err := isValidAfterRefs(de.Sequences); err != nil {
+		return err
+	}
+
+	if err := de.Consistency.IsValid(nil); err != nil {
+		return err
+	}
+
+	if err := de.Stats.IsValid(nil); err != nil {
+		return err
+	}
+
+	return de.Expect.IsValid(nil)
 }
 
 func (de *Design) SetDatabase(s string) error {
@@ -84,15 +123,24 @@ func (de *Design) SetDatabase(s string) error {
 	return nil
 }
 
+// DefaultPortRange is the range of the host ports, which are published for
+// the containers.
+var DefaultPortRange = [2]uint16{1025, 32767}
+
 type DesignHost struct {
-	Weight uint // if 0 weight, this host will be ignored.
-	Host   string
-	Local  bool
-	SSH    DesignHostSSH
+	Weight    uint // if 0 weight, this host will be ignored.
+	Host      string
+	Local     bool
+	SSH       DesignHostSSH
+	PortRange [2]uint16
+	// NOTE StoragePerNode and Disks are set by Design
+	StoragePerNode bool
+	Disks          map[string]DesignDisk
+	Clocks         map[string]DesignClock
 }
 
 func defaultLocalDesignHost() DesignHost {
-	return DesignHost{Weight: 1, Local: true}
+	return DesignHost{Weight: 1, Local: true, PortRange: DefaultPortRange}
 }
 
 func (de *DesignHost) IsValid([]byte) error {
@@ -116,9 +164,36 @@ func (de *DesignHost) IsValid([]byte) error {
 		return errors.Errorf("host is missing")
 	}
 
+	switch {
+	case de.PortRange == [2]uint16{}:
+		de.PortRange = DefaultPortRange
+	case de.PortRange[0] < 1 || de.PortRange[0] > de.PortRange[1]:
+		return errors.Errorf("invalid port range, %d-%d", de.PortRange[0], de.PortRange[1])
+	}
+
 	return nil
 }
 
+// ParsePortRange parses the port range string, like "20000-29999".
+func ParsePortRange(s string) ([2]uint16, error) {
+	var r [2]uint16
+
+	ss := strings.SplitN(strings.TrimSpace(s), "-", 2)
+	if len(ss) != 2 {
+		return r, errors.Errorf("invalid port range, %q; <from>-<to>", s)
+	}
+
+	for i := range ss {
+		p, err := strconv.ParseUint(strings.TrimSpace(ss[i]), 10, 16)
+		if err != nil {
+			return r, errors.Wrapf(err, "invalid port range, %q", s)
+		}
+		r[i] = uint16(p)
+	}
+
+	return r, nil
+}
+
 type DesignHostSSH struct {
 	Host string
 	User string
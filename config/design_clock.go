@@ -0,0 +1,29 @@
+package config
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DesignClock skews the time of node. Offset is added to the real time and
+// Drift is the rate of skew by the elapsed real time; 0.01 drift makes the
+// clock 1% faster. The skewed time is served to node by the fake time server of
+// contest, so contest should run as root to listen udp 123 port.
+type DesignClock struct {
+	Offset time.Duration
+	Drift  float64
+}
+
+func (de DesignClock) IsValid([]byte) error {
+	if de.Drift <= -1 {
+		return errors.Errorf("drift should be over -1, %v", de.Drift)
+	}
+
+	return nil
+}
+
+// OffsetAt returns the effective offset after the elapsed real time.
+func (de DesignClock) OffsetAt(elapsed time.Duration) time.Duration {
+	return de.Offset + time.Duration(float64(elapsed)*de.Drift)
+}
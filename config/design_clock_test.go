@@ -0,0 +1,127 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+)
+
+type testDesignClock struct {
+	suite.Suite
+}
+
+func (t *testDesignClock) TestYAML() {
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(`
+node-config:
+  n0:
+  n1:
+  n2:
+clocks:
+  n0:
+    offset: 3s
+  n1:
+    offset: -1m
+    drift: 0.01
+`), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(2, len(design.Clocks))
+	t.Equal(DesignClock{Offset: time.Second * 3}, design.Clocks["n0"])
+	t.Equal(DesignClock{Offset: time.Minute * -1, Drift: 0.01}, design.Clocks["n1"])
+
+	_, found := design.Clocks["n2"]
+	t.False(found)
+
+	t.Equal(design.Clocks, design.Hosts[0].Clocks)
+}
+
+func (t *testDesignClock) TestYAMLCommon() {
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(`
+node-config:
+  n0:
+  n1:
+clocks:
+  common:
+    drift: 0.5
+  n1:
+    offset: 1s
+`), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(DesignClock{Drift: 0.5}, design.Clocks["n0"])
+	t.Equal(DesignClock{Offset: time.Second, Drift: 0.5}, design.Clocks["n1"])
+}
+
+func (t *testDesignClock) TestYAMLInvalid() {
+	cases := []struct {
+		s   string
+		err string
+	}{
+		{s: `
+node-config:
+  n0:
+clocks:
+  n1:
+    offset: 1s
+`, err: "clock of unknown node"},
+		{s: `
+node-config:
+  n0:
+clocks:
+  n0:
+    offset: 1
+`, err: "invalid clock offset"},
+		{s: `
+node-config:
+  n0:
+clocks:
+  n0:
+    drift: -1
+`, err: "drift should be over -1"},
+	}
+
+	for i, c := range cases {
+		var dy DesignYAML
+		t.NoError(yaml.Unmarshal([]byte(c.s), &dy), "%d", i)
+
+		design, err := dy.Merge()
+		if err == nil {
+			err = design.IsValid(nil)
+		}
+
+		t.Error(err, "%d", i)
+		t.Contains(err.Error(), c.err, "%d", i)
+	}
+}
+
+func (t *testDesignClock) TestParseDesignClock() {
+	base := DesignClock{Offset: time.Second, Drift: 0.1}
+
+	de, err := ParseDesignClock(map[string]interface{}{"offset": "-2s"}, base)
+	t.NoError(err)
+	t.Equal(DesignClock{Offset: time.Second * -2, Drift: 0.1}, de)
+
+	_, err = ParseDesignClock(map[string]interface{}{"drift": -3.0}, base)
+	t.Error(err)
+}
+
+func (t *testDesignClock) TestOffsetAt() {
+	de := DesignClock{Offset: time.Second, Drift: 0.01}
+
+	t.Equal(time.Second, de.OffsetAt(0))
+	t.Equal(time.Second*2, de.OffsetAt(time.Second*100))
+}
+
+func TestDesignClock(t *testing.T) {
+	suite.Run(t, new(testDesignClock))
+}
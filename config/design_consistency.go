@@ -0,0 +1,52 @@
+package config
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	DefaultConsistencyCol    = "manifest"
+	DefaultConsistencyFields = []string{"hash"}
+)
+
+// DesignConsistency compares the records of node storages height by height.
+// Storage is the template string, which is compiled with ".Self.Alias" for
+// each node. Fields are the JSONPaths of record to be compared.
+type DesignConsistency struct {
+	Nodes      []string // NOTE empty nodes means all nodes
+	Storage    string   // NOTE empty storage means the storage of each node
+	Col        string
+	Fields     []string
+	Height     int64 // NOTE condition waits until all nodes reach height
+	AtShutdown bool
+}
+
+func (de *DesignConsistency) IsValid([]byte) error {
+	if len(de.Col) < 1 {
+		de.Col = DefaultConsistencyCol
+	}
+
+	if len(de.Fields) < 1 {
+		de.Fields = DefaultConsistencyFields
+	}
+
+	for i := range de.Fields {
+		if _, err := parseJSONPath(de.Fields[i]); err != nil {
+			return err
+		}
+	}
+
+	if de.Height < 0 {
+		return errors.Errorf("negative consistency height, %d", de.Height)
+	}
+
+	founds := map[string]struct{}{}
+	for i := range de.Nodes {
+		if _, found := founds[de.Nodes[i]]; found {
+			return errors.Errorf("duplicated node, %q in consistency", de.Nodes[i])
+		}
+		founds[de.Nodes[i]] = struct{}{}
+	}
+
+	return nil
+}
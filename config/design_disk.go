@@ -0,0 +1,81 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DiskTypeBind  = "bind"
+	DiskTypeTmpfs = "tmpfs"
+	DiskTypeLoop  = "loop"
+)
+
+var byteSizeUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+}
+
+// DesignDisk is the backend of the node data directory, "/data". "bind" is
+// the plain directory of host; "tmpfs" and "loop" are size-limited and loop
+// device is mapped by device-mapper, so io errors can be injected.
+type DesignDisk struct {
+	Type string
+	Size uint64
+}
+
+func (de *DesignDisk) IsValid([]byte) error {
+	switch de.Type {
+	case "":
+		de.Type = DiskTypeBind
+	case DiskTypeBind, DiskTypeTmpfs, DiskTypeLoop:
+	default:
+		return errors.Errorf("unknown disk type, %q", de.Type)
+	}
+
+	if de.Type != DiskTypeBind && de.Size < 1 {
+		return errors.Errorf("empty size for %s disk", de.Type)
+	}
+
+	return nil
+}
+
+// ParseByteSize parses the human readable size string like "64MiB"; units
+// are based on 1024.
+func ParseByteSize(s string) (uint64, error) {
+	t := strings.ToLower(strings.TrimSpace(s))
+
+	i := strings.IndexFunc(t, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+
+	num, unit := t, ""
+	if i >= 0 {
+		num, unit = t[:i], strings.TrimSpace(t[i:])
+	}
+
+	m, found := byteSizeUnits[unit]
+	if !found {
+		return 0, errors.Errorf("unknown size unit, %q", s)
+	}
+
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid size, %q", s)
+	} else if f < 0 {
+		return 0, errors.Errorf("negative size, %q", s)
+	}
+
+	return uint64(f * float64(m)), nil
+}
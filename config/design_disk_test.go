@@ -0,0 +1,116 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+)
+
+type testDesignDisk struct {
+	suite.Suite
+}
+
+func (t *testDesignDisk) TestParseByteSize() {
+	cases := []struct {
+		s   string
+		n   uint64
+		err string
+	}{
+		{s: "100", n: 100},
+		{s: "100b", n: 100},
+		{s: "64KiB", n: 64 << 10},
+		{s: "64 MB", n: 64 << 20},
+		{s: "1.5g", n: 3 << 29},
+		{s: "1TB", err: "unknown size unit"},
+		{s: "MiB", err: "invalid size"},
+		{s: "-1MiB", err: "unknown size unit"},
+	}
+
+	for i, c := range cases {
+		n, err := ParseByteSize(c.s)
+		if len(c.err) > 0 {
+			t.Error(err, "%d: %q", i, c.s)
+			t.Contains(err.Error(), c.err, "%d: %q", i, c.s)
+
+			continue
+		}
+
+		t.NoError(err, "%d: %q", i, c.s)
+		t.Equal(c.n, n, "%d: %q", i, c.s)
+	}
+}
+
+func (t *testDesignDisk) TestYAML() {
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(`
+node-config:
+  n0:
+  n1:
+  n2:
+disks:
+  common:
+    type: tmpfs
+    size: 64MiB
+  n1:
+    type: loop
+  n2:
+    type: bind
+`), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(DesignDisk{Type: DiskTypeTmpfs, Size: 64 << 20}, design.Disks["n0"])
+	t.Equal(DesignDisk{Type: DiskTypeLoop, Size: 64 << 20}, design.Disks["n1"])
+	t.Equal(DesignDisk{Type: DiskTypeBind, Size: 64 << 20}, design.Disks["n2"])
+	t.Equal(design.Disks, design.Hosts[0].Disks)
+}
+
+func (t *testDesignDisk) TestYAMLInvalid() {
+	cases := []struct {
+		s   string
+		err string
+	}{
+		{s: `
+node-config:
+  n0:
+disks:
+  n1:
+    type: tmpfs
+    size: 1MiB
+`, err: "disk of unknown node"},
+		{s: `
+node-config:
+  n0:
+disks:
+  n0:
+    type: tmpfs
+`, err: "empty size"},
+		{s: `
+node-config:
+  n0:
+disks:
+  n0:
+    type: nfs
+`, err: "unknown disk type"},
+	}
+
+	for i, c := range cases {
+		var dy DesignYAML
+		t.NoError(yaml.Unmarshal([]byte(c.s), &dy), "%d", i)
+
+		design, err := dy.Merge()
+		if err == nil {
+			err = design.IsValid(nil)
+		}
+
+		t.Error(err, "%d", i)
+		t.Contains(err.Error(), c.err, "%d", i)
+	}
+}
+
+func TestDesignDisk(t *testing.T) {
+	suite.Run(t, new(testDesignDisk))
+}
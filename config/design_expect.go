@@ -0,0 +1,104 @@
+package config
+
+import (
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+type DesignExpectType string
+
+const (
+	ExpectKillType     DesignExpectType = "kill"
+	ExpectStderrType   DesignExpectType = "stderr"
+	ExpectExitCodeType DesignExpectType = "exit-code"
+)
+
+func (t DesignExpectType) IsValid([]byte) error {
+	switch t {
+	case ExpectKillType, ExpectStderrType, ExpectExitCodeType:
+		return nil
+	default:
+		return errors.Errorf("unknown expect type, %q", t)
+	}
+}
+
+// DesignExpect describes the expected exit of contest. If it is not empty,
+// contest succeeds only when it exits by the expected cause.
+type DesignExpect struct {
+	Type    DesignExpectType
+	Node    string // NOTE if empty, any node
+	Pattern string
+	Code    int64
+	re      *regexp.Regexp
+}
+
+func (de DesignExpect) IsEmpty() bool {
+	return len(de.Type) < 1
+}
+
+func (de *DesignExpect) IsValid([]byte) error {
+	if de.IsEmpty() {
+		return nil
+	}
+
+	if err := de.Type.IsValid(nil); err != nil {
+		return err
+	}
+
+	if de.Type == ExpectExitCodeType {
+		return nil
+	}
+
+	re, err := regexp.Compile(de.Pattern)
+	if err != nil {
+		return errors.Wrapf(err, "invalid expect pattern, %q", de.Pattern)
+	}
+	de.re = re
+
+	return nil
+}
+
+// MatchKill checks the error message of kill action.
+func (de DesignExpect) MatchKill(msg string) bool {
+	if de.Type != ExpectKillType {
+		return false
+	}
+
+	return de.matchPattern(msg)
+}
+
+// MatchStderr checks the stderr output of node.
+func (de DesignExpect) MatchStderr(node string, msg []byte) bool {
+	if de.Type != ExpectStderrType || !de.matchNode(node) {
+		return false
+	}
+
+	return de.matchPattern(string(msg))
+}
+
+// MatchExitCode checks the exit code of node.
+func (de DesignExpect) MatchExitCode(node string, code int64) bool {
+	if de.Type != ExpectExitCodeType || !de.matchNode(node) {
+		return false
+	}
+
+	return de.Code == code
+}
+
+func (de DesignExpect) matchNode(node string) bool {
+	return len(de.Node) < 1 || de.Node == node
+}
+
+func (de DesignExpect) matchPattern(s string) bool {
+	re := de.re
+	if re == nil {
+		i, err := regexp.Compile(de.Pattern)
+		if err != nil {
+			return false
+		}
+		re = i
+	}
+
+	return re.MatchString(s)
+}
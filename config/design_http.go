@@ -0,0 +1,83 @@
+package config
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	DefaultHTTPTimeout  = time.Second * 10
+	DefaultHTTPInterval = time.Second
+)
+
+// DesignHTTP is the http request to node endpoint. URL, header values and
+// body are template strings. Status and JSON are the expectations of
+// response; JSON is the map of JSONPath and it's expected value.
+type DesignHTTP struct {
+	Method   string
+	URL      string
+	Headers  map[string]string
+	Body     string
+	Insecure bool
+	Timeout  time.Duration
+	Interval time.Duration // NOTE interval of polling for http condition
+	Status   int
+	JSON     map[string]interface{}
+}
+
+func (de *DesignHTTP) IsValid([]byte) error {
+	if len(de.URL) < 1 {
+		return errors.Errorf("empty http url")
+	}
+
+	if len(de.Method) < 1 {
+		de.Method = http.MethodGet
+	}
+	de.Method = strings.ToUpper(de.Method)
+
+	if de.Timeout < 1 {
+		de.Timeout = DefaultHTTPTimeout
+	}
+
+	if de.Interval < 1 {
+		de.Interval = DefaultHTTPInterval
+	}
+
+	for k := range de.JSON {
+		if _, err := parseJSONPath(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckResponse checks the status and json body of response with the
+// expectations.
+func (de DesignHTTP) CheckResponse(status int, body interface{}) error {
+	switch {
+	case de.Status > 0:
+		if status != de.Status {
+			return errors.Errorf("unexpected http status, %d; expected %d", status, de.Status)
+		}
+	case status < 200 || status > 299:
+		return errors.Errorf("unexpected http status, %d", status)
+	}
+
+	for k := range de.JSON {
+		i, found, err := LookupJSONPath(body, k)
+		switch {
+		case err != nil:
+			return err
+		case !found:
+			return errors.Errorf("json path, %q not found in http response", k)
+		case !EqualJSONValue(i, de.JSON[k]):
+			return errors.Errorf("json path, %q not matched; %v != %v", k, i, de.JSON[k])
+		}
+	}
+
+	return nil
+}
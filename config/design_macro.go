@@ -0,0 +1,223 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+var maxMacroDepth = 10
+
+// DesignMacroYAML is the named, parameterized sequences. The parameter, which
+// has nil default value, is required. In sequences, "${<parameter>}" is
+// replaced by the given parameter value.
+type DesignMacroYAML struct {
+	Params    map[string]interface{} `yaml:"params,omitempty"`
+	Sequences []interface{}          `yaml:"sequences"`
+}
+
+type designMacrosFileYAML struct {
+	Macros map[string]*DesignMacroYAML `yaml:"macros"`
+}
+
+// loadMacrosFiles loads the macros files; the relative path is from the base
+// directory.
+func loadMacrosFiles(base string, files []string) (map[string]*DesignMacroYAML, error) {
+	macros := map[string]*DesignMacroYAML{}
+
+	for _, f := range files {
+		f = strings.TrimSpace(f)
+		if !filepath.IsAbs(f) {
+			f = filepath.Join(base, f)
+		}
+
+		b, err := ioutil.ReadFile(filepath.Clean(f))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read macros file, %q", f)
+		}
+
+		var m designMacrosFileYAML
+		if err := yaml.Unmarshal(b, &m); err != nil {
+			return nil, errors.Wrapf(err, "invalid macros file, %q", f)
+		}
+
+		for name := range m.Macros {
+			macros[name] = m.Macros[name]
+		}
+	}
+
+	return macros, nil
+}
+
+func expandMacros(
+	sqs []*DesignSequenceYAML, macros map[string]*DesignMacroYAML, depth int,
+) ([]*DesignSequenceYAML, error) {
+	if depth > maxMacroDepth {
+		return nil, errors.Errorf("too deep macro expansion; over %d", maxMacroDepth)
+	}
+
+	var expanded []*DesignSequenceYAML // nolint:prealloc
+	for i := range sqs {
+		sq := sqs[i]
+		if sq == nil {
+			expanded = append(expanded, sq)
+
+			continue
+		}
+
+		if sq.Use == nil {
+			if err := sq.expandNestedMacros(macros, depth); err != nil {
+				return nil, err
+			}
+
+			expanded = append(expanded, sq)
+
+			continue
+		}
+
+		name := strings.TrimSpace(*sq.Use)
+		if !sq.isOnlyUse() {
+			return nil, errors.Errorf("use, %q can not be combined with the other sequence fields", name)
+		}
+
+		nsqs, err := expandMacro(name, sq.With, macros)
+		if err != nil {
+			return nil, err
+		}
+
+		nsqs, err = expandMacros(nsqs, macros, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		for j := range nsqs {
+			switch {
+			case nsqs[j] == nil:
+			case len(nsqs[j].macro) < 1:
+				nsqs[j].macro = name
+			default:
+				nsqs[j].macro = name + "/" + nsqs[j].macro // NOTE nested macro
+			}
+		}
+
+		expanded = append(expanded, nsqs...)
+	}
+
+	return expanded, nil
+}
+
+func (de *DesignSequenceYAML) expandNestedMacros(macros map[string]*DesignMacroYAML, depth int) error {
+	for _, l := range []*[]*DesignSequenceYAML{&de.Then, &de.Else, &de.Sequences} {
+		if len(*l) < 1 {
+			continue
+		}
+
+		i, err := expandMacros(*l, macros, depth)
+		if err != nil {
+			return err
+		}
+		*l = i
+	}
+
+	return nil
+}
+
+func (de DesignSequenceYAML) isOnlyUse() bool {
+	return de.Condition == nil && de.Action == nil && de.Register == nil &&
+		de.Repeat == nil && de.Until == nil && de.If == nil &&
+		de.Then == nil && de.Else == nil && de.Sequences == nil &&
+		de.Var == nil && de.Max == nil
+}
+
+func expandMacro(
+	name string, with map[string]interface{}, macros map[string]*DesignMacroYAML,
+) ([]*DesignSequenceYAML, error) {
+	macro, found := macros[name]
+	if !found || macro == nil {
+		return nil, errors.Errorf("unknown macro, %q", name)
+	}
+
+	params := map[string]interface{}{}
+	for k := range macro.Params {
+		params[k] = macro.Params[k]
+	}
+
+	for k := range with {
+		if _, found := macro.Params[k]; !found {
+			return nil, errors.Errorf("unknown parameter, %q for macro, %q", k, name)
+		}
+
+		params[k] = with[k]
+	}
+
+	for k := range params {
+		if params[k] == nil {
+			return nil, errors.Errorf("missing parameter, %q for macro, %q", k, name)
+		}
+	}
+
+	b, err := yaml.Marshal(replaceMacroParams(macro.Sequences, params))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to expand macro, %q", name)
+	}
+
+	var sqs []*DesignSequenceYAML
+	if err := yaml.Unmarshal(b, &sqs); err != nil {
+		return nil, errors.Wrapf(err, "invalid sequences in macro, %q", name)
+	}
+
+	return sqs, nil
+}
+
+// replaceMacroParams replaces "${<parameter>}" in string values. If the whole
+// string is one parameter, it is replaced by the parameter value itself, so
+// list or map parameter can be used.
+func replaceMacroParams(v interface{}, params map[string]interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		s := strings.TrimSpace(t)
+		for k := range params {
+			if s == fmt.Sprintf("${%s}", k) {
+				return params[k]
+			}
+		}
+
+		for k := range params {
+			t = strings.ReplaceAll(t, fmt.Sprintf("${%s}", k), macroParamString(params[k]))
+		}
+
+		return t
+	case []interface{}:
+		n := make([]interface{}, len(t))
+		for i := range t {
+			n[i] = replaceMacroParams(t[i], params)
+		}
+
+		return n
+	case map[string]interface{}:
+		n := map[string]interface{}{}
+		for k := range t {
+			n[k] = replaceMacroParams(t[k], params)
+		}
+
+		return n
+	default:
+		return v
+	}
+}
+
+func macroParamString(v interface{}) string {
+	switch v.(type) {
+	case []interface{}, map[string]interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			return string(b)
+		}
+	}
+
+	return fmt.Sprintf("%v", v)
+}
@@ -1,6 +1,11 @@
 package config
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/pkg/errors"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
@@ -10,9 +15,28 @@ type DesignSequence struct {
 	Condition DesignCondition
 	Action    DesignAction
 	Register  DesignRegister
+	Repeat    *DesignRepeat
+	Until     *DesignUntil
+	If        *DesignIf
+	Macro     string // NOTE name of macro, which this sequence is expanded from; nested macros, like "a/b"
+}
+
+// IsControl returns true when the sequence is a control block, like repeat,
+// until or if, which contains nested sequences instead of condition.
+func (de DesignSequence) IsControl() bool {
+	return de.Repeat != nil || de.Until != nil || de.If != nil
 }
 
 func (de *DesignSequence) IsValid([]byte) error {
+	return de.isValid(nil)
+}
+
+// isValid checks the sequence with the vars of the enclosing loops.
+func (de *DesignSequence) isValid(loopVars []string) error {
+	if de.IsControl() {
+		return de.isValidControl(loopVars)
+	}
+
 	if err := de.Condition.IsValid(nil); err != nil {
 		return err
 	} else if err := de.Action.IsValid(nil); err != nil {
@@ -24,6 +48,175 @@ func (de *DesignSequence) IsValid([]byte) error {
 	return nil
 }
 
+func (de *DesignSequence) isValidControl(loopVars []string) error {
+	var n int
+	for _, b := range []bool{de.Repeat != nil, de.Until != nil, de.If != nil} {
+		if b {
+			n++
+		}
+	}
+
+	switch {
+	case n > 1:
+		return errors.Errorf("only one of repeat, until and if is allowed in one sequence")
+	case len(de.Condition.Query) > 0:
+		return errors.Errorf("condition is not allowed with repeat, until and if")
+	case !de.Action.IsEmpty():
+		return errors.Errorf("action is not allowed with repeat, until and if")
+	case !de.Register.IsEmpty():
+		return errors.Errorf("register is not allowed with repeat, until and if")
+	}
+
+	switch {
+	case de.Repeat != nil:
+		return de.Repeat.isValid(loopVars)
+	case de.Until != nil:
+		return de.Until.isValid(loopVars)
+	default:
+		return de.If.isValid(loopVars)
+	}
+}
+
+// NOTE the default vars are for the outermost loop; the nested loops have the
+// depth suffix, like "Loop.Index1", so the inner loop does not overwrite the
+// var of the enclosing loop.
+var (
+	DefaultRepeatVar = "Loop.Index"
+	DefaultUntilVar  = "Loop.Index"
+)
+
+func defaultLoopVar(v string, depth int) string {
+	if depth < 1 {
+		return v
+	}
+
+	return fmt.Sprintf("%s%d", v, depth)
+}
+
+func isValidLoopVar(v string, loopVars []string) error {
+	for i := range loopVars {
+		if loopVars[i] == v {
+			return errors.Errorf("var, %q already used by the enclosing loop", v)
+		}
+	}
+
+	return nil
+}
+
+// DesignRepeat runs the nested sequences Count times. The current iteration,
+// starting from 0, is set to Var.
+type DesignRepeat struct {
+	Count     uint
+	Var       string
+	Sequences []DesignSequence
+}
+
+func (de *DesignRepeat) IsValid([]byte) error {
+	return de.isValid(nil)
+}
+
+func (de *DesignRepeat) isValid(loopVars []string) error {
+	if de.Count < 1 {
+		return errors.Errorf("repeat count should be over 0")
+	}
+
+	if len(de.Var) < 1 {
+		de.Var = defaultLoopVar(DefaultRepeatVar, len(loopVars))
+	}
+
+	if err := isValidLoopVar(de.Var, loopVars); err != nil {
+		return errors.Wrap(err, "invalid repeat var")
+	}
+
+	if len(de.Sequences) < 1 {
+		return errors.Errorf("empty repeat sequences")
+	}
+
+	return isValidNestedSequences(de.Sequences, append(loopVars[:len(loopVars):len(loopVars)], de.Var))
+}
+
+// DesignUntil runs the nested sequences repeatedly until Condition is matched.
+// Condition is checked once after every iteration, so the time based
+// conditions, duration and quiet are not allowed. If Max is not 0, the loop
+// fails after Max iterations.
+type DesignUntil struct {
+	Condition DesignCondition
+	Max       uint
+	Var       string
+	Sequences []DesignSequence
+}
+
+func (de *DesignUntil) IsValid([]byte) error {
+	return de.isValid(nil)
+}
+
+func (de *DesignUntil) isValid(loopVars []string) error {
+	if err := de.Condition.IsValid(nil); err != nil {
+		return errors.Wrap(err, "invalid until condition")
+	}
+
+	if de.Condition.Duration > 0 || de.Condition.Quiet > 0 {
+		return errors.Errorf("until condition can not have duration and quiet; it is checked once after every iteration")
+	}
+
+	if len(de.Var) < 1 {
+		de.Var = defaultLoopVar(DefaultUntilVar, len(loopVars))
+	}
+
+	if err := isValidLoopVar(de.Var, loopVars); err != nil {
+		return errors.Wrap(err, "invalid until var")
+	}
+
+	if len(de.Sequences) < 1 {
+		return errors.Errorf("empty until sequences")
+	}
+
+	return isValidNestedSequences(de.Sequences, append(loopVars[:len(loopVars):len(loopVars)], de.Var))
+}
+
+// DesignIf chooses the next sequences by Expr. Expr is the template string and
+// it is compiled when the if block is reached; "true" selects Then and "false"
+// or empty string selects Else.
+type DesignIf struct {
+	Expr string
+	Then []DesignSequence
+	Else []DesignSequence
+}
+
+func (de *DesignIf) IsValid([]byte) error {
+	return de.isValid(nil)
+}
+
+func (de *DesignIf) isValid(loopVars []string) error {
+	if len(de.Expr) < 1 {
+		return errors.Errorf("empty if expression")
+	}
+
+	if len(de.Then) < 1 && len(de.Else) < 1 {
+		return errors.Errorf("empty then and else sequences")
+	}
+
+	if err := isValidNestedSequences(de.Then, loopVars); err != nil {
+		return errors.Wrap(err, "invalid then sequences")
+	}
+
+	if err := isValidNestedSequences(de.Else, loopVars); err != nil {
+		return errors.Wrap(err, "invalid else sequences")
+	}
+
+	return nil
+}
+
+func isValidNestedSequences(sqs []DesignSequence, loopVars []string) error {
+	for i := range sqs {
+		if err := sqs[i].isValid(loopVars); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 type DesignAction struct {
 	Name  string
 	Args  []string
@@ -120,19 +313,158 @@ func ParseConditionQuery(s string) (bson.M, error) {
 	return q, nil
 }
 
+// ParseConditionPipeline parses the aggregation pipeline, the JSON array.
+func ParseConditionPipeline(s string) (bson.A, error) {
+	if len(s) < 1 {
+		return nil, errors.Errorf("empty condition pipeline")
+	}
+
+	b := []byte(s)
+	if IsTemplateCondition(s) {
+		b = reConditionString.ReplaceAll(b, []byte("1"))
+	}
+
+	var m bson.M
+	if err := bson.UnmarshalExtJSON(
+		[]byte(fmt.Sprintf(`{"pipeline": %s}`, string(b))), false, &m,
+	); err != nil {
+		return nil, errors.Wrap(err, "bad condition pipeline string")
+	}
+
+	p, ok := m["pipeline"].(bson.A)
+	if !ok {
+		return nil, errors.Errorf("condition pipeline should be array, %T", m["pipeline"])
+	}
+
+	return p, nil
+}
+
+// AfterPrevious makes the condition be matched after the last matched record.
+const AfterPrevious = "previous"
+
+// AfterConditionQuery combines the query with the ordering by _id; the
+// matched record should come after the record of refID. If within is not 0,
+// the matched record should come within the duration after the record of refID
+// and the deadline is returned.
+func AfterConditionQuery(q bson.M, refID string, within time.Duration) (bson.M, time.Time, error) {
+	t, err := ULIDTime(refID)
+	if err != nil {
+		return nil, time.Time{}, errors.Wrapf(err, "invalid reference _id, %q", refID)
+	}
+
+	r := bson.M{"$gt": refID}
+
+	var deadline time.Time
+	if within > 0 {
+		deadline = t.Add(within)
+		r["$lte"] = MaxULIDAt(deadline).String()
+	}
+
+	return bson.M{"$and": bson.A{q, bson.M{"_id": r}}}, deadline, nil
+}
+
 type DesignCondition struct {
 	Query   string
 	Storage string
 	Col     string
+	After   string
+	Within  time.Duration
+	// Duration makes the condition be matched after the duration since the
+	// previous sequence.
+	Duration time.Duration
+	// Quiet makes the condition be matched when no records, matched with
+	// Query, are found for the duration.
+	Quiet time.Duration
+	// HTTP makes the condition poll the http endpoint until the response is
+	// expected.
+	HTTP *DesignHTTP
+	// Consistency makes the condition be matched when all the nodes reach
+	// the height and their records are same.
+	Consistency *DesignConsistency
+	// Node makes the condition query the storage of node instead of Storage.
+	Node string
+	// Count makes the condition be matched when the number of records,
+	// matched with Query, reaches Count.
+	Count int64
+	// Aggregate is the aggregation pipeline; the condition is matched when
+	// pipeline returns record.
+	Aggregate string
+	// NodeState makes the condition be matched when all the nodes are in the
+	// given states; the state can have exit code like "exited:137".
+	NodeState map[string]string
+	// Resource makes the condition be matched when the last resource sample
+	// of node satisfies it, like "no0 memory > 1GiB".
+	Resource string
 }
 
 func (de *DesignCondition) IsValid([]byte) error {
-	if len(de.Query) < 1 {
-		return errors.Errorf("empty condition query")
-	} else if _, err := ParseConditionQuery(de.Query); err != nil {
+	if len(de.NodeState) > 0 {
+		return de.isValidNodeState()
+	}
+
+	if len(de.Resource) > 0 {
+		return de.isValidResource()
+	}
+
+	if de.Consistency != nil {
+		if len(de.Query) > 0 || de.Duration > 0 || de.Quiet > 0 || len(de.After) > 0 || de.HTTP != nil {
+			return errors.Errorf("consistency condition can not have query, duration, quiet, after and http")
+		}
+
+		return de.Consistency.IsValid(nil)
+	}
+
+	if de.HTTP != nil {
+		if len(de.Query) > 0 || de.Duration > 0 || de.Quiet > 0 || len(de.After) > 0 {
+			return errors.Errorf("http condition can not have query, duration, quiet and after")
+		}
+
+		return de.HTTP.IsValid(nil)
+	}
+
+	if de.Duration > 0 {
+		if len(de.Query) > 0 || de.Quiet > 0 || len(de.After) > 0 {
+			return errors.Errorf("duration condition can not have query, quiet and after")
+		}
+
+		return nil
+	}
+
+	if err := de.isValidNode(); err != nil {
 		return err
 	}
 
+	switch {
+	case len(de.Aggregate) > 0:
+		if len(de.Query) > 0 || de.Count > 0 || de.Quiet > 0 || len(de.After) > 0 {
+			return errors.Errorf("aggregate condition can not have query, count, quiet and after")
+		}
+
+		if _, err := ParseConditionPipeline(de.Aggregate); err != nil {
+			return err
+		}
+	case len(de.Query) < 1:
+		return errors.Errorf("empty condition query")
+	default:
+		if _, err := ParseConditionQuery(de.Query); err != nil {
+			return err
+		}
+	}
+
+	if de.Within > 0 && len(de.After) < 1 {
+		return errors.Errorf("within should be used with after")
+	}
+
+	if de.Quiet > 0 && len(de.After) > 0 {
+		return errors.Errorf("quiet condition can not have after")
+	}
+
+	if de.Count < 0 {
+		return errors.Errorf("negative count, %d", de.Count)
+	} else if de.Count > 0 && de.Quiet > 0 {
+		return errors.Errorf("count condition can not have quiet")
+	}
+
 	if len(de.Storage) > 0 {
 		if !IsTemplateCondition(de.Storage) {
 			if _, err := CheckMongodbURI(de.Storage); err != nil {
@@ -143,3 +475,202 @@ func (de *DesignCondition) IsValid([]byte) error {
 
 	return nil
 }
+
+func (de *DesignCondition) isValidNodeState() error {
+	if len(de.Query) > 0 || de.Duration > 0 || de.Quiet > 0 || len(de.After) > 0 || de.HTTP != nil ||
+		de.Consistency != nil || len(de.Node) > 0 || de.Count > 0 || len(de.Aggregate) > 0 || len(de.Resource) > 0 {
+		return errors.Errorf("node-state condition can not have the other conditions")
+	}
+
+	for alias := range de.NodeState {
+		if _, _, err := ParseNodeStateCondition(de.NodeState[alias]); err != nil {
+			return errors.Wrapf(err, "invalid node-state of node, %q", alias)
+		}
+	}
+
+	return nil
+}
+
+func (de *DesignCondition) isValidResource() error {
+	if len(de.Query) > 0 || de.Duration > 0 || de.Quiet > 0 || len(de.After) > 0 || de.HTTP != nil ||
+		de.Consistency != nil || len(de.Node) > 0 || de.Count > 0 || len(de.Aggregate) > 0 {
+		return errors.Errorf("resource condition can not have the other conditions")
+	}
+
+	_, err := ParseDesignResource(de.Resource)
+
+	return err
+}
+
+func (de *DesignCondition) isValidNode() error {
+	if len(de.Node) < 1 {
+		return nil
+	}
+
+	switch {
+	case len(de.Storage) > 0:
+		return errors.Errorf("node condition can not have storage")
+	case len(de.Col) < 1:
+		return errors.Errorf("node condition needs col")
+	case len(de.After) > 0 || de.Quiet > 0:
+		// NOTE the records of node storage are not ordered by ULID _id
+		return errors.Errorf("node condition can not have after and quiet")
+	default:
+		return nil
+	}
+}
+
+// hasULIDRecord returns true if the matched record has ULID _id. The records
+// of node storage, the other collections and the aggregation results do not
+// have it, so after can not refer to them.
+func (de DesignCondition) hasULIDRecord() bool {
+	switch {
+	case len(de.NodeState) > 0, len(de.Resource) > 0, de.Consistency != nil, de.HTTP != nil,
+		de.Duration > 0, de.Quiet > 0, de.Count > 0:
+		return true
+	default:
+		return len(de.Node) < 1 && len(de.Col) < 1 && len(de.Aggregate) < 1
+	}
+}
+
+// isValidAfterRefs checks that after of conditions does not refer to the
+// record without ULID _id.
+func isValidAfterRefs(sqs []DesignSequence) error {
+	refs := map[string]struct{}{}
+	noULIDRegisters(sqs, refs)
+
+	_, err := checkAfterRefs(sqs, false, refs)
+
+	return err
+}
+
+// noULIDRegisters collects the registers of the records without ULID _id.
+func noULIDRegisters(sqs []DesignSequence, refs map[string]struct{}) {
+	for i := range sqs {
+		sq := sqs[i]
+
+		switch {
+		case sq.Repeat != nil:
+			noULIDRegisters(sq.Repeat.Sequences, refs)
+		case sq.Until != nil:
+			noULIDRegisters(sq.Until.Sequences, refs)
+		case sq.If != nil:
+			noULIDRegisters(sq.If.Then, refs)
+			noULIDRegisters(sq.If.Else, refs)
+		case !sq.Register.IsEmpty() && !sq.Condition.hasULIDRecord():
+			refs[sq.Register.To] = struct{}{}
+		}
+	}
+}
+
+// checkAfterRefs walks the sequences in order; prev is true when the previous
+// matched record does not have ULID _id. It returns prev after the sequences.
+func checkAfterRefs(sqs []DesignSequence, prev bool, refs map[string]struct{}) (bool, error) {
+	for i := range sqs {
+		sq := sqs[i]
+
+		var err error
+		switch {
+		case sq.Repeat != nil:
+			prev, err = checkLoopAfterRefs(sq.Repeat.Sequences, prev, refs)
+		case sq.Until != nil:
+			prev, err = checkLoopAfterRefs(sq.Until.Sequences, prev, refs)
+		case sq.If != nil:
+			var then, els bool
+			if then, err = checkAfterRefs(sq.If.Then, prev, refs); err == nil {
+				els, err = checkAfterRefs(sq.If.Else, prev, refs)
+			}
+			prev = then || els
+		default:
+			err = checkAfterRef(sq.Condition, prev, refs)
+			prev = !sq.Condition.hasULIDRecord()
+		}
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return prev, nil
+}
+
+// checkLoopAfterRefs checks the loop twice; the first sequence of the next
+// iteration comes after the last sequence of the previous iteration.
+func checkLoopAfterRefs(sqs []DesignSequence, prev bool, refs map[string]struct{}) (bool, error) {
+	last, err := checkAfterRefs(sqs, prev, refs)
+	if err != nil {
+		return false, err
+	}
+
+	return checkAfterRefs(sqs, prev || last, refs)
+}
+
+func checkAfterRef(de DesignCondition, prev bool, refs map[string]struct{}) error {
+	var found bool
+	switch {
+	case len(de.After) < 1:
+		return nil
+	case de.After == AfterPrevious:
+		found = prev
+	default:
+		_, found = refs[de.After]
+	}
+
+	if found {
+		return errors.Errorf(
+			"after, %q refers to the record without ULID _id, like the record of node storage", de.After)
+	}
+
+	return nil
+}
+
+const (
+	NodeStateNotStarted   = "not-started"
+	NodeStateInitializing = "initializing"
+	NodeStateInitialized  = "initialized"
+	NodeStateRunning      = "running"
+	NodeStatePaused       = "paused"
+	NodeStateExited       = "exited"
+)
+
+var nodeStates = []string{
+	NodeStateNotStarted, NodeStateInitializing, NodeStateInitialized,
+	NodeStateRunning, NodeStatePaused, NodeStateExited,
+}
+
+// ParseNodeStateCondition parses the state of node-state condition, like
+// "running" or "exited:137"; the exit code is allowed only for "exited".
+func ParseNodeStateCondition(s string) (string, *int64, error) {
+	state, code := strings.TrimSpace(s), ""
+	if i := strings.Index(state, ":"); i >= 0 {
+		state, code = strings.TrimSpace(state[:i]), strings.TrimSpace(state[i+1:])
+	}
+
+	var known bool
+	for i := range nodeStates {
+		if state == nodeStates[i] {
+			known = true
+
+			break
+		}
+	}
+
+	if !known {
+		return "", nil, errors.Errorf("unknown node state, %q", s)
+	}
+
+	if len(code) < 1 {
+		return state, nil, nil
+	}
+
+	if state != NodeStateExited {
+		return "", nil, errors.Errorf("exit code is allowed only for %q", NodeStateExited)
+	}
+
+	i, err := strconv.ParseInt(code, 10, 64)
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "invalid exit code, %q", s)
+	}
+
+	return state, &i, nil
+}
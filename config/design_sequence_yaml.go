@@ -2,6 +2,7 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v3"
@@ -9,12 +10,31 @@ import (
 
 type DesignSequenceYAML struct {
 	Condition interface{}
-	Action    *DesignActionYAML   `yaml:",omitempty"`
-	Register  *DesignRegisterYAML `yaml:"register,omitempty"`
+	Action    *DesignActionYAML      `yaml:",omitempty"`
+	Register  *DesignRegisterYAML    `yaml:"register,omitempty"`
+	Repeat    *uint                  `yaml:"repeat,omitempty"`
+	Until     interface{}            `yaml:"until,omitempty"`
+	If        *string                `yaml:"if,omitempty"`
+	Then      []*DesignSequenceYAML  `yaml:"then,omitempty"`
+	Else      []*DesignSequenceYAML  `yaml:"else,omitempty"`
+	Sequences []*DesignSequenceYAML  `yaml:"sequences,omitempty"`
+	Var       *string                `yaml:"var,omitempty"`
+	Max       *uint                  `yaml:"max,omitempty"`
+	Use       *string                `yaml:"use,omitempty"`
+	With      map[string]interface{} `yaml:"with,omitempty"`
+	macro     string
 }
 
 func (de DesignSequenceYAML) Merge() (DesignSequence, error) {
-	design := DesignSequence{}
+	design := DesignSequence{Macro: de.macro}
+
+	if de.Use != nil {
+		return design, errors.Errorf("macro, %q is not expanded", *de.Use)
+	}
+
+	if err := de.mergeControl(&design); err != nil {
+		return design, err
+	}
 
 	if de.Condition != nil {
 		if i, err := parseCondition(de.Condition); err != nil {
@@ -45,6 +65,80 @@ func (de DesignSequenceYAML) Merge() (DesignSequence, error) {
 	return design, nil
 }
 
+func (de DesignSequenceYAML) mergeControl(design *DesignSequence) error {
+	var v string
+	if de.Var != nil {
+		v = strings.TrimSpace(*de.Var)
+	}
+
+	if de.Repeat != nil {
+		sqs, err := mergeSequencesYAML(de.Sequences)
+		if err != nil {
+			return err
+		}
+
+		design.Repeat = &DesignRepeat{Count: *de.Repeat, Var: v, Sequences: sqs}
+	}
+
+	if de.Until != nil {
+		var condition DesignCondition
+		if i, err := parseCondition(de.Until); err != nil {
+			return err
+		} else if d, err := i.Merge(); err != nil {
+			return err
+		} else {
+			condition = d
+		}
+
+		sqs, err := mergeSequencesYAML(de.Sequences)
+		if err != nil {
+			return err
+		}
+
+		design.Until = &DesignUntil{Condition: condition, Var: v, Sequences: sqs}
+		if de.Max != nil {
+			design.Until.Max = *de.Max
+		}
+	}
+
+	if de.If != nil {
+		then, err := mergeSequencesYAML(de.Then)
+		if err != nil {
+			return err
+		}
+
+		els, err := mergeSequencesYAML(de.Else)
+		if err != nil {
+			return err
+		}
+
+		design.If = &DesignIf{Expr: strings.TrimSpace(*de.If), Then: then, Else: els}
+	}
+
+	return nil
+}
+
+func mergeSequencesYAML(sqs []*DesignSequenceYAML) ([]DesignSequence, error) {
+	if len(sqs) < 1 {
+		return nil, nil
+	}
+
+	merged := make([]DesignSequence, len(sqs))
+	for i := range sqs {
+		if sqs[i] == nil {
+			continue
+		}
+
+		d, err := sqs[i].Merge()
+		if err != nil {
+			return nil, err
+		}
+		merged[i] = d
+	}
+
+	return merged, nil
+}
+
 type DesignActionYAML struct {
 	Name  *string
 	Args  *[]string
@@ -99,14 +193,65 @@ func (de DesignRegisterYAML) Merge() (DesignRegister, error) {
 }
 
 type DesignConditionYAML struct {
-	Query   *string `yaml:"query"`
-	Storage *string `yaml:"storage,omitempty"`
-	Col     *string `yaml:"col,omitempty"`
+	Query       *string                `yaml:"query"`
+	Storage     *string                `yaml:"storage,omitempty"`
+	Col         *string                `yaml:"col,omitempty"`
+	After       *string                `yaml:"after,omitempty"`
+	Within      *string                `yaml:"within,omitempty"`
+	Duration    *string                `yaml:"duration,omitempty"`
+	Quiet       *string                `yaml:"quiet,omitempty"`
+	HTTP        *DesignHTTPYAML        `yaml:"http,omitempty"`
+	Consistency *DesignConsistencyYAML `yaml:"consistency,omitempty"`
+	Node        *string                `yaml:"node,omitempty"`
+	Count       *int64                 `yaml:"count,omitempty"`
+	Aggregate   *string                `yaml:"aggregate,omitempty"`
+	NodeState   map[string]string      `yaml:"node-state,omitempty"`
+	Resource    *string                `yaml:"resource,omitempty"`
 }
 
 func (de DesignConditionYAML) Merge() (DesignCondition, error) {
 	design := DesignCondition{}
 
+	if de.After != nil {
+		design.After = strings.TrimSpace(*de.After)
+	}
+
+	for _, i := range []struct {
+		s    *string
+		d    *time.Duration
+		name string
+	}{
+		{s: de.Within, d: &design.Within, name: "within"},
+		{s: de.Duration, d: &design.Duration, name: "duration"},
+		{s: de.Quiet, d: &design.Quiet, name: "quiet"},
+	} {
+		if i.s == nil {
+			continue
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(*i.s))
+		if err != nil {
+			return design, errors.Wrapf(err, "invalid %s", i.name)
+		}
+		*i.d = d
+	}
+
+	if de.HTTP != nil {
+		i, err := de.HTTP.Merge()
+		if err != nil {
+			return design, err
+		}
+		design.HTTP = &i
+	}
+
+	if de.Consistency != nil {
+		i, err := de.Consistency.Merge(DesignConsistency{})
+		if err != nil {
+			return design, err
+		}
+		design.Consistency = &i
+	}
+
 	if de.Query != nil {
 		design.Query = strings.TrimSpace(*de.Query)
 	}
@@ -119,6 +264,29 @@ func (de DesignConditionYAML) Merge() (DesignCondition, error) {
 		design.Col = strings.TrimSpace(*de.Col)
 	}
 
+	if de.Node != nil {
+		design.Node = strings.TrimSpace(*de.Node)
+	}
+
+	if de.Count != nil {
+		design.Count = *de.Count
+	}
+
+	if de.Aggregate != nil {
+		design.Aggregate = strings.TrimSpace(*de.Aggregate)
+	}
+
+	if de.Resource != nil {
+		design.Resource = strings.TrimSpace(*de.Resource)
+	}
+
+	if len(de.NodeState) > 0 {
+		design.NodeState = map[string]string{}
+		for alias := range de.NodeState {
+			design.NodeState[strings.TrimSpace(alias)] = strings.TrimSpace(de.NodeState[alias])
+		}
+	}
+
 	return design, nil
 }
 
@@ -142,3 +310,81 @@ func parseCondition(v interface{}) (DesignConditionYAML, error) {
 		return design, errors.Errorf("wrong type for DesignCondition, %T", v)
 	}
 }
+
+type DesignHTTPYAML struct {
+	Method   *string
+	URL      *string `yaml:"url"`
+	Headers  map[string]string
+	Body     *string
+	Insecure *bool
+	Timeout  *string
+	Interval *string
+	Status   *int
+	JSON     map[string]interface{} `yaml:"json"`
+}
+
+func (de DesignHTTPYAML) Merge() (DesignHTTP, error) {
+	design := DesignHTTP{Headers: de.Headers, JSON: de.JSON}
+
+	if de.Method != nil {
+		design.Method = strings.TrimSpace(*de.Method)
+	}
+
+	if de.URL != nil {
+		design.URL = strings.TrimSpace(*de.URL)
+	}
+
+	if de.Body != nil {
+		design.Body = *de.Body
+	}
+
+	if de.Insecure != nil {
+		design.Insecure = *de.Insecure
+	}
+
+	if de.Status != nil {
+		design.Status = *de.Status
+	}
+
+	for _, i := range []struct {
+		s    *string
+		d    *time.Duration
+		name string
+	}{
+		{s: de.Timeout, d: &design.Timeout, name: "timeout"},
+		{s: de.Interval, d: &design.Interval, name: "interval"},
+	} {
+		if i.s == nil {
+			continue
+		}
+
+		d, err := time.ParseDuration(strings.TrimSpace(*i.s))
+		if err != nil {
+			return design, errors.Wrapf(err, "invalid http %s", i.name)
+		}
+		*i.d = d
+	}
+
+	return design, nil
+}
+
+// ParseDesignHTTP parses the http design from the extra of action.
+func ParseDesignHTTP(m map[string]interface{}) (DesignHTTP, error) {
+	var de DesignHTTPYAML
+	if b, err := yaml.Marshal(m); err != nil {
+		return DesignHTTP{}, errors.Wrap(err, "invalid yaml for http")
+	} else if err := yaml.Unmarshal(b, &de); err != nil {
+		return DesignHTTP{}, errors.Wrap(err, "invalid DesignHTTPYAML")
+	}
+
+	design, err := de.Merge()
+	if err != nil {
+		return design, err
+	}
+
+	if err := design.IsValid(nil); err != nil {
+		return design, err
+	}
+
+	return design, nil
+}
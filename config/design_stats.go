@@ -0,0 +1,120 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ResourceCPU        = "cpu" // NOTE percent of one cpu
+	ResourceMemory     = "memory"
+	ResourceNetworkRx  = "network-rx"
+	ResourceNetworkTx  = "network-tx"
+	ResourceBlockRead  = "block-read"
+	ResourceBlockWrite = "block-write"
+)
+
+var (
+	DefaultStatsInterval = time.Second * 10
+	resourceMetrics      = []string{
+		ResourceCPU, ResourceMemory, ResourceNetworkRx, ResourceNetworkTx, ResourceBlockRead, ResourceBlockWrite,
+	}
+	resourceOps = []string{">=", "<=", ">", "<"}
+)
+
+// DesignStats is the resource sampling of node containers; the samples are
+// saved in the "stats" collection of contest storage.
+type DesignStats struct {
+	Interval time.Duration
+}
+
+func (de *DesignStats) IsValid([]byte) error {
+	switch {
+	case de.Interval < 0:
+		return errors.Errorf("negative stats interval, %s", de.Interval)
+	case de.Interval == 0:
+		de.Interval = DefaultStatsInterval
+	}
+
+	return nil
+}
+
+// DesignResource is the resource condition, like "no0 memory > 1GiB"; "*"
+// node matches any node. cpu is the percent and the others are the bytes.
+type DesignResource struct {
+	Node   string
+	Metric string
+	Op     string
+	Value  float64
+}
+
+func ParseDesignResource(s string) (DesignResource, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 4 {
+		return DesignResource{}, errors.Errorf("invalid resource condition, %q; <node> <metric> <op> <value>", s)
+	}
+
+	de := DesignResource{Node: fields[0], Metric: fields[1], Op: fields[2]}
+
+	var known bool
+	for i := range resourceMetrics {
+		if de.Metric == resourceMetrics[i] {
+			known = true
+
+			break
+		}
+	}
+
+	if !known {
+		return DesignResource{}, errors.Errorf("unknown resource metric, %q", de.Metric)
+	}
+
+	known = false
+	for i := range resourceOps {
+		if de.Op == resourceOps[i] {
+			known = true
+
+			break
+		}
+	}
+
+	if !known {
+		return DesignResource{}, errors.Errorf("unknown resource operator, %q", de.Op)
+	}
+
+	value := strings.Join(fields[3:], "")
+	if de.Metric == ResourceCPU {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+		if err != nil {
+			return DesignResource{}, errors.Wrapf(err, "invalid cpu value, %q", value)
+		}
+		de.Value = f
+	} else {
+		i, err := ParseByteSize(value)
+		if err != nil {
+			return DesignResource{}, err
+		}
+		de.Value = float64(i)
+	}
+
+	return de, nil
+}
+
+// Match checks whether the value satisfies the condition.
+func (de DesignResource) Match(v float64) bool {
+	switch de.Op {
+	case ">":
+		return v > de.Value
+	case ">=":
+		return v >= de.Value
+	case "<":
+		return v < de.Value
+	case "<=":
+		return v <= de.Value
+	default:
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/yaml.v3"
+)
+
+type testDesignStats struct {
+	suite.Suite
+}
+
+func (t *testDesignStats) TestYAML() {
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(`
+stats:
+  interval: 3s
+sequences:
+  - condition:
+      resource: no0 memory > 1GiB
+`), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(time.Second*3, design.Stats.Interval)
+	t.Equal("no0 memory > 1GiB", design.Sequences[0].Condition.Resource)
+}
+
+func (t *testDesignStats) TestDefaultInterval() {
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(`storage: mongodb://localhost:27017/contest`), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(DefaultStatsInterval, design.Stats.Interval)
+}
+
+func (t *testDesignStats) TestParseDesignResource() {
+	cases := []struct {
+		s        string
+		expected DesignResource
+		err      string
+	}{
+		{s: "no0 memory > 1GiB", expected: DesignResource{Node: "no0", Metric: ResourceMemory, Op: ">", Value: 1 << 30}},
+		{s: "* cpu >= 80%", expected: DesignResource{Node: "*", Metric: ResourceCPU, Op: ">=", Value: 80}},
+		{s: "no1 network-rx < 64 MiB", expected: DesignResource{Node: "no1", Metric: ResourceNetworkRx, Op: "<", Value: 64 << 20}},
+		{s: "no0 memory >", err: "invalid resource condition"},
+		{s: "no0 disk > 1GiB", err: "unknown resource metric"},
+		{s: "no0 memory == 1GiB", err: "unknown resource operator"},
+		{s: "no0 cpu > a", err: "invalid cpu value"},
+		{s: "no0 memory > 1TB", err: "unknown size unit"},
+	}
+
+	for i, c := range cases {
+		de, err := ParseDesignResource(c.s)
+		if len(c.err) > 0 {
+			t.Error(err, "%d: %q", i, c.s)
+			t.Contains(err.Error(), c.err, "%d: %q", i, c.s)
+
+			continue
+		}
+
+		t.NoError(err, "%d: %q", i, c.s)
+		t.Equal(c.expected, de, "%d: %q", i, c.s)
+	}
+}
+
+func (t *testDesignStats) TestMatch() {
+	de, err := ParseDesignResource("no0 memory > 1KiB")
+	t.NoError(err)
+
+	t.True(de.Match(1025))
+	t.False(de.Match(1024))
+
+	de.Op = "<="
+	t.True(de.Match(1024))
+	t.False(de.Match(1025))
+}
+
+func TestDesignStats(t *testing.T) {
+	suite.Run(t, new(testDesignStats))
+}
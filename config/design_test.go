@@ -2,12 +2,16 @@ package config
 
 import (
 	"crypto/rsa"
+	"fmt"
 	"io/ioutil"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/oklog/ulid"
 	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
 	"golang.org/x/crypto/ssh"
 	"gopkg.in/yaml.v3"
 )
@@ -78,6 +82,60 @@ hosts:
 	t.Equal(uint(4), design.Hosts[2].Weight)
 }
 
+func (t *testDesign) TestYAMLHostsPortRange() {
+	y := `
+hosts:
+  - local: true
+    host: 172.17.0.1
+    port-range: 20000-29999
+  - local: true
+    host: 172.17.0.2
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal([2]uint16{20000, 29999}, design.Hosts[0].PortRange)
+	t.Equal(DefaultPortRange, design.Hosts[1].PortRange)
+}
+
+func (t *testDesign) TestYAMLHostsPortRangeInvalid() {
+	cases := []struct {
+		s   string
+		err string
+	}{
+		{s: "20000", err: "invalid port range"},
+		{s: "a-30000", err: "invalid port range"},
+		{s: "20000-70000", err: "invalid port range"},
+		{s: "30000-20000", err: "invalid port range, 30000-20000"},
+		{s: "0-100", err: "invalid port range, 0-100"},
+	}
+
+	for i, c := range cases {
+		y := fmt.Sprintf(`
+hosts:
+  - local: true
+    host: 172.17.0.1
+    port-range: %s
+`, c.s)
+
+		var dy DesignYAML
+		t.NoError(yaml.Unmarshal([]byte(y), &dy), "%d: %q", i, c.s)
+
+		design, err := dy.Merge()
+		if err == nil {
+			err = design.IsValid(nil)
+		}
+
+		t.Error(err, "%d: %q", i, c.s)
+		t.Contains(err.Error(), c.err, "%d: %q", i, c.s)
+	}
+}
+
 func (t *testDesign) TestYAMLHostsWithSSHButEmptyHostString() {
 	y := `
 hosts:
@@ -368,6 +426,1255 @@ sequences:
 	t.Equal(`{"a": 1}`, design.Sequences[0].Condition.Query)
 }
 
+func (t *testDesign) TestYAMLSequenceRepeat() {
+	y := `
+sequences:
+  - repeat: 3
+    var: Loop.no2
+    sequences:
+      - condition: >
+          {"a": 1}
+        action:
+          name: showme
+      - condition: >
+          {"b": 1}
+  - condition: >
+      {"c": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(2, len(design.Sequences))
+
+	sq := design.Sequences[0]
+	t.True(sq.IsControl())
+	t.NotNil(sq.Repeat)
+	t.Equal(uint(3), sq.Repeat.Count)
+	t.Equal("Loop.no2", sq.Repeat.Var)
+	t.Equal(2, len(sq.Repeat.Sequences))
+	t.Equal(`{"a": 1}`, sq.Repeat.Sequences[0].Condition.Query)
+	t.Equal("showme", sq.Repeat.Sequences[0].Action.Name)
+	t.Equal(`{"b": 1}`, sq.Repeat.Sequences[1].Condition.Query)
+
+	t.False(design.Sequences[1].IsControl())
+}
+
+func (t *testDesign) TestYAMLSequenceRepeatEmpty() {
+	y := `
+sequences:
+  - repeat: 0
+    sequences:
+      - condition: >
+          {"a": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+
+	err = design.IsValid(nil)
+	t.Contains(err.Error(), "repeat count should be over 0")
+
+	y = `
+sequences:
+  - repeat: 2
+	`
+
+	dy = DesignYAML{}
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err = dy.Merge()
+	t.NoError(err)
+
+	err = design.IsValid(nil)
+	t.Contains(err.Error(), "empty repeat sequences")
+}
+
+func (t *testDesign) TestYAMLSequenceUntil() {
+	y := `
+sequences:
+  - until: >
+      {"node": "no2", "x.m": "caught up"}
+    max: 10
+    sequences:
+      - condition: >
+          {"a": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	sq := design.Sequences[0]
+	t.NotNil(sq.Until)
+	t.Equal(`{"node": "no2", "x.m": "caught up"}`, sq.Until.Condition.Query)
+	t.Equal(uint(10), sq.Until.Max)
+	t.Equal(DefaultUntilVar, sq.Until.Var)
+	t.Equal(1, len(sq.Until.Sequences))
+}
+
+func (t *testDesign) TestYAMLSequenceUntilTimeCondition() {
+	for _, c := range []string{"{duration: 3s}", `{query: '{"a": 1}', quiet: 3s}`} {
+		y := fmt.Sprintf(`
+sequences:
+  - until: %s
+    sequences:
+      - condition: >
+          {"a": 1}
+	`, c)
+
+		var dy DesignYAML
+		t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+		design, err := dy.Merge()
+		t.NoError(err)
+
+		err = design.IsValid(nil)
+		t.Error(err, c)
+		t.Contains(err.Error(), "until condition can not have duration and quiet")
+	}
+}
+
+func (t *testDesign) TestYAMLSequenceNestedLoopVar() {
+	y := `
+sequences:
+  - repeat: 2
+    sequences:
+      - if: "true"
+        then:
+          - until: >
+              {"a": 1}
+            sequences:
+              - repeat: 3
+                sequences:
+                  - condition: >
+                      {"b": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	repeat := design.Sequences[0].Repeat
+	t.Equal(DefaultRepeatVar, repeat.Var)
+
+	until := repeat.Sequences[0].If.Then[0].Until
+	t.Equal(DefaultUntilVar+"1", until.Var)
+	t.Equal(DefaultRepeatVar+"2", until.Sequences[0].Repeat.Var)
+}
+
+func (t *testDesign) TestYAMLSequenceNestedLoopSameVar() {
+	y := `
+sequences:
+  - repeat: 2
+    var: I
+    sequences:
+      - repeat: 3
+        var: I
+        sequences:
+          - condition: >
+              {"b": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+
+	err = design.IsValid(nil)
+	t.Error(err)
+	t.Contains(err.Error(), "already used by the enclosing loop")
+}
+
+func (t *testDesign) TestYAMLSequenceIf() {
+	y := `
+sequences:
+  - if: >
+      {{ eq .Register.showme.node "no0" }}
+    then:
+      - condition: >
+          {"a": 1}
+    else:
+      - condition: >
+          {"b": 1}
+      - condition: >
+          {"c": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	sq := design.Sequences[0]
+	t.NotNil(sq.If)
+	t.Equal(`{{ eq .Register.showme.node "no0" }}`, sq.If.Expr)
+	t.Equal(1, len(sq.If.Then))
+	t.Equal(2, len(sq.If.Else))
+}
+
+func (t *testDesign) TestYAMLSequenceControlWithCondition() {
+	y := `
+sequences:
+  - repeat: 2
+    condition: >
+      {"a": 1}
+    sequences:
+      - condition: >
+          {"b": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+
+	err = design.IsValid(nil)
+	t.Contains(err.Error(), "condition is not allowed")
+
+	y = `
+sequences:
+  - repeat: 2
+    if: "true"
+    then:
+      - condition: >
+          {"b": 1}
+    sequences:
+      - condition: >
+          {"b": 1}
+	`
+
+	dy = DesignYAML{}
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err = dy.Merge()
+	t.NoError(err)
+
+	err = design.IsValid(nil)
+	t.Contains(err.Error(), "only one of repeat, until and if")
+}
+
+func (t *testDesign) TestYAMLSequenceMacro() {
+	y := `
+macros:
+  bootstrap-genesis:
+    params:
+      genesis:
+      nodes: [no0, no1, no2]
+    sequences:
+      - condition: >
+          {"m": "contest ready"}
+        action:
+          name: init-nodes
+          nodes:
+            - ${genesis}
+      - condition: >
+          {"node": "${genesis}", "x.m": "genesis block created"}
+        action:
+          name: start-nodes
+          nodes: ${nodes}
+sequences:
+  - use: bootstrap-genesis
+    with:
+      genesis: no1
+  - condition: >
+      {"c": 1}
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(3, len(design.Sequences))
+
+	t.Equal("bootstrap-genesis", design.Sequences[0].Macro)
+	t.Equal("init-nodes", design.Sequences[0].Action.Name)
+	t.Equal([]interface{}{"no1"}, design.Sequences[0].Action.Extra["nodes"])
+
+	t.Equal("bootstrap-genesis", design.Sequences[1].Macro)
+	t.Equal(`{"node": "no1", "x.m": "genesis block created"}`, design.Sequences[1].Condition.Query)
+	t.Equal([]interface{}{"no0", "no1", "no2"}, design.Sequences[1].Action.Extra["nodes"])
+
+	t.Empty(design.Sequences[2].Macro)
+	t.Equal(`{"c": 1}`, design.Sequences[2].Condition.Query)
+}
+
+func (t *testDesign) TestYAMLSequenceMacroInControl() {
+	y := `
+include-macros:
+  - ./test_macros.yml
+sequences:
+  - repeat: 2
+    sequences:
+      - use: wait-block
+        with:
+          node: no2
+          height: 3
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+	dy.BaseDir = "testdata"
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	sqs := design.Sequences[0].Repeat.Sequences
+	t.Equal(1, len(sqs))
+	t.Equal("wait-block", sqs[0].Macro)
+	t.Equal(`{"node": "no2", "x.m": "new block stored", "x.block.height": 3}`, sqs[0].Condition.Query)
+}
+
+func (t *testDesign) TestYAMLSequenceNestedMacro() {
+	y := `
+include-macros:
+  - ./test_macros.yml
+macros:
+  wait-blocks:
+    params:
+      node:
+    sequences:
+      - use: wait-block
+        with:
+          node: ${node}
+      - repeat: 2
+        sequences:
+          - use: wait-block
+            with:
+              node: ${node}
+              height: 3
+sequences:
+  - use: wait-blocks
+    with:
+      node: no1
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+	dy.BaseDir = "testdata"
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(2, len(design.Sequences))
+	t.Equal("wait-blocks/wait-block", design.Sequences[0].Macro)
+	t.Equal(`{"node": "no1", "x.m": "new block stored", "x.block.height": 2}`, design.Sequences[0].Condition.Query)
+
+	t.Equal("wait-blocks", design.Sequences[1].Macro)
+
+	sqs := design.Sequences[1].Repeat.Sequences
+	t.Equal(1, len(sqs))
+	t.Equal("wait-block", sqs[0].Macro)
+	t.Equal(`{"node": "no1", "x.m": "new block stored", "x.block.height": 3}`, sqs[0].Condition.Query)
+}
+
+func (t *testDesign) TestYAMLIncludeMacrosPath() {
+	abs, err := filepath.Abs("testdata/test_macros.yml")
+	t.NoError(err)
+
+	cases := []struct {
+		name string
+		base string
+		path string
+		err  string
+	}{
+		{name: "relative to base", base: "testdata", path: "./test_macros.yml"},
+		{name: "no base", path: "testdata/test_macros.yml"},
+		{name: "absolute", base: "/findme", path: abs},
+		{name: "not relative to current", path: "./test_macros.yml", err: "failed to read macros file"},
+		{name: "unknown base", base: "/findme", path: "./test_macros.yml", err: "failed to read macros file"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			y := fmt.Sprintf(`
+include-macros:
+  - %s
+sequences:
+  - use: wait-block
+    with:
+      node: no0
+      height: 3
+`, c.path)
+
+			var dy DesignYAML
+			t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+			dy.BaseDir = c.base
+
+			design, err := dy.Merge()
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal("wait-block", design.Sequences[0].Macro)
+		})
+	}
+}
+
+func (t *testDesign) TestYAMLSequenceMacroErrors() {
+	cases := []struct {
+		name string
+		y    string
+		err  string
+	}{
+		{
+			name: "unknown macro",
+			y: `
+sequences:
+  - use: findme
+`,
+			err: "unknown macro",
+		},
+		{
+			name: "missing parameter",
+			y: `
+macros:
+  showme:
+    params:
+      node:
+    sequences:
+      - condition: '{"node": "${node}"}'
+sequences:
+  - use: showme
+`,
+			err: "missing parameter",
+		},
+		{
+			name: "unknown parameter",
+			y: `
+macros:
+  showme:
+    sequences:
+      - condition: '{"a": 1}'
+sequences:
+  - use: showme
+    with:
+      node: no0
+`,
+			err: "unknown parameter",
+		},
+		{
+			name: "recursive",
+			y: `
+macros:
+  showme:
+    sequences:
+      - use: showme
+sequences:
+  - use: showme
+`,
+			err: "too deep macro expansion",
+		},
+		{
+			name: "use with condition",
+			y: `
+macros:
+  showme:
+    sequences:
+      - condition: '{"a": 1}'
+sequences:
+  - use: showme
+    condition: '{"b": 1}'
+`,
+			err: "can not be combined",
+		},
+	}
+
+	for i, c := range cases {
+		i := i
+		c := c
+		t.Run(
+			c.name,
+			func() {
+				var dy DesignYAML
+				t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(c.y)), &dy))
+
+				_, err := dy.Merge()
+				t.Error(err, "%d: %s", i, c.name)
+				t.Contains(err.Error(), c.err, "%d: %s", i, c.name)
+			},
+		)
+	}
+}
+
+func (t *testDesign) TestYAMLSequenceConditionAfter() {
+	y := `
+sequences:
+  - condition:
+      query: >
+        {"a": 1}
+      after: previous
+      within: 10s
+  - condition:
+      query: >
+        {"b": 1}
+      after: showme
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(AfterPrevious, design.Sequences[0].Condition.After)
+	t.Equal(time.Second*10, design.Sequences[0].Condition.Within)
+	t.Equal("showme", design.Sequences[1].Condition.After)
+	t.Equal(time.Duration(0), design.Sequences[1].Condition.Within)
+}
+
+func (t *testDesign) TestYAMLSequenceConditionAfterNotULIDRecord() {
+	node := `
+  - condition:
+      node: no0
+      col: block
+      query: '{"height": 3}'
+`
+
+	cases := []struct {
+		name string
+		y    string
+		err  string
+	}{
+		{
+			name: "after node register",
+			y: node + `    register:
+      type: last_match
+      to: block
+  - condition:
+      query: '{"a": 1}'
+      after: block
+`,
+			err: `after, "block" refers to the record without ULID _id`,
+		},
+		{
+			name: "after previous node",
+			y: node + `  - condition:
+      query: '{"a": 1}'
+      after: previous
+`,
+			err: `after, "previous" refers to the record without ULID _id`,
+		},
+		{
+			name: "after previous count",
+			y: node + `  - condition:
+      node: no0
+      col: block
+      query: '{"height": 3}'
+      count: 1
+  - condition:
+      query: '{"a": 1}'
+      after: previous
+`,
+		},
+		{
+			name: "after previous iteration",
+			y: `
+  - repeat: 2
+    sequences:
+      - condition:
+          query: '{"a": 1}'
+          after: previous
+` + strings.ReplaceAll(node, "\n  ", "\n      "),
+			err: `after, "previous" refers to the record without ULID _id`,
+		},
+		{
+			name: "after previous if",
+			y: `
+  - if: "true"
+    then:` + strings.ReplaceAll(node, "\n  ", "\n      ") + `    else:
+      - condition: '{"b": 1}'
+  - condition:
+      query: '{"a": 1}'
+      after: previous
+`,
+			err: `after, "previous" refers to the record without ULID _id`,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			var dy DesignYAML
+			t.NoError(yaml.Unmarshal([]byte("sequences:"+c.y), &dy))
+
+			design, err := dy.Merge()
+			t.NoError(err)
+
+			err = design.IsValid(nil)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+		})
+	}
+}
+
+func (t *testDesign) TestYAMLSequenceConditionWithinWithoutAfter() {
+	y := `
+sequences:
+  - condition:
+      query: >
+        {"a": 1}
+      within: 10s
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+
+	err = design.IsValid(nil)
+	t.Contains(err.Error(), "within should be used with after")
+}
+
+func (t *testDesign) TestYAMLSequenceTimeConditions() {
+	y := `
+sequences:
+  - condition:
+      duration: 30s
+    action:
+      name: stop-nodes
+      nodes:
+        - no1
+  - condition:
+      query: >
+        {"node": "no1"}
+      quiet: 10s
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(time.Second*30, design.Sequences[0].Condition.Duration)
+	t.Empty(design.Sequences[0].Condition.Query)
+	t.Equal(time.Second*10, design.Sequences[1].Condition.Quiet)
+	t.Equal(`{"node": "no1"}`, design.Sequences[1].Condition.Query)
+}
+
+func (t *testDesign) TestYAMLSequenceTimeConditionsInvalid() {
+	cases := []struct {
+		name string
+		y    string
+		err  string
+	}{
+		{
+			name: "duration with query",
+			y: `
+sequences:
+  - condition:
+      query: '{"a": 1}'
+      duration: 3s
+`,
+			err: "duration condition can not have query",
+		},
+		{
+			name: "quiet without query",
+			y: `
+sequences:
+  - condition:
+      quiet: 3s
+`,
+			err: "empty condition query",
+		},
+		{
+			name: "quiet with after",
+			y: `
+sequences:
+  - condition:
+      query: '{"a": 1}'
+      quiet: 3s
+      after: previous
+`,
+			err: "quiet condition can not have after",
+		},
+	}
+
+	for i, c := range cases {
+		i := i
+		c := c
+		t.Run(
+			c.name,
+			func() {
+				var dy DesignYAML
+				t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(c.y)), &dy))
+
+				design, err := dy.Merge()
+				t.NoError(err)
+
+				err = design.IsValid(nil)
+				t.Error(err, "%d: %s", i, c.name)
+				t.Contains(err.Error(), c.err, "%d: %s", i, c.name)
+			},
+		)
+	}
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(`
+sequences:
+  - condition:
+      duration: 3
+`), &dy))
+
+	_, err := dy.Merge()
+	t.Contains(err.Error(), "invalid duration")
+}
+
+func (t *testDesign) TestYAMLSequenceHTTPCondition() {
+	y := `
+sequences:
+  - condition:
+      http:
+        url: "https://{{ .Design.Node.no0.Host }}:54321/"
+        headers:
+          Accept: application/json
+        insecure: true
+        interval: 500ms
+        status: 200
+        json:
+          $.block.height: 3
+          $.suffrage.nodes[0]: no0
+    action:
+      name: stop-nodes
+      nodes:
+        - no0
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	h := design.Sequences[0].Condition.HTTP
+	t.NotNil(h)
+	t.Equal("GET", h.Method)
+	t.Equal("https://{{ .Design.Node.no0.Host }}:54321/", h.URL)
+	t.Equal("application/json", h.Headers["Accept"])
+	t.True(h.Insecure)
+	t.Equal(DefaultHTTPTimeout, h.Timeout)
+	t.Equal(time.Millisecond*500, h.Interval)
+
+	body := map[string]interface{}{
+		"block":    map[string]interface{}{"height": 3},
+		"suffrage": map[string]interface{}{"nodes": []interface{}{"no0", "no1"}},
+	}
+	t.NoError(h.CheckResponse(200, body))
+
+	err = h.CheckResponse(500, body)
+	t.Contains(err.Error(), "unexpected http status")
+
+	body["block"] = map[string]interface{}{"height": 2}
+	err = h.CheckResponse(200, body)
+	t.Contains(err.Error(), "not matched")
+}
+
+func (t *testDesign) TestYAMLSequenceHTTPConditionInvalid() {
+	cases := []struct {
+		name string
+		y    string
+		err  string
+	}{
+		{
+			name: "empty url",
+			y: `
+sequences:
+  - condition:
+      http:
+        method: get
+`,
+			err: "empty http url",
+		},
+		{
+			name: "http with query",
+			y: `
+sequences:
+  - condition:
+      query: '{"a": 1}'
+      http:
+        url: http://localhost
+`,
+			err: "http condition can not have query",
+		},
+		{
+			name: "invalid json path",
+			y: `
+sequences:
+  - condition:
+      http:
+        url: http://localhost
+        json:
+          $.a[b]: 1
+`,
+			err: "invalid index",
+		},
+	}
+
+	for i, c := range cases {
+		i := i
+		c := c
+		t.Run(
+			c.name,
+			func() {
+				var dy DesignYAML
+				t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(c.y)), &dy))
+
+				design, err := dy.Merge()
+				t.NoError(err)
+
+				err = design.IsValid(nil)
+				t.Error(err, "%d: %s", i, c.name)
+				t.Contains(err.Error(), c.err, "%d: %s", i, c.name)
+			},
+		)
+	}
+}
+
+func (t *testDesign) TestParseDesignHTTP() {
+	design, err := ParseDesignHTTP(map[string]interface{}{
+		"name":     "http",
+		"method":   "post",
+		"url":      "http://localhost:54321/",
+		"body":     `{"a": 1}`,
+		"timeout":  "3s",
+		"register": "res",
+	})
+	t.NoError(err)
+	t.Equal("POST", design.Method)
+	t.Equal(`{"a": 1}`, design.Body)
+	t.Equal(time.Second*3, design.Timeout)
+
+	_, err = ParseDesignHTTP(map[string]interface{}{"url": "http://localhost", "timeout": "3"})
+	t.Contains(err.Error(), "invalid http timeout")
+}
+
+func (t *testDesign) TestYAMLConsistency() {
+	y := `
+consistency:
+  at-shutdown: true
+  fields:
+    - hash
+    - $.d.value.state
+sequences:
+  - condition:
+      consistency:
+        nodes:
+          - no0
+          - no1
+        height: 10
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.True(design.Consistency.AtShutdown)
+	t.Empty(design.Consistency.Storage)
+	t.Equal(DefaultConsistencyCol, design.Consistency.Col)
+	t.Equal([]string{"hash", "$.d.value.state"}, design.Consistency.Fields)
+	t.Empty(design.Consistency.Nodes)
+
+	c := design.Sequences[0].Condition.Consistency
+	t.NotNil(c)
+	t.Equal([]string{"no0", "no1"}, c.Nodes)
+	t.Equal(int64(10), c.Height)
+	t.Equal(DefaultConsistencyFields, c.Fields)
+
+	i, err := ParseDesignConsistency(map[string]interface{}{"col": "block", "register": "a"}, design.Consistency)
+	t.NoError(err)
+	t.Equal("block", i.Col)
+	t.Equal(design.Consistency.Fields, i.Fields)
+
+	_, err = ParseDesignConsistency(map[string]interface{}{"nodes": []interface{}{"no0", "no0"}}, design.Consistency)
+	t.Contains(err.Error(), "duplicated node")
+
+	dy = DesignYAML{}
+	t.NoError(yaml.Unmarshal([]byte(`
+sequences:
+  - condition:
+      query: '{"a": 1}'
+      consistency:
+        height: 3
+`), &dy))
+
+	design, err = dy.Merge()
+	t.NoError(err)
+	t.Contains(design.IsValid(nil).Error(), "consistency condition can not have query")
+}
+
+func (t *testDesign) TestYAMLNodeCondition() {
+	y := `
+sequences:
+  - condition:
+      node: no0
+      col: manifest
+      query: '{"height": {"$gte": 3}}'
+      count: 2
+  - condition:
+      node: no1
+      col: manifest
+      aggregate: >
+        [{"$match": {"height": {{ .Register.height }}}}, {"$limit": 1}]
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal("no0", design.Sequences[0].Condition.Node)
+	t.Equal(int64(2), design.Sequences[0].Condition.Count)
+	t.Equal("no1", design.Sequences[1].Condition.Node)
+	t.Equal(`[{"$match": {"height": {{ .Register.height }}}}, {"$limit": 1}]`, design.Sequences[1].Condition.Aggregate)
+
+	p, err := ParseConditionPipeline(`[{"$match": {"height": 3}}, {"$limit": 1}]`)
+	t.NoError(err)
+	t.Equal(2, len(p))
+
+	_, err = ParseConditionPipeline(`{"$match": {"height": 3}}`)
+	t.Contains(err.Error(), "should be array")
+}
+
+func (t *testDesign) TestYAMLNodeConditionInvalid() {
+	cases := []struct {
+		name string
+		y    string
+		err  string
+	}{
+		{
+			name: "node without col",
+			y: `
+sequences:
+  - condition:
+      node: no0
+      query: '{"a": 1}'
+`,
+			err: "node condition needs col",
+		},
+		{
+			name: "node with storage",
+			y: `
+sequences:
+  - condition:
+      node: no0
+      col: manifest
+      storage: mongodb://localhost:27017/a
+      query: '{"a": 1}'
+`,
+			err: "node condition can not have storage",
+		},
+		{
+			name: "node with after",
+			y: `
+sequences:
+  - condition:
+      node: no0
+      col: manifest
+      query: '{"a": 1}'
+      after: previous
+`,
+			err: "node condition can not have after",
+		},
+		{
+			name: "aggregate with query",
+			y: `
+sequences:
+  - condition:
+      col: manifest
+      query: '{"a": 1}'
+      aggregate: '[]'
+`,
+			err: "aggregate condition can not have query",
+		},
+		{
+			name: "negative count",
+			y: `
+sequences:
+  - condition:
+      query: '{"a": 1}'
+      count: -1
+`,
+			err: "negative count",
+		},
+	}
+
+	for i, c := range cases {
+		i := i
+		c := c
+		t.Run(
+			c.name,
+			func() {
+				var dy DesignYAML
+				t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(c.y)), &dy))
+
+				design, err := dy.Merge()
+				t.NoError(err)
+
+				err = design.IsValid(nil)
+				t.Error(err, "%d: %s", i, c.name)
+				t.Contains(err.Error(), c.err, "%d: %s", i, c.name)
+			},
+		)
+	}
+}
+
+func (t *testDesign) TestYAMLNodeStateCondition() {
+	y := `
+sequences:
+  - condition:
+      node-state:
+        no0: running
+        no1: 'exited:137'
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(map[string]string{"no0": "running", "no1": "exited:137"}, design.Sequences[0].Condition.NodeState)
+
+	state, code, err := ParseNodeStateCondition("exited:137")
+	t.NoError(err)
+	t.Equal(NodeStateExited, state)
+	t.Equal(int64(137), *code)
+
+	state, code, err = ParseNodeStateCondition("paused")
+	t.NoError(err)
+	t.Equal(NodeStatePaused, state)
+	t.Nil(code)
+}
+
+func (t *testDesign) TestYAMLNodeStateConditionInvalid() {
+	cases := []struct {
+		name string
+		y    string
+		err  string
+	}{
+		{
+			name: "unknown state",
+			y: `
+sequences:
+  - condition:
+      node-state:
+        no0: dead
+`,
+			err: "unknown node state",
+		},
+		{
+			name: "exit code with running",
+			y: `
+sequences:
+  - condition:
+      node-state:
+        no0: 'running:1'
+`,
+			err: "exit code is allowed only",
+		},
+		{
+			name: "invalid exit code",
+			y: `
+sequences:
+  - condition:
+      node-state:
+        no0: 'exited:a'
+`,
+			err: "invalid exit code",
+		},
+		{
+			name: "with query",
+			y: `
+sequences:
+  - condition:
+      query: '{"a": 1}'
+      node-state:
+        no0: running
+`,
+			err: "node-state condition can not have the other conditions",
+		},
+	}
+
+	for i, c := range cases {
+		i := i
+		c := c
+		t.Run(
+			c.name,
+			func() {
+				var dy DesignYAML
+				t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(c.y)), &dy))
+
+				design, err := dy.Merge()
+				t.NoError(err)
+
+				err = design.IsValid(nil)
+				t.Error(err, "%d: %s", i, c.name)
+				t.Contains(err.Error(), c.err, "%d: %s", i, c.name)
+			},
+		)
+	}
+}
+
+func (t *testDesign) TestAfterConditionQuery() {
+	now := time.Now()
+	refID := ulidAt(now)
+
+	q, deadline, err := AfterConditionQuery(bson.M{"a": 1}, refID, 0)
+	t.NoError(err)
+	t.True(deadline.IsZero())
+	t.Equal(bson.M{"$and": bson.A{bson.M{"a": 1}, bson.M{"_id": bson.M{"$gt": refID}}}}, q)
+
+	q, deadline, err = AfterConditionQuery(bson.M{"a": 1}, refID, time.Second*3)
+	t.NoError(err)
+
+	rt, err := ULIDTime(refID)
+	t.NoError(err)
+	t.Equal(rt.Add(time.Second*3), deadline)
+
+	r := q["$and"].(bson.A)[1].(bson.M)["_id"].(bson.M)
+	upper := r["$lte"].(string)
+	t.True(upper > refID)
+	t.True(upper > ulidAt(now.Add(time.Second*2)))
+	t.True(upper < ulidAt(now.Add(time.Second*4)))
+
+	_, _, err = AfterConditionQuery(bson.M{"a": 1}, "findme", 0)
+	t.Contains(err.Error(), "invalid reference _id")
+}
+
+func ulidAt(t time.Time) string {
+	return ulid.MustNew(ulid.Timestamp(t), entropy).String()
+}
+
+func (t *testDesign) TestYAMLExpect() {
+	y := `
+expect:
+  stderr:
+    node: no0
+    pattern: "failed to prepare.*contest designed"
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.Equal(ExpectStderrType, design.Expect.Type)
+	t.True(design.Expect.MatchStderr("no0", []byte("error: failed to prepare; contest designed")))
+	t.False(design.Expect.MatchStderr("no1", []byte("error: failed to prepare; contest designed")))
+	t.False(design.Expect.MatchStderr("no0", []byte("error: failed to save")))
+	t.False(design.Expect.MatchKill("failed to prepare; contest designed"))
+	t.False(design.Expect.MatchExitCode("no0", 1))
+}
+
+func (t *testDesign) TestYAMLExpectKillAndExitCode() {
+	y := `
+expect:
+  kill: ^showme$
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.True(design.Expect.MatchKill("showme"))
+	t.False(design.Expect.MatchKill("showme findme"))
+
+	y = `
+expect:
+  exit-code:
+    code: 3
+	`
+
+	dy = DesignYAML{}
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err = dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.True(design.Expect.MatchExitCode("no0", 3))
+	t.True(design.Expect.MatchExitCode("no1", 3))
+	t.False(design.Expect.MatchExitCode("no1", 1))
+}
+
+func (t *testDesign) TestYAMLExpectInvalid() {
+	y := `
+expect:
+  kill: showme
+  exit-code:
+    code: 3
+	`
+
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	_, err := dy.Merge()
+	t.Contains(err.Error(), "only one of kill, stderr and exit-code")
+
+	y = `
+expect:
+  kill: "showme("
+	`
+
+	dy = DesignYAML{}
+	t.NoError(yaml.Unmarshal([]byte(strings.TrimSpace(y)), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+
+	err = design.IsValid(nil)
+	t.Contains(err.Error(), "invalid expect pattern")
+}
+
 func (t *testDesign) TestYAMLLoadStorage() {
 	b, err := ioutil.ReadFile(filepath.Clean("./test_simple.yml"))
 	t.NoError(err)
@@ -385,6 +1692,30 @@ func (t *testDesign) TestYAMLLoadStorage() {
 	t.Equal([]string{"127.0.0.1:27017"}, design.Storage.Hosts)
 }
 
+func (t *testDesign) TestYAMLStoragePerNode() {
+	var dy DesignYAML
+	t.NoError(yaml.Unmarshal([]byte(`
+storage-per-node: true
+`), &dy))
+
+	design, err := dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.True(design.StoragePerNode)
+	t.Equal(1, len(design.Hosts))
+	t.True(design.Hosts[0].StoragePerNode)
+
+	// NOTE by default, storage is shared
+	dy = DesignYAML{}
+	design, err = dy.Merge()
+	t.NoError(err)
+	t.NoError(design.IsValid(nil))
+
+	t.False(design.StoragePerNode)
+	t.False(design.Hosts[0].StoragePerNode)
+}
+
 func (t *testDesign) TestYAMLLoadHosts() {
 	b, err := ioutil.ReadFile(filepath.Clean("./test_simple.yml"))
 	t.NoError(err)
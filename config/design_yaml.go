@@ -2,18 +2,31 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 type DesignYAML struct {
-	Storage     *string
-	Hosts       []*DesignHostYAML
-	NodeConfig  map[ /* node alias */ string]interface{} `yaml:"node-config"`
-	NodesConfig *string                                  `yaml:"nodes-config"`
-	Sequences   []*DesignSequenceYAML
-	ExitOnError *bool `yaml:"exit-on-error"`
-	Skip        *bool
+	Storage        *string
+	StoragePerNode *bool `yaml:"storage-per-node"`
+	Hosts          []*DesignHostYAML
+	NodeConfig     map[ /* node alias */ string]interface{} `yaml:"node-config"`
+	NodesConfig    *string                                  `yaml:"nodes-config"`
+	Sequences      []*DesignSequenceYAML
+	Macros         map[string]*DesignMacroYAML `yaml:"macros"`
+	IncludeMacros  []string                    `yaml:"include-macros"`
+	ExitOnError    *bool                       `yaml:"exit-on-error"`
+	Skip           *bool
+	Expect         *DesignExpectYAML
+	Consistency    *DesignConsistencyYAML
+	Disks          map[ /* node alias */ string]*DesignDiskYAML
+	Clocks         map[ /* node alias */ string]*DesignClockYAML
+	Stats          *DesignStatsYAML
+	// NOTE BaseDir is the directory of design file; the relative paths in
+	// design, like "include-macros" are from BaseDir.
+	BaseDir string `yaml:"-"`
 }
 
 func (de DesignYAML) Merge() (Design, error) {
@@ -36,6 +49,18 @@ func (de DesignYAML) Merge() (Design, error) {
 	design.NodeConfig = j
 	design.CommonNodeConfig = k
 
+	l, err := de.mergeDisks(design.NodeConfig)
+	if err != nil {
+		return design, err
+	}
+	design.Disks = l
+
+	o, err := de.mergeClocks(design.NodeConfig)
+	if err != nil {
+		return design, err
+	}
+	design.Clocks = o
+
 	if de.NodesConfig != nil {
 		design.NodesConfig = *de.NodesConfig
 	}
@@ -102,10 +127,118 @@ func (de DesignYAML) mergeNodeConfigs() (map[string]string, string, error) {
 	return nodeConfig, commonNodeConfig, nil
 }
 
+// mergeDisks merges the disks of nodes; "common" is applied to all the nodes
+// and overridden by the disk of node.
+func (de DesignYAML) mergeDisks(nodeConfig map[string]string) (map[string]DesignDisk, error) {
+	if len(de.Disks) < 1 {
+		return nil, nil
+	}
+
+	common := DesignDisk{}
+	if c := de.Disks["common"]; c != nil {
+		i, err := c.Merge(common)
+		if err != nil {
+			return nil, err
+		}
+		common = i
+	}
+
+	for alias := range de.Disks {
+		if alias == "common" {
+			continue
+		}
+
+		if _, found := nodeConfig[alias]; !found {
+			return nil, errors.Errorf("disk of unknown node, %q", alias)
+		}
+	}
+
+	disks := map[string]DesignDisk{}
+	for alias := range nodeConfig {
+		disk := common
+		if c := de.Disks[alias]; c != nil {
+			i, err := c.Merge(common)
+			if err != nil {
+				return nil, err
+			}
+			disk = i
+		}
+
+		disks[alias] = disk
+	}
+
+	return disks, nil
+}
+
+// mergeClocks merges the clocks of nodes like mergeDisks.
+func (de DesignYAML) mergeClocks(nodeConfig map[string]string) (map[string]DesignClock, error) {
+	if len(de.Clocks) < 1 {
+		return nil, nil
+	}
+
+	var common *DesignClock
+	if c := de.Clocks["common"]; c != nil {
+		i, err := c.Merge(DesignClock{})
+		if err != nil {
+			return nil, err
+		}
+		common = &i
+	}
+
+	for alias := range de.Clocks {
+		if alias == "common" {
+			continue
+		}
+
+		if _, found := nodeConfig[alias]; !found {
+			return nil, errors.Errorf("clock of unknown node, %q", alias)
+		}
+	}
+
+	clocks := map[string]DesignClock{}
+	for alias := range nodeConfig {
+		c := de.Clocks[alias]
+		if c == nil && common == nil {
+			continue
+		}
+
+		var base DesignClock
+		if common != nil {
+			base = *common
+		}
+
+		if c != nil {
+			i, err := c.Merge(base)
+			if err != nil {
+				return nil, err
+			}
+			base = i
+		}
+
+		clocks[alias] = base
+	}
+
+	return clocks, nil
+}
+
 func (de DesignYAML) mergeSequences() ([]DesignSequence, error) {
-	ss := make([]DesignSequence, len(de.Sequences))
-	for i := range de.Sequences {
-		d, err := de.Sequences[i].Merge()
+	macros, err := loadMacrosFiles(de.BaseDir, de.IncludeMacros)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range de.Macros {
+		macros[name] = de.Macros[name]
+	}
+
+	sqs, err := expandMacros(de.Sequences, macros, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := make([]DesignSequence, len(sqs))
+	for i := range sqs {
+		d, err := sqs[i].Merge()
 		if err != nil {
 			return nil, err
 		}
@@ -115,7 +248,7 @@ func (de DesignYAML) mergeSequences() ([]DesignSequence, error) {
 	return ss, nil
 }
 
-func (de DesignYAML) mergeEtc(design Design) (Design, error) { // nolint:unparam
+func (de DesignYAML) mergeEtc(design Design) (Design, error) {
 	if de.ExitOnError == nil {
 		design.ExitOnError = true
 	} else {
@@ -126,14 +259,61 @@ func (de DesignYAML) mergeEtc(design Design) (Design, error) { // nolint:unparam
 		design.Skip = *de.Skip
 	}
 
+	if de.StoragePerNode != nil {
+		design.StoragePerNode = *de.StoragePerNode
+	}
+
+	if de.Expect != nil {
+		i, err := de.Expect.Merge()
+		if err != nil {
+			return design, err
+		}
+		design.Expect = i
+	}
+
+	if de.Consistency != nil {
+		i, err := de.Consistency.Merge(DesignConsistency{})
+		if err != nil {
+			return design, err
+		}
+		design.Consistency = i
+	}
+
+	if de.Stats != nil {
+		i, err := de.Stats.Merge()
+		if err != nil {
+			return design, err
+		}
+		design.Stats = i
+	}
+
+	return design, nil
+}
+
+type DesignStatsYAML struct {
+	Interval *string
+}
+
+func (de DesignStatsYAML) Merge() (DesignStats, error) {
+	design := DesignStats{}
+
+	if de.Interval != nil {
+		d, err := time.ParseDuration(strings.TrimSpace(*de.Interval))
+		if err != nil {
+			return design, errors.Wrap(err, "invalid stats interval")
+		}
+		design.Interval = d
+	}
+
 	return design, nil
 }
 
 type DesignHostYAML struct {
-	Weight *uint
-	Local  *bool
-	Host   *string
-	SSH    *DesignHostSSHYAML
+	Weight    *uint
+	Local     *bool
+	Host      *string
+	SSH       *DesignHostSSHYAML
+	PortRange *string `yaml:"port-range"`
 }
 
 func (de DesignHostYAML) Merge() (DesignHost, error) {
@@ -151,6 +331,14 @@ func (de DesignHostYAML) Merge() (DesignHost, error) {
 		design.Host = *de.Host
 	}
 
+	if de.PortRange != nil {
+		r, err := ParsePortRange(*de.PortRange)
+		if err != nil {
+			return design, err
+		}
+		design.PortRange = r
+	}
+
 	if de.SSH != nil {
 		d, err := de.SSH.Merge()
 		if err != nil {
@@ -185,3 +373,192 @@ func (de DesignHostSSHYAML) Merge() (DesignHostSSH, error) {
 
 	return design, nil
 }
+
+type DesignExpectYAML struct {
+	Kill     *string
+	Stderr   *DesignExpectStderrYAML
+	ExitCode *DesignExpectExitCodeYAML `yaml:"exit-code"`
+}
+
+type DesignExpectStderrYAML struct {
+	Node    *string
+	Pattern *string
+}
+
+type DesignExpectExitCodeYAML struct {
+	Node *string
+	Code *int64
+}
+
+func (de DesignExpectYAML) Merge() (DesignExpect, error) {
+	design := DesignExpect{}
+
+	var n int
+	if de.Kill != nil {
+		n++
+
+		design.Type = ExpectKillType
+		design.Pattern = strings.TrimSpace(*de.Kill)
+	}
+
+	if de.Stderr != nil {
+		n++
+
+		design.Type = ExpectStderrType
+		if de.Stderr.Node != nil {
+			design.Node = strings.TrimSpace(*de.Stderr.Node)
+		}
+
+		if de.Stderr.Pattern != nil {
+			design.Pattern = strings.TrimSpace(*de.Stderr.Pattern)
+		}
+	}
+
+	if de.ExitCode != nil {
+		n++
+
+		design.Type = ExpectExitCodeType
+		if de.ExitCode.Node != nil {
+			design.Node = strings.TrimSpace(*de.ExitCode.Node)
+		}
+
+		if de.ExitCode.Code == nil {
+			return design, errors.Errorf("empty exit code for expect")
+		}
+		design.Code = *de.ExitCode.Code
+	}
+
+	if n > 1 {
+		return design, errors.Errorf("only one of kill, stderr and exit-code is allowed in expect")
+	}
+
+	return design, nil
+}
+
+type DesignDiskYAML struct {
+	Type *string
+	Size *string
+}
+
+// Merge overrides base with the given values.
+func (de DesignDiskYAML) Merge(base DesignDisk) (DesignDisk, error) {
+	design := base
+
+	if de.Type != nil {
+		design.Type = strings.TrimSpace(*de.Type)
+	}
+
+	if de.Size != nil {
+		i, err := ParseByteSize(*de.Size)
+		if err != nil {
+			return design, err
+		}
+		design.Size = i
+	}
+
+	return design, nil
+}
+
+type DesignClockYAML struct {
+	Offset *string
+	Drift  *float64
+}
+
+// Merge overrides base with the given values.
+func (de DesignClockYAML) Merge(base DesignClock) (DesignClock, error) {
+	design := base
+
+	if de.Offset != nil {
+		i, err := time.ParseDuration(strings.TrimSpace(*de.Offset))
+		if err != nil {
+			return design, errors.Wrap(err, "invalid clock offset")
+		}
+		design.Offset = i
+	}
+
+	if de.Drift != nil {
+		design.Drift = *de.Drift
+	}
+
+	return design, nil
+}
+
+// ParseDesignClock parses the clock design from the extra of action over the
+// base design.
+func ParseDesignClock(m map[string]interface{}, base DesignClock) (DesignClock, error) {
+	var de DesignClockYAML
+	if b, err := yaml.Marshal(m); err != nil {
+		return DesignClock{}, errors.Wrap(err, "invalid yaml for clock")
+	} else if err := yaml.Unmarshal(b, &de); err != nil {
+		return DesignClock{}, errors.Wrap(err, "invalid DesignClockYAML")
+	}
+
+	design, err := de.Merge(base)
+	if err != nil {
+		return design, err
+	}
+
+	return design, design.IsValid(nil)
+}
+
+type DesignConsistencyYAML struct {
+	Nodes      []string
+	Storage    *string
+	Col        *string
+	Fields     []string
+	Height     *int64
+	AtShutdown *bool `yaml:"at-shutdown"`
+}
+
+// Merge overrides base with the given values.
+func (de DesignConsistencyYAML) Merge(base DesignConsistency) (DesignConsistency, error) {
+	design := base
+
+	if de.Nodes != nil {
+		design.Nodes = de.Nodes
+	}
+
+	if de.Storage != nil {
+		design.Storage = strings.TrimSpace(*de.Storage)
+	}
+
+	if de.Col != nil {
+		design.Col = strings.TrimSpace(*de.Col)
+	}
+
+	if de.Fields != nil {
+		design.Fields = de.Fields
+	}
+
+	if de.Height != nil {
+		design.Height = *de.Height
+	}
+
+	if de.AtShutdown != nil {
+		design.AtShutdown = *de.AtShutdown
+	}
+
+	return design, nil
+}
+
+// ParseDesignConsistency parses the consistency design from the extra of
+// action over the base design.
+func ParseDesignConsistency(m map[string]interface{}, base DesignConsistency) (DesignConsistency, error) {
+	var de DesignConsistencyYAML
+	if b, err := yaml.Marshal(m); err != nil {
+		return DesignConsistency{}, errors.Wrap(err, "invalid yaml for consistency")
+	} else if err := yaml.Unmarshal(b, &de); err != nil {
+		return DesignConsistency{}, errors.Wrap(err, "invalid DesignConsistencyYAML")
+	}
+
+	design, err := de.Merge(base)
+	if err != nil {
+		return design, err
+	}
+
+	if err := design.IsValid(nil); err != nil {
+		return design, err
+	}
+
+	return design, nil
+}
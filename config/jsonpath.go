@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// LookupJSONPath finds the value by the simple JSONPath, like
+// "$.block.manifests[0].hash". The leading "$" can be omitted. Only the child
+// and array index operators are supported.
+func LookupJSONPath(v interface{}, path string) (interface{}, bool, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	m := v
+	for _, t := range tokens {
+		switch k := t.(type) {
+		case string:
+			i, ok := m.(map[string]interface{})
+			if !ok {
+				return nil, false, nil
+			}
+
+			j, found := i[k]
+			if !found {
+				return nil, false, nil
+			}
+			m = j
+		case int:
+			i, ok := m.([]interface{})
+			if !ok || k >= len(i) {
+				return nil, false, nil
+			}
+			m = i[k]
+		}
+	}
+
+	return m, true, nil
+}
+
+// EqualJSONValue compares the values by their JSON representation, so the
+// numbers from YAML and JSON can be compared.
+func EqualJSONValue(a, b interface{}) bool {
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(ab, bb)
+}
+
+func parseJSONPath(path string) ([]interface{}, error) {
+	s := strings.TrimSpace(path)
+	s = strings.TrimPrefix(s, "$")
+
+	if len(s) > 0 && s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	var tokens []interface{}
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+
+			n := strings.IndexAny(s, ".[")
+			if n < 0 {
+				n = len(s)
+			}
+
+			if n < 1 {
+				return nil, errors.Errorf("empty key in json path, %q", path)
+			}
+
+			tokens = append(tokens, s[:n])
+			s = s[n:]
+		case '[':
+			n := strings.Index(s, "]")
+			if n < 0 {
+				return nil, errors.Errorf("unclosed bracket in json path, %q", path)
+			}
+
+			i, err := strconv.Atoi(strings.TrimSpace(s[1:n]))
+			if err != nil || i < 0 {
+				return nil, errors.Errorf("invalid index in json path, %q", path)
+			}
+
+			tokens = append(tokens, i)
+			s = s[n+1:]
+		default:
+			return nil, errors.Errorf("invalid json path, %q", path)
+		}
+	}
+
+	return tokens, nil
+}
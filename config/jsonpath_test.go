@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type testJSONPath struct {
+	suite.Suite
+}
+
+func (t *testJSONPath) TestLookup() {
+	var v interface{}
+	t.NoError(json.Unmarshal([]byte(`{"a": {"b": [{"c": 3}, {"c": "showme"}]}, "d": 1.5}`), &v))
+
+	cases := []struct {
+		name     string
+		path     string
+		expected interface{}
+		found    bool
+		err      string
+	}{
+		{name: "root", path: "$", expected: v, found: true},
+		{name: "simple", path: "$.d", expected: 1.5, found: true},
+		{name: "without $", path: "d", expected: 1.5, found: true},
+		{name: "nested index", path: "$.a.b[0].c", expected: float64(3), found: true},
+		{name: "nested index #1", path: "a.b[1].c", expected: "showme", found: true},
+		{name: "not found", path: "$.a.e", found: false},
+		{name: "index out of range", path: "$.a.b[2]", found: false},
+		{name: "index on map", path: "$.a[0]", found: false},
+		{name: "empty key", path: "$.a..b", err: "empty key"},
+		{name: "unclosed", path: "$.a.b[0", err: "unclosed bracket"},
+		{name: "bad index", path: "$.a.b[k]", err: "invalid index"},
+	}
+
+	for i, c := range cases {
+		i := i
+		c := c
+		t.Run(
+			c.name,
+			func() {
+				r, found, err := LookupJSONPath(v, c.path)
+				if len(c.err) > 0 {
+					t.Error(err, "%d: %s", i, c.name)
+					t.Contains(err.Error(), c.err, "%d: %s", i, c.name)
+
+					return
+				}
+
+				t.NoError(err, "%d: %s", i, c.name)
+				t.Equal(c.found, found, "%d: %s", i, c.name)
+				t.Equal(c.expected, r, "%d: %s: %v != %v", i, c.name, c.expected, r)
+			},
+		)
+	}
+}
+
+func (t *testJSONPath) TestEqualJSONValue() {
+	t.True(EqualJSONValue(3, float64(3)))
+	t.True(EqualJSONValue("a", "a"))
+	t.True(EqualJSONValue(map[string]interface{}{"a": 1}, map[string]interface{}{"a": 1.0}))
+	t.False(EqualJSONValue(3, "3"))
+	t.False(EqualJSONValue(3, 3.1))
+}
+
+func TestJSONPath(t *testing.T) {
+	suite.Run(t, new(testJSONPath))
+}
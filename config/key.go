@@ -0,0 +1,34 @@
+package config
+
+import (
+	"crypto/sha256"
+	"math/big"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+)
+
+// seededWIF derives the compressed WIF of secp256k1 privatekey from string.
+// key.NewBasePrivatekeyFromSeed can not be used, because ecdsa.GenerateKey
+// does not produce the same key from the same reader.
+func seededWIF(s string) (string, error) {
+	h := sha256.Sum256([]byte(s))
+	for {
+		d := new(big.Int).SetBytes(h[:])
+		if d.Sign() > 0 && d.Cmp(btcec.S256().N) < 0 {
+			break
+		}
+
+		h = sha256.Sum256(h[:])
+	}
+
+	priv, _ := btcec.PrivKeyFromBytes(btcec.S256(), h[:])
+
+	wif, err := btcutil.NewWIF(priv, &chaincfg.MainNetParams, true)
+	if err != nil {
+		return "", err
+	}
+
+	return wif.String(), nil
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 )
@@ -91,3 +93,51 @@ func copyValue(v reflect.Value) reflect.Value {
 func CopyValue(i interface{}) interface{} {
 	return copyValue(reflect.ValueOf(i)).Interface()
 }
+
+// MapDiff is the changed value of map; Path is the keys joined by ".".
+type MapDiff struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// DiffMap returns the changed values from a to b, sorted by path.
+func DiffMap(a, b map[string]interface{}) []MapDiff {
+	return diffMap(nil, a, b)
+}
+
+func diffMap(prefix []string, a, b map[string]interface{}) []MapDiff {
+	keys := map[string]struct{}{}
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diffs []MapDiff
+	for _, k := range sorted {
+		path := append(append([]string{}, prefix...), k)
+
+		am, aok := a[k].(map[string]interface{})
+		bm, bok := b[k].(map[string]interface{})
+		if aok && bok {
+			diffs = append(diffs, diffMap(path, am, bm)...)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(a[k], b[k]) {
+			diffs = append(diffs, MapDiff{Path: strings.Join(path, "."), Old: a[k], New: b[k]})
+		}
+	}
+
+	return diffs
+}
@@ -90,6 +90,63 @@ func (t *testMap) TestMerge() {
 	}
 }
 
+func (t *testMap) TestDiff() {
+	cases := []struct {
+		name     string
+		a        map[string]interface{}
+		b        map[string]interface{}
+		expected []MapDiff
+	}{
+		{
+			name: "nil",
+		},
+		{
+			name: "same",
+			a:    map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": []interface{}{1, 2}}},
+			b:    map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": []interface{}{1, 2}}},
+		},
+		{
+			name:     "added",
+			a:        map[string]interface{}{"a": 1},
+			b:        map[string]interface{}{"a": 1, "b": 2},
+			expected: []MapDiff{{Path: "b", New: 2}},
+		},
+		{
+			name:     "removed",
+			a:        map[string]interface{}{"a": 1, "b": 2},
+			b:        map[string]interface{}{"a": 1},
+			expected: []MapDiff{{Path: "b", Old: 2}},
+		},
+		{
+			name: "nested",
+			a:    map[string]interface{}{"a": map[string]interface{}{"b": 1, "c": map[string]interface{}{"d": "e"}}},
+			b:    map[string]interface{}{"a": map[string]interface{}{"b": 2, "c": map[string]interface{}{"d": "f"}}},
+			expected: []MapDiff{
+				{Path: "a.b", Old: 1, New: 2},
+				{Path: "a.c.d", Old: "e", New: "f"},
+			},
+		},
+		{
+			name:     "map to value",
+			a:        map[string]interface{}{"a": map[string]interface{}{"b": 1}},
+			b:        map[string]interface{}{"a": 1},
+			expected: []MapDiff{{Path: "a", Old: map[string]interface{}{"b": 1}, New: 1}},
+		},
+	}
+
+	for i, c := range cases {
+		i := i
+		c := c
+		t.Run(
+			c.name,
+			func() {
+				d := DiffMap(c.a, c.b)
+				t.Equal(c.expected, d, "%d: %s: %v != %v", i, c.name, c.expected, d)
+			},
+		)
+	}
+}
+
 func TestMap(t *testing.T) {
 	suite.Run(t, new(testMap))
 }
@@ -0,0 +1,129 @@
+package config
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+)
+
+var (
+	seed      int64
+	seedRands map[ /* purpose */ string]*rand.Rand
+	seedLock  sync.Mutex
+)
+
+func init() {
+	SetSeed(NewSeed())
+}
+
+// NewSeed returns new random seed.
+func NewSeed() int64 {
+	var b [8]byte
+	if _, err := crand.Read(b[:]); err != nil {
+		panic(err)
+	}
+
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
+
+// SetSeed sets the global random source of contest. With the same seed, the
+// keys, the entropy of ULIDs and test name, and the random choices are same.
+// Each purpose has it's own source derived from the seed, so the random
+// numbers of one purpose are not changed by the others.
+func SetSeed(s int64) {
+	seedLock.Lock()
+	defer seedLock.Unlock()
+
+	seed = s
+	seedRands = map[string]*rand.Rand{}
+
+	resetULIDEntropy(rand.New(rand.NewSource(subSeed(s, "ulid")))) // nolint:gosec
+}
+
+func Seed() int64 {
+	seedLock.Lock()
+	defer seedLock.Unlock()
+
+	return seed
+}
+
+// SubSeed returns the seed for the purpose, which is derived from the seed.
+func SubSeed(purpose string) int64 {
+	seedLock.Lock()
+	defer seedLock.Unlock()
+
+	return subSeed(seed, purpose)
+}
+
+func subSeed(s int64, purpose string) int64 {
+	h := sha256.Sum256([]byte(fmt.Sprintf("contest-seed-%020d-%s", s, purpose)))
+
+	return int64(binary.BigEndian.Uint64(h[:8]) >> 1)
+}
+
+// seedRand returns the source of the purpose; seedLock should be locked.
+func seedRand(purpose string) *rand.Rand {
+	r, found := seedRands[purpose]
+	if !found {
+		r = rand.New(rand.NewSource(subSeed(seed, purpose))) // nolint:gosec
+		seedRands[purpose] = r
+	}
+
+	return r
+}
+
+func randIntn(purpose string, n int) int {
+	if n < 1 {
+		return 0
+	}
+
+	seedLock.Lock()
+	defer seedLock.Unlock()
+
+	return seedRand(purpose).Intn(n)
+}
+
+// NewTestName returns new test name. It is the ULID of the current time, so
+// the runs with the same seed have the different test names, but the entropy
+// of it is from the seed.
+func NewTestName() string {
+	seedLock.Lock()
+	defer seedLock.Unlock()
+
+	return ulid.MustNew(ulid.Timestamp(time.Now()), seedRand("test-name")).String()
+}
+
+// RandInt returns random int in [0, n) from the seeded source.
+func RandInt(n int) int {
+	return randIntn("RandInt", n)
+}
+
+// RandChoice returns one of items from the seeded source. If only one slice
+// is given, one of it's items is returned.
+func RandChoice(items ...interface{}) (interface{}, error) {
+	if len(items) == 1 {
+		v := reflect.ValueOf(items[0])
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			l := make([]interface{}, v.Len())
+			for i := 0; i < v.Len(); i++ {
+				l[i] = v.Index(i).Interface()
+			}
+
+			items = l
+		}
+	}
+
+	if len(items) < 1 {
+		return nil, errors.Errorf("empty items for RandChoice")
+	}
+
+	return items[randIntn("RandChoice", len(items))], nil
+}
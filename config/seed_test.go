@@ -0,0 +1,156 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/suite"
+)
+
+type testSeed struct {
+	suite.Suite
+	old int64
+}
+
+func (t *testSeed) SetupTest() {
+	t.old = Seed()
+}
+
+func (t *testSeed) TearDownTest() {
+	SetSeed(t.old)
+}
+
+func (t *testSeed) TestRandInt() {
+	SetSeed(33)
+
+	a := make([]int, 10)
+	for i := range a {
+		a[i] = RandInt(100)
+	}
+
+	SetSeed(33)
+
+	for i := range a {
+		t.Equal(a[i], RandInt(100))
+	}
+}
+
+func (t *testSeed) TestRandChoice() {
+	SetSeed(33)
+
+	a, err := RandChoice("a", "b", "c")
+	t.NoError(err)
+	b, err := RandChoice([]string{"a", "b", "c"})
+	t.NoError(err)
+
+	SetSeed(33)
+
+	i, err := RandChoice([]interface{}{"a", "b", "c"})
+	t.NoError(err)
+	t.Equal(a, i)
+
+	i, err = RandChoice("a", "b", "c")
+	t.NoError(err)
+	t.Equal(b, i)
+
+	_, err = RandChoice()
+	t.Contains(err.Error(), "empty items")
+}
+
+func (t *testSeed) TestSubSources() {
+	SetSeed(33)
+
+	a := make([]int, 10)
+	for i := range a {
+		a[i] = RandInt(100)
+	}
+
+	SetSeed(33)
+
+	// NOTE the other purposes do not change RandInt
+	for i := range a {
+		_, err := RandChoice("a", "b", "c")
+		t.NoError(err)
+		_ = ULID()
+		_ = NewTestName()
+
+		t.Equal(a[i], RandInt(100))
+	}
+
+	t.Equal(SubSeed("chaos"), SubSeed("chaos"))
+	t.NotEqual(SubSeed("chaos"), SubSeed("ulid"))
+
+	i := SubSeed("chaos")
+	SetSeed(34)
+	t.NotEqual(i, SubSeed("chaos"))
+}
+
+func (t *testSeed) TestNewTestName() {
+	SetSeed(33)
+	a, err := ulid.Parse(NewTestName())
+	t.NoError(err)
+
+	SetSeed(33)
+	_ = ULID()
+	b, err := ulid.Parse(NewTestName())
+	t.NoError(err)
+
+	t.Equal(a.Entropy(), b.Entropy())
+
+	SetSeed(34)
+	c, err := ulid.Parse(NewTestName())
+	t.NoError(err)
+
+	t.NotEqual(a.Entropy(), c.Entropy())
+}
+
+func (t *testSeed) TestULIDEntropy() {
+	SetSeed(33)
+	a := ULID()
+
+	SetSeed(33)
+	b := ULID()
+
+	t.Equal(a.Entropy(), b.Entropy())
+}
+
+func (t *testSeed) TestNewKey() {
+	SetSeed(33)
+
+	a, err := NewSeededKey("Design.Node.no0")
+	t.NoError(err)
+
+	b, err := NewSeededKey("Design.Node.no1")
+	t.NoError(err)
+	t.NotEqual(a.String(), b.String())
+
+	vars := NewVars(nil)
+	i, err := CompileTemplate(`{{ NewKey "Design.Node.no0" }}`, vars)
+	t.NoError(err)
+	t.Equal(a.String(), string(i))
+
+	SetSeed(34)
+
+	c, err := NewSeededKey("Design.Node.no0")
+	t.NoError(err)
+	t.NotEqual(a.String(), c.String())
+}
+
+func (t *testSeed) TestTemplate() {
+	SetSeed(33)
+
+	vars := NewVars(map[string]interface{}{"Nodes": []interface{}{"no0", "no1", "no2"}})
+
+	a, err := CompileTemplate(`{{ RandInt 10 }} {{ RandChoice .Nodes }}`, vars)
+	t.NoError(err)
+
+	SetSeed(33)
+
+	b, err := CompileTemplate(`{{ RandInt 10 }} {{ RandChoice .Nodes }}`, vars)
+	t.NoError(err)
+	t.Equal(string(a), string(b))
+}
+
+func TestSeed(t *testing.T) {
+	suite.Run(t, new(testSeed))
+}
@@ -1,19 +1,47 @@
 package config
 
 import (
-	"crypto/rand"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/oklog/ulid"
 )
 
-var entropy io.Reader
+var (
+	entropy     io.Reader
+	entropyLock sync.Mutex
+)
+
+func resetULIDEntropy(r io.Reader) {
+	entropyLock.Lock()
+	defer entropyLock.Unlock()
 
-func init() {
-	entropy = ulid.Monotonic(rand.Reader, 0)
+	entropy = ulid.Monotonic(r, 0)
 }
 
 func ULID() ulid.ULID {
+	entropyLock.Lock()
+	defer entropyLock.Unlock()
+
 	return ulid.MustNew(ulid.Timestamp(time.Now()), entropy)
 }
+
+// ULIDTime returns the time of ULID string.
+func ULIDTime(s string) (time.Time, error) {
+	id, err := ulid.Parse(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return ulid.Time(id.Time()), nil
+}
+
+// MaxULIDAt returns the biggest ULID at the given time.
+func MaxULIDAt(t time.Time) ulid.ULID {
+	var id ulid.ULID
+	_ = id.SetTime(ulid.Timestamp(t))
+	_ = id.SetEntropy([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	return id
+}
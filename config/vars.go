@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"regexp"
 	"strings"
@@ -269,11 +270,27 @@ func (vs *Vars) baseFuncMap() template.FuncMap {
 				return i.(key.Privatekey)
 			}
 
-			k := key.NewBasePrivatekey()
+			k, err := NewSeededKey(keys)
+			if err != nil {
+				panic(err)
+			}
 
 			_ = setVar(vs.m, keys, k)
 
 			return k
 		},
+		"RandInt":    RandInt,
+		"RandChoice": RandChoice,
 	}
 }
+
+// NewSeededKey makes new privatekey from the global seed and name, so the same
+// seed and name makes the same key.
+func NewSeededKey(name string) (key.Privatekey, error) {
+	wif, err := seededWIF(fmt.Sprintf("contest-key-seed-%020d-%s", Seed(), name))
+	if err != nil {
+		return nil, err
+	}
+
+	return key.LoadBasePrivatekey(wif)
+}
@@ -4,6 +4,8 @@ go 1.16
 
 require (
 	github.com/alecthomas/kong v0.2.20
+	github.com/btcsuite/btcd v0.22.0-beta
+	github.com/btcsuite/btcutil v1.0.3-0.20201208143702-a53e38424cce
 	github.com/docker/docker v23.0.3+incompatible
 	github.com/docker/go-connections v0.4.0
 	github.com/hpcloud/tail v1.0.0
@@ -0,0 +1,183 @@
+package host
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+)
+
+var (
+	ntpEpochOffset int64 = 2208988800 // NOTE seconds from 1900 to 1970
+	ntpPacketSize        = 48
+	ntpPort              = 123 // NOTE mitum does not allow the port of time server
+)
+
+// Clock is the skewed time of node.
+type Clock struct {
+	sync.RWMutex
+	design config.DesignClock
+	since  time.Time
+}
+
+func NewClock(design config.DesignClock) *Clock {
+	return &Clock{design: design, since: time.Now()}
+}
+
+func (c *Clock) Design() config.DesignClock {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.design
+}
+
+// Skew changes the clock with the offset and drift of m; drift is accumulated
+// from now. The omitted offset keeps the current effective offset, so changing
+// only drift does not make the clock jump.
+func (c *Clock) Skew(m map[string]interface{}) (config.DesignClock, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+
+	base := c.design
+	base.Offset = c.design.OffsetAt(now.Sub(c.since))
+
+	design, err := config.ParseDesignClock(m, base)
+	if err != nil {
+		return config.DesignClock{}, err
+	}
+
+	c.design = design
+	c.since = now
+
+	return design, nil
+}
+
+// Offset returns the effective offset of clock.
+func (c *Clock) Offset() time.Duration {
+	if c == nil {
+		return 0
+	}
+
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.design.OffsetAt(time.Since(c.since))
+}
+
+func (c *Clock) Now() time.Time {
+	return time.Now().Add(c.Offset())
+}
+
+// ClockServer is the fake ntp server for nodes. The node config,
+// "time-server" is set to it and the clock of node is found by the address of
+// request, so the node time is synced with the skewed time. The container
+// clock itself is not changed.
+//
+// NOTE mitum queries time server every 2 minutes, so the changed clock is
+// applied to node within 2 minutes. mitum does not allow the port of time
+// server, so contest should run as root to listen udp 123 port.
+type ClockServer struct {
+	*logging.Logging
+	ip     string
+	lookup func(net.IP) (string /* node alias */, *Clock)
+	conn   *net.UDPConn
+}
+
+func NewClockServer(ip string, lookup func(net.IP) (string, *Clock)) *ClockServer {
+	return &ClockServer{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "clock-server").Str("ip", ip)
+		}),
+		ip:     ip,
+		lookup: lookup,
+	}
+}
+
+func (cs *ClockServer) Start() error {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP(cs.ip), Port: ntpPort})
+	if err != nil {
+		return errors.Wrapf(err, "failed to listen clock server; udp %d port requires root", ntpPort)
+	}
+
+	cs.conn = conn
+
+	go cs.serve()
+
+	return nil
+}
+
+func (cs *ClockServer) Close() error {
+	if cs.conn == nil {
+		return nil
+	}
+
+	return cs.conn.Close()
+}
+
+// IP returns the ip of server; it is used for "time-server" of node config.
+func (cs *ClockServer) IP() string {
+	return cs.ip
+}
+
+func (cs *ClockServer) serve() {
+	b := make([]byte, 512)
+
+	for {
+		n, addr, err := cs.conn.ReadFromUDP(b)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				cs.Log().Error().Err(err).Msg("failed to read ntp request")
+			}
+
+			return
+		}
+
+		if n < ntpPacketSize {
+			continue
+		}
+
+		alias, c := cs.lookup(addr.IP)
+		received := c.Now()
+
+		if _, err := cs.conn.WriteToUDP(cs.response(c, b[:ntpPacketSize], received), addr); err != nil {
+			cs.Log().Error().Err(err).Msg("failed to write ntp response")
+
+			continue
+		}
+
+		cs.Log().Debug().Stringer("from", addr).Str("node", alias).Dur("offset", c.Offset()).Msg("ntp queried")
+	}
+}
+
+func (*ClockServer) response(c *Clock, req []byte, received time.Time) []byte {
+	b := make([]byte, ntpPacketSize)
+
+	version := (req[0] >> 3) & 0x07
+	b[0] = version<<3 | 4 // NOTE no leap warning and server mode
+	b[1] = 1              // NOTE stratum, primary server
+	b[2] = req[2]         // NOTE poll
+	b[3] = 0xec           // NOTE precision, -20
+	copy(b[12:16], "LOCL")
+
+	binary.BigEndian.PutUint64(b[16:24], toNTPTime(received)) // NOTE reference time
+	copy(b[24:32], req[40:48])                                // NOTE origin time
+	binary.BigEndian.PutUint64(b[32:40], toNTPTime(received)) // NOTE receive time
+	binary.BigEndian.PutUint64(b[40:48], toNTPTime(c.Now()))  // NOTE transmit time
+
+	return b
+}
+
+func toNTPTime(t time.Time) uint64 {
+	sec := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond()) << 32) / uint64(time.Second)
+
+	return sec<<32 | frac
+}
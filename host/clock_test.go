@@ -0,0 +1,153 @@
+package host
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+)
+
+type testClock struct {
+	suite.Suite
+}
+
+func fromNTPTime(i uint64) time.Time {
+	sec := int64(i>>32) - ntpEpochOffset
+	nsec := int64(((i & 0xffffffff) * uint64(time.Second)) >> 32)
+
+	return time.Unix(sec, nsec)
+}
+
+func (t *testClock) TestToNTPTime() {
+	cases := []struct {
+		name     string
+		t        time.Time
+		expected uint64
+	}{
+		{name: "unix epoch", t: time.Unix(0, 0), expected: uint64(ntpEpochOffset) << 32},
+		{name: "half second", t: time.Unix(1, int64(time.Second/2)), expected: uint64(ntpEpochOffset+1)<<32 | 1<<31},
+		{name: "quarter second", t: time.Unix(2, int64(time.Second/4)), expected: uint64(ntpEpochOffset+2)<<32 | 1<<30},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			t.Equal(c.expected, toNTPTime(c.t))
+		})
+	}
+
+	now := time.Now()
+	d := fromNTPTime(toNTPTime(now)).Sub(now)
+	t.True(d > -time.Microsecond && d < time.Microsecond)
+}
+
+func (t *testClock) TestResponse() {
+	offset := time.Hour
+
+	c := NewClock(config.DesignClock{Offset: offset})
+	cs := NewClockServer("127.0.0.1", nil)
+
+	req := make([]byte, ntpPacketSize)
+	req[0] = 0<<6 | 4<<3 | 3 // NOTE no leap, version 4 and client mode
+	req[2] = 6               // NOTE poll
+	binary.BigEndian.PutUint64(req[40:48], toNTPTime(time.Now()))
+
+	received := c.Now()
+	b := cs.response(c, req, received)
+	t.Equal(ntpPacketSize, len(b))
+
+	t.Equal(byte(4<<3|4), b[0], "version and server mode")
+	t.Equal(byte(1), b[1], "stratum")
+	t.Equal(req[2], b[2], "poll")
+	t.Equal(byte(0xec), b[3], "precision")
+	t.Equal("LOCL", string(b[12:16]))
+
+	t.Equal(toNTPTime(received), binary.BigEndian.Uint64(b[16:24]), "reference time")
+	t.Equal(req[40:48], b[24:32], "origin time")
+	t.Equal(toNTPTime(received), binary.BigEndian.Uint64(b[32:40]), "receive time")
+
+	transmit := fromNTPTime(binary.BigEndian.Uint64(b[40:48]))
+	d := transmit.Sub(time.Now().Add(offset))
+	t.True(d > -time.Second && d < time.Second, "transmit time has offset")
+}
+
+func (t *testClock) TestLookupClock() {
+	ho := &LocalHost{nodes: map[string]*Node{}}
+
+	for _, i := range []struct {
+		alias string
+		ip    string
+		clock *Clock
+	}{
+		{alias: "no0", ip: "172.20.0.3", clock: NewClock(config.DesignClock{Offset: time.Hour})},
+		{alias: "no1", ip: "172.20.0.4"},
+		{alias: "no2"}, // NOTE not started
+	} {
+		no, err := NewNode(i.alias, ho)
+		t.NoError(err)
+
+		no.clock = i.clock
+		no.SetIP(i.ip)
+
+		ho.nodes[i.alias] = no
+	}
+
+	alias, c := ho.lookupClock(net.ParseIP("172.20.0.3"))
+	t.Equal("no0", alias)
+	t.Equal(time.Hour, c.Offset())
+
+	alias, c = ho.lookupClock(net.ParseIP("172.20.0.4"))
+	t.Equal("no1", alias)
+	t.Nil(c)
+
+	alias, c = ho.lookupClock(net.ParseIP("172.20.0.5"))
+	t.Empty(alias)
+	t.Nil(c)
+
+	// NOTE restarted node has new ip
+	ho.nodes["no0"].SetIP("172.20.0.5")
+
+	alias, _ = ho.lookupClock(net.ParseIP("172.20.0.5"))
+	t.Equal("no0", alias)
+
+	alias, _ = ho.lookupClock(net.ParseIP("172.20.0.3"))
+	t.Empty(alias)
+}
+
+func (t *testClock) TestSkewAfterDrift() {
+	c := NewClock(config.DesignClock{Offset: time.Second, Drift: 0.1})
+	c.since = c.since.Add(time.Second * -10) // NOTE 1s drifted
+
+	t.True(c.Offset() >= time.Second*2)
+
+	// NOTE omitted offset keeps the drifted offset
+	de, err := c.Skew(map[string]interface{}{"drift": 0.2})
+	t.NoError(err)
+	t.True(de.Offset >= time.Second*2 && de.Offset < time.Second*3)
+	t.Equal(0.2, de.Drift)
+	t.True(c.Offset() >= de.Offset)
+
+	c.since = c.since.Add(time.Second * -10) // NOTE 2s drifted
+
+	de, err = c.Skew(map[string]interface{}{"drift": 0})
+	t.NoError(err)
+	t.True(de.Offset >= time.Second*4 && de.Offset < time.Second*5)
+	t.Equal(float64(0), de.Drift)
+
+	de, err = c.Skew(map[string]interface{}{"offset": "-1m"})
+	t.NoError(err)
+	t.Equal(time.Minute*-1, de.Offset)
+	t.Equal(time.Minute*-1, c.Offset())
+
+	_, err = c.Skew(map[string]interface{}{"drift": -1})
+	t.Error(err)
+	t.Equal(time.Minute*-1, c.Offset(), "failed skew does not change clock")
+}
+
+func TestClock(t *testing.T) {
+	suite.Run(t, new(testClock))
+}
@@ -2,6 +2,8 @@ package host
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -10,29 +12,27 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-type Condition struct {
-	*logging.Logging
-	queryString   string
-	query         bson.M
-	storageString string
-	storage       *Mongodb
-	col           string
-}
-
-func NewCondition(ctx context.Context, q, storageURI, col string) (*Condition, error) {
-	if len(storageURI) < 1 {
-		var design config.Design
-		if err := config.LoadDesignContextValue(ctx, &design); err != nil {
-			return nil, err
-		}
+// conditionChecker checks the condition of one kind, like query, duration or
+// http.
+type conditionChecker interface {
+	String() string
+	// reset restarts the condition; anchor is the start time of the current
+	// iteration of loop and it is zero outside of loop.
+	reset(anchor time.Time)
+	check(context.Context, *config.Vars, func(string) (*Mongodb, error)) (interface{}, bool, error)
+}
 
-		storageURI = design.Storage.String()
-	}
+// conditionQuerier is the conditionChecker, which has the query.
+type conditionQuerier interface {
+	query(*config.Vars) (bson.M, error)
+}
 
-	if len(col) < 1 {
-		col = colLogEntry
-	}
+type Condition struct {
+	*logging.Logging
+	checker conditionChecker
+}
 
+func NewCondition(ctx context.Context, design config.DesignCondition) (*Condition, error) {
 	var log *logging.Logging
 	if err := config.LoadLogContextValue(ctx, &log); err != nil {
 		return nil, err
@@ -61,82 +61,286 @@ func NewCondition(ctx context.Context, q, storageURI, col string) (*Condition, e
 		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
 			return c.
 				Str("module", "condition").
-				Str("query", q)
+				Str("query", design.Query)
 		}),
-		queryString:   q,
-		storageString: storageURI,
-		col:           col,
 	}
 
+	checker, err := newConditionChecker(ctx, design, hosts, co.Logging)
+	if err != nil {
+		return nil, err
+	}
+	co.checker = checker
+
 	_ = co.SetLogging(log)
 
 	return co, nil
 }
 
+func newConditionChecker(
+	ctx context.Context, design config.DesignCondition, hosts *Hosts, log *logging.Logging,
+) (conditionChecker, error) {
+	switch {
+	case len(design.NodeState) > 0:
+		return &nodeStateCondition{expected: design.NodeState, states: hosts.NodeStates()}, nil
+	case len(design.Resource) > 0:
+		return newResourceCondition(ctx, design.Resource)
+	case design.Consistency != nil:
+		return &consistencyCondition{design: *design.Consistency, hosts: hosts}, nil
+	case design.HTTP != nil:
+		return &httpCondition{Logging: log, design: *design.HTTP}, nil
+	case design.Duration > 0:
+		return &durationCondition{duration: design.Duration, started: time.Now()}, nil
+	default:
+		return newStorageCondition(ctx, design, hosts, log)
+	}
+}
+
 func (co *Condition) QueryString() string {
-	return co.queryString
+	return co.checker.String()
+}
+
+// Reset restarts the condition, like the duration, quiet and consistency
+// condition, and the template query will be compiled again with the latest
+// vars. If anchor is not zero, the query of the contest log entries matches
+// only the records created after anchor; the iterations of loop use it, so the
+// records of the previous iterations are not matched again.
+func (co *Condition) Reset(anchor time.Time) {
+	co.checker.reset(anchor)
 }
 
+// Query returns the compiled query; the condition without query returns nil.
 func (co *Condition) Query(vars *config.Vars) (bson.M, error) {
-	if co.query != nil {
-		return co.query, nil
+	i, ok := co.checker.(conditionQuerier)
+	if !ok {
+		return nil, nil
 	}
 
-	if !config.IsTemplateCondition(co.queryString) {
-		i, err := config.ParseConditionQuery(co.queryString)
-		if err != nil {
-			return nil, errors.Wrapf(err, "invalid compiled condition query string, %q", co.queryString)
-		}
-		co.query = i
+	return i.query(vars)
+}
+
+func (co *Condition) Check(
+	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) (interface{}, bool, error) {
+	return co.checker.check(ctx, vars, getStorage)
+}
+
+// durationCondition is matched after the duration since it is reset.
+type durationCondition struct {
+	duration time.Duration
+	started  time.Time
+}
+
+func (c *durationCondition) String() string {
+	return fmt.Sprintf("duration: %s", c.duration)
+}
+
+func (c *durationCondition) reset(time.Time) {
+	c.started = time.Now()
+}
+
+func (c *durationCondition) check(context.Context, *config.Vars, func(string) (*Mongodb, error)) (
+	interface{}, bool, error,
+) {
+	if time.Since(c.started) < c.duration {
+		return nil, false, nil
 	}
 
-	b, err := config.CompileTemplate(co.queryString, vars)
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to compile condition query, %q", co.queryString)
+	return timeRecord("duration", c.duration), true, nil
+}
+
+// httpCondition polls the http endpoint by interval. The failed request or
+// the unexpected response is regarded as not matched.
+type httpCondition struct {
+	*logging.Logging
+	design     config.DesignHTTP
+	lastPolled time.Time
+}
+
+func (c *httpCondition) String() string {
+	return fmt.Sprintf("http: %s %s", c.design.Method, c.design.URL)
+}
+
+func (c *httpCondition) reset(time.Time) {
+	c.lastPolled = time.Time{}
+}
+
+func (c *httpCondition) check(ctx context.Context, vars *config.Vars, _ func(string) (*Mongodb, error)) (
+	interface{}, bool, error,
+) {
+	if time.Since(c.lastPolled) < c.design.Interval {
+		return nil, false, nil
+	}
+	c.lastPolled = time.Now()
+
+	res, err := HTTPRequest(ctx, c.design, vars)
+	if err == nil {
+		err = c.design.CheckResponse(res.Status, res.Body)
 	}
 
-	i, err := config.ParseConditionQuery(string(b))
 	if err != nil {
-		return nil, errors.Wrapf(err, "invalid compiled condition query string, %q", co.queryString)
+		c.Log().Debug().Err(err).Msg("http condition not matched")
+
+		return nil, false, nil
 	}
-	co.query = i
 
-	co.Log().Debug().Str("col", co.col).Interface("query", co.query).Msg("querying")
+	m := res.Map()
+	m["_id"] = config.ULID().String()
 
-	return co.query, nil
+	return m, true, nil
 }
 
-func (co *Condition) Check(
+// consistencyCondition checks the consistency of node storages by interval.
+// When all the nodes reach the height without divergence, it is matched; the
+// divergence is returned as error. The nodes are found when it is checked, so
+// the nodes added by add-nodes are also compared.
+type consistencyCondition struct {
+	design     config.DesignConsistency
+	hosts      *Hosts
+	checker    *ConsistencyChecker
+	lastPolled time.Time
+}
+
+func (c *consistencyCondition) String() string {
+	return fmt.Sprintf("consistency: %v over %d", c.design.Nodes, c.design.Height)
+}
+
+func (c *consistencyCondition) reset(time.Time) {
+	c.lastPolled = time.Time{}
+	c.checker = nil
+}
+
+func (c *consistencyCondition) check(
 	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
 ) (interface{}, bool, error) {
-	if co.storage == nil {
-		uri := co.storageString
-		if config.IsTemplateCondition(uri) {
-			i, err := config.CompileTemplate(uri, vars)
-			if err != nil {
-				return nil, false, errors.Wrap(err, "failed to compile storage uri")
-			}
-			uri = string(i)
-		}
+	if time.Since(c.lastPolled) < consistencyInterval {
+		return nil, false, nil
+	}
+	c.lastPolled = time.Now()
 
-		i, err := getStorage(uri)
+	if c.checker == nil {
+		nodes, err := ConsistencyNodes(c.hosts, c.design)
 		if err != nil {
 			return nil, false, err
 		}
-		co.storage = i
+
+		c.checker = NewConsistencyChecker(c.design, nodes, c.hosts)
 	}
 
-	query, err := co.Query(vars)
+	result, err := c.checker.Check(ctx, vars, getStorage)
 	if err != nil {
 		return nil, false, err
 	}
 
-	switch i, found, err := co.storage.Find(ctx, co.col, query); {
-	case err != nil:
-		co.Log().Error().Err(err).Msg("failed to find condition")
+	if result.MinHeight() < c.design.Height {
+		return nil, false, nil
+	}
 
-		return nil, false, err
-	default:
-		return i, found, nil
+	m := result.Map()
+	m["_id"] = config.ULID().String()
+
+	return m, true, nil
+}
+
+// nodeStateCondition checks whether all the nodes are in the expected states.
+type nodeStateCondition struct {
+	expected map[string]string
+	states   *NodeStates
+}
+
+func (c *nodeStateCondition) String() string {
+	return fmt.Sprintf("node-state: %v", c.expected)
+}
+
+func (*nodeStateCondition) reset(time.Time) {}
+
+func (c *nodeStateCondition) check(context.Context, *config.Vars, func(string) (*Mongodb, error)) (
+	interface{}, bool, error,
+) {
+	states := map[string]interface{}{}
+	for alias := range c.expected {
+		expected, code, err := config.ParseNodeStateCondition(c.expected[alias])
+		if err != nil {
+			return nil, false, err
+		}
+
+		r, restarts := c.states.State(alias)
+		if r.State != expected || (code != nil && r.ExitCode != *code) {
+			return nil, false, nil
+		}
+
+		states[alias] = map[string]interface{}{
+			"state":     r.State,
+			"exit_code": r.ExitCode,
+			"restarts":  restarts,
+		}
+	}
+
+	return map[string]interface{}{"_id": config.ULID().String(), "node_state": states}, true, nil
+}
+
+// resourceCondition checks the last samples of stats sampler; with "*" node,
+// any node can be matched.
+type resourceCondition struct {
+	resource string
+	design   config.DesignResource
+	stats    *StatsSampler
+}
+
+func newResourceCondition(ctx context.Context, resource string) (*resourceCondition, error) {
+	design, err := config.ParseDesignResource(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var stats *StatsSampler
+	if err := LoadStatsSamplerContextValue(ctx, &stats); err != nil {
+		return nil, errors.Wrap(err, "stats sampler not found for resource condition")
+	}
+
+	return &resourceCondition{resource: resource, design: design, stats: stats}, nil
+}
+
+func (c *resourceCondition) String() string {
+	return fmt.Sprintf("resource: %s", c.resource)
+}
+
+func (*resourceCondition) reset(time.Time) {}
+
+func (c *resourceCondition) check(context.Context, *config.Vars, func(string) (*Mongodb, error)) (
+	interface{}, bool, error,
+) {
+	var samples []StatsSample
+	if c.design.Node == "*" {
+		samples = c.stats.LatestAll()
+	} else if i, found := c.stats.Latest(c.design.Node); found {
+		samples = []StatsSample{i}
+	}
+
+	for i := range samples {
+		s := samples[i]
+		v := s.Value(c.design.Metric)
+		if !c.design.Match(v) {
+			continue
+		}
+
+		return map[string]interface{}{
+			"_id":      config.ULID().String(),
+			"resource": c.resource,
+			"node":     s.Node,
+			"metric":   c.design.Metric,
+			"value":    v,
+			"t":        s.T,
+		}, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// timeRecord makes the record for the time based conditions; it has new _id,
+// so the next condition can be ordered after it.
+func timeRecord(name string, d time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"_id": config.ULID().String(),
+		name:  d.String(),
 	}
 }
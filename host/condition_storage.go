@@ -0,0 +1,378 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/mitum/util/logging"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// withinGrace is the additional time for within of condition; the log entries
+// are saved in storage with small delay.
+var withinGrace = time.Second * 2
+
+// conditionStorage is the storage and collection, which the storage based
+// conditions query. With node, the storage of node is found when it is
+// connected; the nodes added by add-nodes do not exist when the sequences are
+// parsed.
+type conditionStorage struct {
+	uri     string
+	col     string
+	node    string
+	hosts   *Hosts
+	storage *Mongodb
+}
+
+// isLogEntries returns true if the records are the contest log entries, which
+// have ULID _id, so they can be ordered by _id.
+func (cs conditionStorage) isLogEntries() bool {
+	return len(cs.node) < 1 && cs.col == colLogEntry
+}
+
+func (cs *conditionStorage) connect(vars *config.Vars, getStorage func(string) (*Mongodb, error)) (*Mongodb, error) {
+	if cs.storage != nil {
+		return cs.storage, nil
+	}
+
+	uri := cs.uri
+	if len(cs.node) > 0 {
+		i, err := nodeStorageURI(cs.hosts, cs.node)
+		if err != nil {
+			return nil, err
+		}
+		uri = i
+	}
+
+	if config.IsTemplateCondition(uri) {
+		i, err := config.CompileTemplate(uri, vars)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to compile storage uri")
+		}
+		uri = string(i)
+	}
+
+	i, err := getStorage(uri)
+	if err != nil {
+		return nil, err
+	}
+	cs.storage = i
+
+	return i, nil
+}
+
+func newStorageCondition(
+	ctx context.Context, design config.DesignCondition, hosts *Hosts, log *logging.Logging,
+) (conditionChecker, error) {
+	cs := conditionStorage{uri: design.Storage, col: design.Col, node: design.Node, hosts: hosts}
+
+	if len(cs.uri) < 1 {
+		var cdesign config.Design
+		if err := config.LoadDesignContextValue(ctx, &cdesign); err != nil {
+			return nil, err
+		}
+
+		cs.uri = cdesign.Storage.String()
+	}
+
+	if len(cs.col) < 1 {
+		cs.col = colLogEntry
+	}
+
+	if len(design.Aggregate) > 0 {
+		return &aggregateCondition{Logging: log, conditionStorage: cs, pipeline: design.Aggregate}, nil
+	}
+
+	qc := &queryCondition{
+		Logging:          log,
+		conditionStorage: cs,
+		queryString:      design.Query,
+		after:            design.After,
+		within:           design.Within,
+	}
+
+	if len(qc.after) > 0 && !cs.isLogEntries() {
+		return nil, errors.Errorf("after can be used only for the contest log entries, not %q", cs.col)
+	}
+
+	switch {
+	case design.Quiet > 0:
+		if !cs.isLogEntries() {
+			return nil, errors.Errorf("quiet condition can be used only for the contest log entries, not %q", cs.col)
+		}
+
+		return &quietCondition{queryCondition: qc, quiet: design.Quiet, started: time.Now()}, nil
+	case design.Count > 0:
+		return &countCondition{queryCondition: qc, count: design.Count}, nil
+	default:
+		return qc, nil
+	}
+}
+
+// queryCondition is matched when the record, matched with query, is found.
+type queryCondition struct {
+	*logging.Logging
+	conditionStorage
+	queryString string
+	q           bson.M
+	after       string
+	within      time.Duration
+	deadline    time.Time
+	anchor      time.Time
+}
+
+func (c *queryCondition) String() string {
+	return c.queryString
+}
+
+func (c *queryCondition) reset(anchor time.Time) {
+	if config.IsTemplateCondition(c.queryString) || len(c.after) > 0 || !anchor.Equal(c.anchor) {
+		c.q = nil
+	}
+
+	c.anchor = anchor
+}
+
+func (c *queryCondition) query(vars *config.Vars) (bson.M, error) {
+	if c.q != nil {
+		return c.q, nil
+	}
+
+	s := c.queryString
+	if config.IsTemplateCondition(c.queryString) {
+		b, err := config.CompileTemplate(c.queryString, vars)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to compile condition query, %q", c.queryString)
+		}
+		s = string(b)
+	}
+
+	i, err := config.ParseConditionQuery(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid compiled condition query string, %q", c.queryString)
+	}
+
+	if len(c.after) > 0 {
+		j, err := c.queryAfter(i, vars)
+		if err != nil {
+			return nil, err
+		}
+		i = j
+	}
+
+	// NOTE the records, which do not have ULID _id can not be anchored, so
+	// the records of the previous iterations can be matched again.
+	if !c.anchor.IsZero() && c.isLogEntries() {
+		i = bson.M{"$and": bson.A{i, bson.M{"_id": bson.M{"$gt": config.MaxULIDAt(c.anchor).String()}}}}
+	}
+
+	c.q = i
+
+	c.Log().Debug().Str("col", c.col).Interface("query", c.q).Msg("querying")
+
+	return c.q, nil
+}
+
+func (c *queryCondition) queryAfter(q bson.M, vars *config.Vars) (bson.M, error) {
+	key := "Register.last_match"
+	if c.after != config.AfterPrevious {
+		key = fmt.Sprintf("Register.%s", c.after)
+	}
+
+	var refID string
+	switch i, found := vars.Value(key); {
+	case !found:
+		return nil, errors.Errorf("after, %q not found in register", c.after)
+	default:
+		m, ok := i.(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("after, %q is not record, %T", c.after, i)
+		}
+
+		if refID, ok = m["_id"].(string); !ok {
+			return nil, errors.Errorf(
+				"after, %q does not have ULID _id; after can be used only after the contest log entries", c.after)
+		}
+	}
+
+	nq, deadline, err := config.AfterConditionQuery(q, refID, c.within)
+	if err != nil {
+		return nil, err
+	}
+	c.deadline = deadline
+
+	return nq, nil
+}
+
+func (c *queryCondition) check(
+	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) (interface{}, bool, error) {
+	st, err := c.connect(vars, getStorage)
+	if err != nil {
+		return nil, false, err
+	}
+
+	query, err := c.query(vars)
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch i, found, err := st.Find(ctx, c.col, query); {
+	case err != nil:
+		c.Log().Error().Err(err).Msg("failed to find condition")
+
+		return nil, false, err
+	case !found && !c.deadline.IsZero() && time.Now().After(c.deadline.Add(withinGrace)):
+		return nil, false, errors.Errorf(
+			"condition, %q not matched within %s after %q", c.queryString, c.within, c.after)
+	default:
+		return i, found, nil
+	}
+}
+
+// countCondition is matched when the number of records reaches the count.
+type countCondition struct {
+	*queryCondition
+	count int64
+}
+
+func (c *countCondition) check(
+	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) (interface{}, bool, error) {
+	st, err := c.connect(vars, getStorage)
+	if err != nil {
+		return nil, false, err
+	}
+
+	query, err := c.query(vars)
+	if err != nil {
+		return nil, false, err
+	}
+
+	n, err := st.Count(ctx, c.col, query)
+	switch {
+	case err != nil:
+		c.Log().Error().Err(err).Msg("failed to count condition")
+
+		return nil, false, err
+	case n < c.count:
+		return nil, false, nil
+	default:
+		return map[string]interface{}{"_id": config.ULID().String(), "count": n}, true, nil
+	}
+}
+
+// quietCondition is matched when no records are found for the quiet duration.
+type quietCondition struct {
+	*queryCondition
+	quiet   time.Duration
+	started time.Time
+}
+
+func (c *quietCondition) reset(anchor time.Time) {
+	c.queryCondition.reset(anchor)
+
+	c.started = time.Now()
+}
+
+func (c *quietCondition) check(
+	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) (interface{}, bool, error) {
+	st, err := c.connect(vars, getStorage)
+	if err != nil {
+		return nil, false, err
+	}
+
+	query, err := c.query(vars)
+	if err != nil {
+		return nil, false, err
+	}
+
+	now := time.Now()
+	if now.Sub(c.started) < c.quiet {
+		return nil, false, nil
+	}
+
+	q := bson.M{"$and": bson.A{query, bson.M{"_id": bson.M{"$gt": config.MaxULIDAt(now.Add(c.quiet * -1)).String()}}}}
+
+	switch _, found, err := st.Find(ctx, c.col, q); {
+	case err != nil:
+		c.Log().Error().Err(err).Msg("failed to find condition")
+
+		return nil, false, err
+	case found:
+		return nil, false, nil
+	default:
+		return timeRecord("quiet", c.quiet), true, nil
+	}
+}
+
+// aggregateCondition runs the aggregation pipeline and the first result is
+// matched record.
+type aggregateCondition struct {
+	*logging.Logging
+	conditionStorage
+	pipeline string
+}
+
+func (c *aggregateCondition) String() string {
+	return fmt.Sprintf("aggregate: %s", c.pipeline)
+}
+
+func (*aggregateCondition) reset(time.Time) {}
+
+func (c *aggregateCondition) check(
+	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) (interface{}, bool, error) {
+	st, err := c.connect(vars, getStorage)
+	if err != nil {
+		return nil, false, err
+	}
+
+	s := c.pipeline
+	if config.IsTemplateCondition(s) {
+		b, err := config.CompileTemplate(s, vars)
+		if err != nil {
+			return nil, false, errors.Wrapf(err, "failed to compile condition pipeline, %q", s)
+		}
+		s = string(b)
+	}
+
+	pipeline, err := config.ParseConditionPipeline(s)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "invalid compiled condition pipeline, %q", c.pipeline)
+	}
+
+	switch i, found, err := st.Aggregate(ctx, c.col, pipeline); {
+	case err != nil:
+		c.Log().Error().Err(err).Msg("failed to aggregate condition")
+
+		return nil, false, err
+	default:
+		return i, found, nil
+	}
+}
+
+func nodeStorageURI(hosts *Hosts, alias string) (string, error) {
+	var node *Node
+	if err := hosts.TraverseNodes(func(no *Node) (bool, error) {
+		if no.Alias() == alias {
+			node = no
+
+			return false, nil
+		}
+
+		return true, nil
+	}); err != nil {
+		return "", err
+	}
+
+	if node == nil {
+		return "", errors.Errorf("node, %q not found", alias)
+	}
+
+	return node.StorageURI()
+}
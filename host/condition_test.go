@@ -0,0 +1,157 @@
+package host
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+)
+
+type testCondition struct {
+	suite.Suite
+}
+
+func (t *testCondition) newStorageCondition(design config.DesignCondition, hosts *Hosts) (conditionChecker, error) {
+	ctx := context.WithValue(context.Background(), config.ContextValueDesign, config.Design{})
+
+	return newStorageCondition(ctx, design, hosts, logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+		return c.Str("module", "condition")
+	}))
+}
+
+func (t *testCondition) TestNodeStorageLazy() {
+	ho := &LocalHost{
+		design:   config.DesignHost{Host: "local"},
+		nodes:    map[string]*Node{},
+		mongodbs: map[string]*mongodbContainer{"": {port: "27018"}},
+	}
+
+	hosts := NewHosts(nil, nil)
+	t.NoError(hosts.AddHost(ho))
+
+	// NOTE node is not added yet
+	c, err := t.newStorageCondition(config.DesignCondition{Query: `{"height": 3}`, Node: "no9", Col: "block"}, hosts)
+	t.NoError(err)
+
+	var uri string
+	getStorage := func(s string) (*Mongodb, error) {
+		uri = s
+
+		return &Mongodb{}, nil
+	}
+
+	_, err = c.(*queryCondition).connect(nil, getStorage)
+	t.Error(err)
+	t.Contains(err.Error(), `node, "no9" not found`)
+
+	no, err := NewNode("no9", ho)
+	t.NoError(err)
+	ho.nodes["no9"] = no
+
+	_, err = c.(*queryCondition).connect(nil, getStorage)
+	t.NoError(err)
+	t.Equal("mongodb://127.0.0.1:27018/contest_no9", uri)
+}
+
+func (t *testCondition) TestNotLogEntries() {
+	cases := []struct {
+		name   string
+		design config.DesignCondition
+		err    string
+	}{
+		{name: "log entries", design: config.DesignCondition{Query: `{"a": 1}`, After: config.AfterPrevious}},
+		{
+			name:   "after other collection",
+			design: config.DesignCondition{Query: `{"a": 1}`, Storage: "mongodb://a/b", Col: "block", After: "showme"},
+			err:    "after can be used only for the contest log entries",
+		},
+		{
+			name:   "quiet other collection",
+			design: config.DesignCondition{Query: `{"a": 1}`, Storage: "mongodb://a/b", Col: "block", Quiet: 3},
+			err:    "quiet condition can be used only for the contest log entries",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			_, err := t.newStorageCondition(c.design, nil)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+		})
+	}
+}
+
+func (t *testCondition) TestAfterNotULIDRecord() {
+	c, err := t.newStorageCondition(config.DesignCondition{Query: `{"a": 1}`, After: "showme"}, nil)
+	t.NoError(err)
+
+	vars := config.NewVars(map[string]interface{}{
+		"Register": map[string]interface{}{
+			"showme": map[string]interface{}{"_id": int64(3), "height": int64(3)},
+		},
+	})
+
+	_, err = c.(*queryCondition).query(vars)
+	t.Error(err)
+	t.Contains(err.Error(), "does not have ULID _id")
+}
+
+func (t *testCondition) TestConsistencyNodeStorage() {
+	ho := &LocalHost{
+		design:   config.DesignHost{Host: "local"},
+		nodes:    map[string]*Node{},
+		mongodbs: map[string]*mongodbContainer{"": {port: "27018"}},
+	}
+
+	hosts := NewHosts(nil, nil)
+	t.NoError(hosts.AddHost(ho))
+
+	for _, alias := range []string{"no1", "no0"} {
+		no, err := NewNode(alias, ho)
+		t.NoError(err)
+		ho.nodes[alias] = no
+	}
+
+	nodes, err := ConsistencyNodes(hosts, config.DesignConsistency{})
+	t.NoError(err)
+	t.Equal([]string{"no0", "no1"}, nodes)
+
+	// NOTE node added at runtime
+	no, err := NewNode("no2", ho)
+	t.NoError(err)
+	ho.nodes["no2"] = no
+
+	nodes, err = ConsistencyNodes(hosts, config.DesignConsistency{})
+	t.NoError(err)
+	t.Equal([]string{"no0", "no1", "no2"}, nodes)
+
+	nodes, err = ConsistencyNodes(hosts, config.DesignConsistency{Nodes: []string{"no1"}})
+	t.NoError(err)
+	t.Equal([]string{"no1"}, nodes)
+
+	var uri string
+	getStorage := func(s string) (*Mongodb, error) {
+		uri = s
+
+		return &Mongodb{}, nil
+	}
+
+	_, err = consistencyStorage(config.DesignConsistency{}, hosts, "no2", nil, getStorage)
+	t.NoError(err)
+	t.Equal("mongodb://127.0.0.1:27018/contest_no2", uri)
+}
+
+func TestCondition(t *testing.T) {
+	suite.Run(t, new(testCondition))
+}
@@ -0,0 +1,286 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/contest/config"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// consistencyInterval is the interval of checking for consistency condition.
+var consistencyInterval = time.Second * 2
+
+// ConsistencyError is the first divergence of node storages.
+type ConsistencyError struct {
+	Height int64
+	Field  string
+	Values map[string]interface{} // NOTE nil value means record is missing
+}
+
+func (e ConsistencyError) Error() string {
+	aliases := make([]string, 0, len(e.Values))
+	for alias := range e.Values {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	vs := make([]string, len(aliases))
+	for i, alias := range aliases {
+		b, _ := json.Marshal(e.Values[alias])
+		vs[i] = fmt.Sprintf("%s=%s", alias, string(b))
+	}
+
+	return fmt.Sprintf("nodes diverged at height %d; %s: %s", e.Height, e.Field, strings.Join(vs, ", "))
+}
+
+type ConsistencyResult struct {
+	Heights map[string]int64 `json:"heights"` // NOTE highest height of each node
+	Checked int64            `json:"checked"` // NOTE highest height compared
+}
+
+func (r ConsistencyResult) Map() map[string]interface{} {
+	heights := map[string]interface{}{}
+	for k := range r.Heights {
+		heights[k] = r.Heights[k]
+	}
+
+	return map[string]interface{}{
+		"heights": heights,
+		"checked": r.Checked,
+	}
+}
+
+// MinHeight returns the lowest height of nodes.
+func (r ConsistencyResult) MinHeight() int64 {
+	if len(r.Heights) < 1 {
+		return -1
+	}
+
+	min := int64(-1)
+	for k := range r.Heights {
+		if h := r.Heights[k]; min < 0 || h < min {
+			min = h
+		}
+	}
+
+	return min
+}
+
+// ConsistencyNodes returns the nodes to be compared; if not set, all the nodes
+// of hosts, including the nodes added by add-nodes.
+func ConsistencyNodes(hosts *Hosts, de config.DesignConsistency) ([]string, error) {
+	if len(de.Nodes) > 0 {
+		return de.Nodes, nil
+	}
+
+	var aliases []string
+	if err := hosts.TraverseNodes(func(no *Node) (bool, error) {
+		aliases = append(aliases, no.Alias())
+
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(aliases)
+
+	return aliases, nil
+}
+
+// CheckConsistency compares the records of node storages height by height
+// until the lowest height of nodes.
+func CheckConsistency(
+	ctx context.Context,
+	de config.DesignConsistency,
+	aliases []string,
+	hosts *Hosts,
+	vars *config.Vars,
+	getStorage func(string) (*Mongodb, error),
+) (ConsistencyResult, error) {
+	return NewConsistencyChecker(de, aliases, hosts).Check(ctx, vars, getStorage)
+}
+
+// ConsistencyChecker checks the consistency incrementally; it keeps the last
+// verified height, so the next Check loads only the records over it.
+type ConsistencyChecker struct {
+	design  config.DesignConsistency
+	aliases []string
+	hosts   *Hosts
+	checked int64
+	heights map[string]int64
+}
+
+func NewConsistencyChecker(de config.DesignConsistency, aliases []string, hosts *Hosts) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		design:  de,
+		aliases: aliases,
+		hosts:   hosts,
+		checked: -1,
+		heights: map[string]int64{},
+	}
+}
+
+func (cc *ConsistencyChecker) Check(
+	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) (ConsistencyResult, error) {
+	result := ConsistencyResult{Heights: map[string]int64{}, Checked: cc.checked}
+
+	if len(cc.aliases) < 2 {
+		return result, errors.Errorf("consistency needs at least 2 nodes")
+	}
+
+	records := map[string]map[int64]map[string]interface{}{}
+	for _, alias := range cc.aliases {
+		st, err := consistencyStorage(cc.design, cc.hosts, alias, vars, getStorage)
+		if err != nil {
+			return result, err
+		}
+
+		r, max, err := consistencyRecords(ctx, st, cc.design.Col, cc.checked)
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to load records of node, %q", alias)
+		}
+
+		records[alias] = r
+
+		if i, found := cc.heights[alias]; !found || max > i {
+			cc.heights[alias] = max
+		}
+
+		result.Heights[alias] = cc.heights[alias]
+	}
+
+	upto := result.MinHeight()
+
+	var heights []int64 // nolint:prealloc
+	founds := map[int64]struct{}{}
+	for alias := range records {
+		for h := range records[alias] {
+			if _, found := founds[h]; found || h > upto {
+				continue
+			}
+
+			founds[h] = struct{}{}
+			heights = append(heights, h)
+		}
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+
+	for _, h := range heights {
+		if err := compareConsistencyRecords(h, cc.design.Fields, cc.aliases, records); err != nil {
+			return result, err
+		}
+
+		cc.checked = h
+		result.Checked = h
+	}
+
+	return result, nil
+}
+
+func compareConsistencyRecords(
+	height int64, fields, aliases []string, records map[string]map[int64]map[string]interface{},
+) error {
+	for _, field := range fields {
+		values := map[string]interface{}{}
+
+		var diverged, anyFound bool
+		for i, alias := range aliases {
+			r, found := records[alias][height]
+			if !found {
+				values[alias] = nil
+				diverged = true
+
+				continue
+			}
+
+			v, fieldFound, err := config.LookupJSONPath(r, field)
+			if err != nil {
+				return err
+			}
+			values[alias] = v
+
+			if fieldFound {
+				anyFound = true
+			}
+
+			if i > 0 && !config.EqualJSONValue(v, values[aliases[0]]) {
+				diverged = true
+			}
+		}
+
+		if !anyFound {
+			return errors.Errorf("field, %q not found in records of height %d", field, height)
+		}
+
+		if diverged {
+			return ConsistencyError{Height: height, Field: field, Values: values}
+		}
+	}
+
+	return nil
+}
+
+func consistencyStorage(
+	de config.DesignConsistency,
+	hosts *Hosts,
+	alias string,
+	vars *config.Vars,
+	getStorage func(string) (*Mongodb, error),
+) (*Mongodb, error) {
+	if len(de.Storage) < 1 {
+		uri, err := nodeStorageURI(hosts, alias)
+		if err != nil {
+			return nil, err
+		}
+
+		return getStorage(uri)
+	}
+
+	nvars := vars.Clone(map[string]interface{}{"Self": map[string]interface{}{"Alias": alias}})
+
+	b, err := config.CompileTemplate(de.Storage, nvars)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compile consistency storage of node, %q", alias)
+	}
+
+	return getStorage(strings.TrimSpace(string(b)))
+}
+
+// consistencyRecords loads the records over the given height.
+func consistencyRecords(
+	ctx context.Context, st *Mongodb, col string, over int64,
+) (map[int64]map[string]interface{}, int64, error) {
+	rs, err := st.FindAll(ctx, col, bson.M{"height": bson.M{"$gt": over}}, bson.D{{Key: "height", Value: 1}})
+	if err != nil {
+		return nil, -1, err
+	}
+
+	records := map[int64]map[string]interface{}{}
+	max := int64(-1)
+	for i := range rs {
+		var h int64
+		switch t := rs[i]["height"].(type) {
+		case json.Number:
+			j, err := t.Int64()
+			if err != nil {
+				return nil, -1, errors.Wrap(err, "invalid height")
+			}
+			h = j
+		default:
+			return nil, -1, errors.Errorf("record does not have height, %T", rs[i]["height"])
+		}
+
+		records[h] = rs[i]
+		if h > max {
+			max = h
+		}
+	}
+
+	return records, max, nil
+}
@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	dockerTypes "github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/filters"
 	dockerClient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/pkg/errors"
 	"github.com/spikeekips/mitum/util"
 )
@@ -23,6 +25,7 @@ var (
 	ContainerLabelNode         = "node"
 	ContainerLabelNodeAlias    = ContainerLabel + "-node"
 	ContainerLabelNodeType     = ContainerLabel + "-type"
+	ContainerLabelTest         = ContainerLabel + "-test"
 	ContainerLabelNodeInitType = "init"
 	ContainerLabelNodeRunType  = "run"
 
@@ -32,29 +35,51 @@ var (
 
 var ContainerLogIgnoreError = util.NewError("failed to read container logs; ignored")
 
-func MongodbContainerName() string {
-	return "contest-mongodb"
+// NOTE the container and network names are scoped by the test name, so
+// multiple contests can run on the same docker host.
+
+func MongodbContainerName(testName string) string {
+	return fmt.Sprintf("contest-%s-mongodb", testName)
+}
+
+func NodeMongodbContainerName(testName, alias string) string {
+	return fmt.Sprintf("contest-%s-mongodb-%s", testName, alias)
+}
+
+func NodeInitContainerName(testName, alias string) string {
+	return fmt.Sprintf("contest-%s-node-init-%s", testName, alias)
 }
 
-func NodeInitContainerName(alias string) string {
-	return fmt.Sprintf("contest-node-init-%s", alias)
+func NodeRunContainerName(testName, alias string) string {
+	return fmt.Sprintf("contest-%s-node-run-%s", testName, alias)
 }
 
-func NodeRunContainerName(alias string) string {
-	return fmt.Sprintf("contest-node-run-%s", alias)
+func NodeCustomContainerName(testName, alias string) string {
+	return fmt.Sprintf("contest-%s-node-custom-%s-%s", testName, util.UUID().String(), alias)
 }
 
-func NodeCustomContainerName(alias string) string {
-	return fmt.Sprintf("contest-node-custom-%s-%s", util.UUID().String(), alias)
+func NetworkName(testName string) string {
+	return fmt.Sprintf("contest-%s", testName)
 }
 
+// ContainerLabels returns the labels of contest container.
+func ContainerLabels(testName, role string) map[string]string {
+	return map[string]string{
+		ContainerLabel:     role,
+		ContainerLabelTest: testName,
+	}
+}
+
+// TraverseContainers traverses the contest containers of the test; if
+// testName is empty, the containers of all the tests are traversed.
 func TraverseContainers(
-	ctx context.Context, client *dockerClient.Client, callback func(dockerTypes.Container,
+	ctx context.Context, client *dockerClient.Client, testName string, callback func(dockerTypes.Container,
 	) (bool, error)) error {
 	cs, err := client.ContainerList(
 		ctx,
 		dockerTypes.ContainerListOptions{
-			All: true,
+			All:     true,
+			Filters: testFilters(testName),
 		},
 	)
 	if err != nil {
@@ -62,29 +87,140 @@ func TraverseContainers(
 	}
 
 	for i := range cs {
-		c := cs[i]
+		if keep, err := callback(cs[i]); err != nil {
+			return err
+		} else if !keep {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// CreateNetwork creates the bridge network of the test; if already created,
+// the existing one is returned.
+func CreateNetwork(ctx context.Context, client *dockerClient.Client, testName string) (string, error) {
+	name := NetworkName(testName)
+
+	switch i, err := client.NetworkInspect(ctx, name, dockerTypes.NetworkInspectOptions{}); {
+	case err == nil:
+		return i.ID, nil
+	case !dockerClient.IsErrNotFound(err):
+		return "", errors.Wrapf(err, "failed to inspect network, %q", name)
+	}
+
+	r, err := client.NetworkCreate(ctx, name, dockerTypes.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Labels:         ContainerLabels(testName, "network"),
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create network, %q", name)
+	}
 
-		var found bool
-		for k := range c.Labels {
-			if strings.HasPrefix(k, ContainerLabel) {
-				found = true
+	return r.ID, nil
+}
 
-				break
+// CleanContainers removes the contest containers and networks of the test;
+// if testName is empty, those of all the tests are removed. Without force,
+// the running containers are not removed and returns error.
+func CleanContainers(ctx context.Context, client *dockerClient.Client, testName string, dryrun, force bool) error {
+	var cs []dockerTypes.Container
+	if err := TraverseContainers(ctx, client, testName, func(c dockerTypes.Container) (bool, error) {
+		if !force {
+			if c.State == "running" {
+				return false, errors.Errorf("founds still running node container, %q", c.ID)
 			}
 		}
 
-		if !found {
+		if !dryrun {
+			cs = append(cs, c)
+		}
+
+		return true, nil
+	}); err != nil {
+		return err
+	} else if dryrun {
+		return nil
+	}
+
+	if err := RunWaitGroup(len(cs), func(i int) error {
+		return client.ContainerRemove(ctx, cs[i].ID, dockerTypes.ContainerRemoveOptions{
+			RemoveVolumes: true,
+			Force:         force,
+		})
+	}); err != nil {
+		return err
+	}
+
+	ns, err := client.NetworkList(ctx, dockerTypes.NetworkListOptions{Filters: testFilters(testName)})
+	if err != nil {
+		return errors.Wrap(err, "failed to list networks")
+	}
+
+	return RunWaitGroup(len(ns), func(i int) error {
+		if err := client.NetworkRemove(ctx, ns[i].ID); err != nil && !dockerClient.IsErrNotFound(err) {
+			return errors.Wrapf(err, "failed to remove network, %q", ns[i].Name)
+		}
+
+		return nil
+	})
+}
+
+// CleanStaleContainers removes the containers and networks of the stale
+// tests and returns the names of them. The test is stale when all of it's
+// containers, including mongodb, are exited; the contest of it already
+// finished without cleaning. The test of testName is not touched.
+func CleanStaleContainers(ctx context.Context, client *dockerClient.Client, testName string) ([]string, error) {
+	var cs []dockerTypes.Container
+	if err := TraverseContainers(ctx, client, "", func(c dockerTypes.Container) (bool, error) {
+		cs = append(cs, c)
+
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+
+	tests := staleTests(cs, testName)
+	for i := range tests {
+		if err := CleanContainers(ctx, client, tests[i], false, false); err != nil {
+			return nil, errors.Wrapf(err, "failed to clean stale containers of test, %q", tests[i])
+		}
+	}
+
+	return tests, nil
+}
+
+func staleTests(cs []dockerTypes.Container, testName string) []string {
+	live := map[string]bool{}
+	for i := range cs {
+		t := cs[i].Labels[ContainerLabelTest]
+		if len(t) < 1 || t == testName {
 			continue
 		}
 
-		if keep, err := callback(c); err != nil {
-			return err
-		} else if !keep {
-			return nil
+		// NOTE the just created containers of the starting contest are not
+		// running yet
+		live[t] = live[t] || (cs[i].State != "exited" && cs[i].State != "dead")
+	}
+
+	var tests []string
+	for t := range live {
+		if !live[t] {
+			tests = append(tests, t)
 		}
 	}
+	sort.Strings(tests)
 
-	return nil
+	return tests
+}
+
+func testFilters(testName string) filters.Args {
+	if len(testName) < 1 {
+		return filters.NewArgs(filters.Arg("label", ContainerLabel))
+	}
+
+	return filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", ContainerLabelTest, testName)))
 }
 
 func PullImages(client *dockerClient.Client, images []string, update bool) error {
@@ -196,3 +332,66 @@ func readContainerLogs(ctx context.Context, reader io.Reader, callback func(uint
 func ContainerInspect(ctx context.Context, client *dockerClient.Client, id string) (dockerTypes.ContainerJSON, error) {
 	return client.ContainerInspect(ctx, id)
 }
+
+// ContainerExec runs command inside the running container and returns the
+// output and exit code.
+func ContainerExec(
+	ctx context.Context,
+	client *dockerClient.Client,
+	id string,
+	cmd []string,
+) ([]byte /* stdout */, []byte /* stderr */, int /* exit code */, error) {
+	r, err := client.ContainerExecCreate(ctx, id, dockerTypes.ExecConfig{
+		AttachStdout: true,
+		AttachStderr: true,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, nil, -1, errors.Wrap(err, "failed to create exec")
+	}
+
+	hr, err := client.ContainerExecAttach(ctx, r.ID, dockerTypes.ExecStartCheck{})
+	if err != nil {
+		return nil, nil, -1, errors.Wrap(err, "failed to attach exec")
+	}
+	defer hr.Close()
+
+	stdout, stderr, err := readExecOutput(ctx, hr)
+	if err != nil {
+		return stdout, stderr, -1, err
+	}
+
+	i, err := client.ContainerExecInspect(ctx, r.ID)
+	if err != nil {
+		return stdout, stderr, -1, errors.Wrap(err, "failed to inspect exec")
+	}
+
+	return stdout, stderr, i.ExitCode, nil
+}
+
+// readExecOutput reads the output of exec until the output is closed. If
+// context is done, the connection is closed and the output is returned after
+// the copy stops.
+func readExecOutput(ctx context.Context, hr dockerTypes.HijackedResponse) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+
+	copied := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(&stdout, &stderr, hr.Reader)
+		copied <- err
+	}()
+
+	select {
+	case <-ctx.Done():
+		hr.Close()
+		<-copied
+
+		return stdout.Bytes(), stderr.Bytes(), ctx.Err()
+	case err := <-copied:
+		if err != nil {
+			return stdout.Bytes(), stderr.Bytes(), errors.Wrap(err, "failed to read exec output")
+		}
+
+		return stdout.Bytes(), stderr.Bytes(), nil
+	}
+}
@@ -0,0 +1,129 @@
+package host
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/suite"
+)
+
+type testContainerExec struct {
+	suite.Suite
+}
+
+func (t *testContainerExec) hijacked() (dockerTypes.HijackedResponse, net.Conn) {
+	client, server := net.Pipe()
+
+	return dockerTypes.NewHijackedResponse(client, ""), server
+}
+
+func (t *testContainerExec) TestRead() {
+	hr, server := t.hijacked()
+
+	go func() {
+		_, _ = stdcopy.NewStdWriter(server, stdcopy.Stdout).Write([]byte("showme"))
+		_, _ = stdcopy.NewStdWriter(server, stdcopy.Stderr).Write([]byte("findme"))
+		_ = server.Close()
+	}()
+
+	stdout, stderr, err := readExecOutput(context.Background(), hr)
+	t.NoError(err)
+	t.Equal("showme", string(stdout))
+	t.Equal("findme", string(stderr))
+}
+
+func (t *testContainerExec) TestCancel() {
+	hr, server := t.hijacked()
+
+	written := make(chan struct{})
+	go func() {
+		defer func() {
+			_ = server.Close()
+		}()
+
+		w := stdcopy.NewStdWriter(server, stdcopy.Stdout)
+
+		var once bool
+		for {
+			if _, err := w.Write([]byte("showme")); err != nil {
+				return
+			}
+
+			if !once {
+				once = true
+				close(written)
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	go func() {
+		<-written
+		cancel()
+	}()
+
+	done := make(chan struct{})
+
+	var stdout []byte
+	var err error
+	go func() {
+		defer close(done)
+
+		stdout, _, err = readExecOutput(ctx, hr)
+	}()
+
+	select {
+	case <-time.After(time.Second * 3):
+		t.Fail("not returned after canceled")
+
+		return
+	case <-done:
+	}
+
+	t.ErrorIs(err, context.Canceled)
+	t.Contains(string(stdout), "showme")
+}
+
+func TestContainerExec(t *testing.T) {
+	suite.Run(t, new(testContainerExec))
+}
+
+type testStaleTests struct {
+	suite.Suite
+}
+
+func (t *testStaleTests) TestStaleTests() {
+	container := func(testName, state string) dockerTypes.Container {
+		return dockerTypes.Container{
+			Labels: ContainerLabels(testName, ContainerLabelNode),
+			State:  state,
+		}
+	}
+
+	cs := []dockerTypes.Container{
+		container("a", "exited"),
+		container("a", "exited"),
+		container("b", "exited"),
+		container("b", "running"), // NOTE mongodb of running contest
+		container("c", "exited"),
+		container("c", "created"),
+		container("d", "exited"),
+		container("d", "dead"),
+		container("e", "paused"),
+		container("self", "exited"),
+		{Labels: map[string]string{ContainerLabel: ContainerLabelNode}, State: "exited"},
+	}
+
+	t.Equal([]string{"a", "d"}, staleTests(cs, "self"))
+	t.Equal([]string{"a", "d", "self"}, staleTests(cs, "e"))
+	t.Empty(staleTests(nil, "self"))
+}
+
+func TestStaleTests(t *testing.T) {
+	suite.Run(t, new(testStaleTests))
+}
@@ -7,10 +7,13 @@ import (
 )
 
 var (
-	ContextValueHosts      util.ContextKey = "hosts"
-	ContextValueMongodb    util.ContextKey = "mongodb"
-	ContextValueLogSaver   util.ContextKey = "log_saver"
-	ContextValueLogWatcher util.ContextKey = "log_watcher"
+	ContextValueHosts        util.ContextKey = "hosts"
+	ContextValueMongodb      util.ContextKey = "mongodb"
+	ContextValueLogSaver     util.ContextKey = "log_saver"
+	ContextValueLogWatcher   util.ContextKey = "log_watcher"
+	ContextValueLoads        util.ContextKey = "loads"
+	ContextValueDockerEvents util.ContextKey = "docker_events"
+	ContextValueStatsSampler util.ContextKey = "stats_sampler"
 )
 
 func LoadHostsContextValue(ctx context.Context, l **Hosts) error {
@@ -28,3 +31,15 @@ func LoadLogSaverContextValue(ctx context.Context, l **LogSaver) error {
 func LoadLogWatcherContextValue(ctx context.Context, l **LogWatcher) error {
 	return util.LoadFromContextValue(ctx, ContextValueLogWatcher, l)
 }
+
+func LoadLoadsContextValue(ctx context.Context, l **Loads) error {
+	return util.LoadFromContextValue(ctx, ContextValueLoads, l)
+}
+
+func LoadDockerEventsContextValue(ctx context.Context, l **DockerEvents) error {
+	return util.LoadFromContextValue(ctx, ContextValueDockerEvents, l)
+}
+
+func LoadStatsSamplerContextValue(ctx context.Context, l **StatsSampler) error {
+	return util.LoadFromContextValue(ctx, ContextValueStatsSampler, l)
+}
@@ -0,0 +1,282 @@
+package host
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/spikeekips/contest/config"
+)
+
+var (
+	DiagnosticsFile             = "diagnostics.tar.gz"
+	DiagnosticsLogEntries int64 = 100
+	// NOTE diagnosticsQuitWait is the time for the goroutine dumps of the quit
+	// nodes to be saved by log saver.
+	diagnosticsQuitWait = time.Second * 3
+)
+
+type diagnosticsFile struct {
+	name string
+	body []byte
+}
+
+// Diagnostics collects the state of the failed contest into one tar.gz file;
+// the docker inspect of the contest containers, the rendered node configs,
+// the vars, the last log entries of nodes and the listing of node data
+// directories. The hosts, mongodb and vars can be nil, if contest failed
+// before they are prepared.
+type Diagnostics struct {
+	*logging.Logging
+	hosts *Hosts
+	mg    *Mongodb
+	vars  *config.Vars
+	files []diagnosticsFile
+	errs  []string
+}
+
+func NewDiagnostics(hosts *Hosts, mg *Mongodb, vars *config.Vars) *Diagnostics {
+	return &Diagnostics{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "diagnostics")
+		}),
+		hosts: hosts,
+		mg:    mg,
+		vars:  vars,
+	}
+}
+
+// Quit sends SIGQUIT to the running node containers, so the go nodes dump
+// the goroutine stacks to stderr. Quit waits until the dumps are saved.
+func (di *Diagnostics) Quit(ctx context.Context) int {
+	if di.hosts == nil {
+		return 0
+	}
+
+	var quit int
+	_ = di.hosts.TraverseHosts(func(h Host) (bool, error) {
+		if err := TraverseContainers(ctx, h.DockerClient(), h.TestName(), func(c dockerTypes.Container) (bool, error) {
+			if c.Labels[ContainerLabelNodeType] != ContainerLabelNodeRunType || c.State != "running" {
+				return true, nil
+			}
+
+			if err := h.DockerClient().ContainerKill(ctx, c.ID, "SIGQUIT"); err != nil {
+				di.addError("quit", errors.Wrapf(err, "failed to quit node, %q", c.Labels[ContainerLabelNodeAlias]))
+
+				return true, nil
+			}
+
+			quit++
+
+			return true, nil
+		}); err != nil {
+			di.addError("quit", err)
+		}
+
+		return true, nil
+	})
+
+	if quit > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(diagnosticsQuitWait):
+		}
+	}
+
+	return quit
+}
+
+// Collect collects the diagnostics. The failure of each part does not stop
+// collecting; the failures are saved in "errors.txt".
+func (di *Diagnostics) Collect(ctx context.Context, exitError error) {
+	if exitError != nil {
+		di.add("exit_error.txt", []byte(fmt.Sprintf("%+v\n", exitError)))
+	}
+
+	di.collectVars()
+
+	if di.hosts == nil {
+		return
+	}
+
+	di.collectInspects(ctx)
+
+	_ = di.hosts.TraverseNodes(func(no *Node) (bool, error) {
+		di.collectNode(ctx, no)
+
+		return true, nil
+	})
+}
+
+// Save writes the collected files into tar.gz file.
+func (di *Diagnostics) Save(f string) error {
+	if len(di.errs) > 0 {
+		di.add("errors.txt", []byte(strings.Join(di.errs, "\n")+"\n"))
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	now := time.Now()
+	for i := range di.files {
+		d := di.files[i]
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    filepath.Join("diagnostics", d.name),
+			Mode:    0o600,
+			Size:    int64(len(d.body)),
+			ModTime: now,
+		}); err != nil {
+			return errors.Wrap(err, "failed to write diagnostics")
+		}
+
+		if _, err := tw.Write(d.body); err != nil {
+			return errors.Wrap(err, "failed to write diagnostics")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, "failed to write diagnostics")
+	}
+
+	if err := gw.Close(); err != nil {
+		return errors.Wrap(err, "failed to write diagnostics")
+	}
+
+	return os.WriteFile(filepath.Clean(f), buf.Bytes(), 0o600)
+}
+
+func (di *Diagnostics) collectVars() {
+	if di.vars == nil {
+		return
+	}
+
+	b, err := json.MarshalIndent(di.vars.Map(), "", "  ")
+	if err != nil {
+		di.addError("vars", err)
+
+		return
+	}
+
+	di.add("vars.json", b)
+}
+
+func (di *Diagnostics) collectInspects(ctx context.Context) {
+	_ = di.hosts.TraverseHosts(func(h Host) (bool, error) {
+		if err := TraverseContainers(ctx, h.DockerClient(), h.TestName(), func(c dockerTypes.Container) (bool, error) {
+			name := c.ID
+			if len(c.Names) > 0 {
+				name = strings.TrimPrefix(c.Names[0], "/")
+			}
+
+			i, err := ContainerInspect(ctx, h.DockerClient(), c.ID)
+			if err != nil {
+				di.addError("inspect", errors.Wrapf(err, "failed to inspect container, %q", name))
+
+				return true, nil
+			}
+
+			b, err := json.MarshalIndent(i, "", "  ")
+			if err != nil {
+				di.addError("inspect", err)
+
+				return true, nil
+			}
+
+			di.add(filepath.Join("inspect", name+".json"), b)
+
+			return true, nil
+		}); err != nil {
+			di.addError("inspect", errors.Wrapf(err, "failed to traverse containers of host, %q", h.Host()))
+		}
+
+		return true, nil
+	})
+}
+
+func (di *Diagnostics) collectNode(ctx context.Context, no *Node) {
+	alias := no.Alias()
+
+	if b := no.ConfigData(); len(b) > 0 {
+		di.add(filepath.Join("config", alias+".yml"), b)
+	}
+
+	if b, err := listDir(no.DataDir()); err != nil {
+		di.addError("data", errors.Wrapf(err, "failed to list data directory of node, %q", alias))
+	} else {
+		di.add(filepath.Join("data", alias+".txt"), b)
+	}
+
+	if di.mg == nil {
+		return
+	}
+
+	records, err := di.mg.FindLatest(ctx, colLogEntry, bson.M{"node": alias}, DiagnosticsLogEntries)
+	if err != nil {
+		di.addError("logs", errors.Wrapf(err, "failed to find log entries of node, %q", alias))
+
+		return
+	}
+
+	var buf bytes.Buffer
+	for i := range records {
+		b, err := json.Marshal(records[i])
+		if err != nil {
+			di.addError("logs", err)
+
+			continue
+		}
+
+		_, _ = buf.Write(b)
+		_ = buf.WriteByte('\n')
+	}
+
+	di.add(filepath.Join("logs", alias+".json"), buf.Bytes())
+}
+
+func (di *Diagnostics) add(name string, body []byte) {
+	di.files = append(di.files, diagnosticsFile{name: name, body: body})
+}
+
+func (di *Diagnostics) addError(part string, err error) {
+	di.Log().Error().Err(err).Str("part", part).Msg("failed to collect diagnostics")
+
+	di.errs = append(di.errs, fmt.Sprintf("%s: %v", part, err))
+}
+
+// listDir lists the files under the directory in lexical order, like "ls
+// -lR".
+func listDir(root string) ([]byte, error) {
+	var lines []string
+	if err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s: %v", p, err))
+
+			return nil
+		}
+
+		rel, _ := filepath.Rel(root, p)
+		lines = append(lines, fmt.Sprintf("%s %12d %s %s",
+			fi.Mode(), fi.Size(), fi.ModTime().UTC().Format(time.RFC3339), rel))
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join(lines, "\n") + "\n"), nil
+}
@@ -0,0 +1,304 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/mitum/util"
+
+	"github.com/spikeekips/contest/config"
+)
+
+var diskFillFile = ".contest-fill"
+
+// Disk is the data directory of node. tmpfs and loop disk are mounted on the
+// directory; the loop device is mapped by device-mapper, so the "error"
+// target can replace it to inject io errors. Mounting requires root.
+type Disk struct {
+	sync.Mutex
+	host    Host
+	design  config.DesignDisk
+	dir     string
+	image   string // NOTE image file of loop device
+	loop    string
+	dm      string // NOTE device-mapper name
+	sectors string
+	mounted bool
+}
+
+func NewDisk(h Host, design config.DesignDisk, dir string) *Disk {
+	return &Disk{host: h, design: design, dir: dir}
+}
+
+func (d *Disk) Dir() string {
+	return d.dir
+}
+
+func (d *Disk) Design() config.DesignDisk {
+	return d.design
+}
+
+func (d *Disk) Mount(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.mounted {
+		return nil
+	}
+
+	if err := os.MkdirAll(d.dir, 0o700); err != nil {
+		return errors.Wrapf(err, "failed to create data directory, %q", d.dir)
+	}
+
+	switch d.design.Type {
+	case config.DiskTypeBind:
+		return nil
+	case config.DiskTypeTmpfs:
+		if _, err := d.run(ctx, "mount", "-t", "tmpfs", "-o", fmt.Sprintf("size=%d", d.design.Size), "tmpfs", d.dir); err != nil {
+			return err
+		}
+	case config.DiskTypeLoop:
+		if err := d.mountLoop(ctx); err != nil {
+			return err
+		}
+	default:
+		return errors.Errorf("unknown disk type, %q", d.design.Type)
+	}
+
+	d.mounted = true
+
+	return nil
+}
+
+// mountLoop creates the image, the loop device and the device-mapper and
+// mounts it. If any step fails, the completed steps are rolled back.
+func (d *Disk) mountLoop(ctx context.Context) (err error) {
+	d.image = d.dir + ".disk"
+	d.dm = fmt.Sprintf("contest-%s-%s", filepath.Base(d.dir), util.UUID().String()[:8])
+
+	var rollbacks []func(context.Context) error
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// NOTE ctx may be already done
+		rctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		for i := len(rollbacks) - 1; i >= 0; i-- {
+			if rerr := rollbacks[i](rctx); rerr != nil {
+				err = errors.Wrapf(err, "failed to roll back mount: %v", rerr)
+			}
+		}
+	}()
+
+	rollbacks = append(rollbacks, func(context.Context) error {
+		if err := os.Remove(d.image); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to remove disk image")
+		}
+
+		return nil
+	})
+
+	if _, err := d.run(ctx, "truncate", "-s", strconv.FormatUint(d.design.Size, 10), d.image); err != nil {
+		return err
+	} else if _, err := d.run(ctx, "mkfs.ext4", "-q", "-F", d.image); err != nil {
+		return err
+	}
+
+	loop, err := d.run(ctx, "losetup", "--find", "--show", d.image)
+	if err != nil {
+		return err
+	}
+	d.loop = loop
+
+	rollbacks = append(rollbacks, func(rctx context.Context) error {
+		_, err := d.run(rctx, "losetup", "-d", d.loop)
+
+		return err
+	})
+
+	sectors, err := d.run(ctx, "blockdev", "--getsz", d.loop)
+	if err != nil {
+		return err
+	}
+	d.sectors = sectors
+
+	if _, err := d.run(ctx, "dmsetup", "create", d.dm, "--table", d.linearTable()); err != nil {
+		return err
+	}
+
+	rollbacks = append(rollbacks, func(rctx context.Context) error {
+		_, err := d.run(rctx, "dmsetup", "remove", d.dm)
+
+		return err
+	})
+
+	_, err = d.run(ctx, "mount", filepath.Join("/dev/mapper", d.dm), d.dir)
+
+	return err
+}
+
+// Unmount unmounts the disk and releases the devices; the node containers
+// should be stopped.
+func (d *Disk) Unmount(ctx context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.mounted {
+		return nil
+	}
+
+	if _, err := d.run(ctx, "umount", d.dir); err != nil {
+		return err
+	}
+
+	if d.design.Type == config.DiskTypeLoop {
+		if _, err := d.run(ctx, "dmsetup", "remove", d.dm); err != nil {
+			return err
+		} else if _, err := d.run(ctx, "losetup", "-d", d.loop); err != nil {
+			return err
+		}
+
+		if err := os.Remove(d.image); err != nil {
+			return errors.Wrap(err, "failed to remove disk image")
+		}
+	}
+
+	d.mounted = false
+
+	return nil
+}
+
+// Fill fills the disk by the given size; if size is 0, the available space of
+// disk is filled.
+func (d *Disk) Fill(ctx context.Context, size uint64) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if size < 1 {
+		i, err := d.available(ctx)
+		if err != nil {
+			return err
+		}
+		size = i
+	}
+
+	if size < 1 {
+		return nil
+	}
+
+	_, err := d.run(ctx, "fallocate", "-l", strconv.FormatUint(size, 10), d.fillFile())
+
+	return err
+}
+
+// Unfill removes the file, which is created by Fill.
+func (d *Disk) Unfill(context.Context) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if err := os.Remove(d.fillFile()); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove fill file")
+	}
+
+	return nil
+}
+
+// SetReadOnly remounts the disk by read-only or read-write.
+func (d *Disk) SetReadOnly(ctx context.Context, readonly bool) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.mounted {
+		return errors.Errorf("%s disk can not be remounted", d.design.Type)
+	}
+
+	opt := "remount,rw"
+	if readonly {
+		opt = "remount,ro"
+	}
+
+	_, err := d.run(ctx, "mount", "-o", opt, d.dir)
+
+	return err
+}
+
+// SetIOError replaces the device-mapper table of loop disk with the "error"
+// target; every io to the disk fails until it is recovered.
+func (d *Disk) SetIOError(ctx context.Context, ioerror bool) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.design.Type != config.DiskTypeLoop || !d.mounted {
+		return errors.Errorf("io error is allowed only for loop disk")
+	}
+
+	table := d.linearTable()
+	if ioerror {
+		table = fmt.Sprintf("0 %s error", d.sectors)
+	}
+
+	if _, err := d.run(ctx, "dmsetup", "suspend", "--nolockfs", d.dm); err != nil {
+		return err
+	} else if _, err := d.run(ctx, "dmsetup", "load", d.dm, "--table", table); err != nil {
+		return err
+	}
+
+	_, err := d.run(ctx, "dmsetup", "resume", d.dm)
+
+	return err
+}
+
+func (d *Disk) available(ctx context.Context) (uint64, error) {
+	s, err := d.run(ctx, "df", "--output=avail", "-B1", d.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	lines := strings.Split(s, "\n")
+
+	i, err := strconv.ParseUint(strings.TrimSpace(lines[len(lines)-1]), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse available space, %q", s)
+	}
+
+	return i, nil
+}
+
+func (d *Disk) fillFile() string {
+	return filepath.Join(d.dir, diskFillFile)
+}
+
+func (d *Disk) linearTable() string {
+	return fmt.Sprintf("0 %s linear %s 0", d.sectors, d.loop)
+}
+
+func (d *Disk) run(ctx context.Context, name string, args ...string) (string, error) {
+	stdout, stderr, err := d.host.ShellExec(ctx, name, args)
+	if err != nil {
+		var e []byte
+		if stderr != nil {
+			e, _ = ioutil.ReadAll(stderr)
+		}
+
+		return "", errors.Wrapf(err, "failed to run %q: %s", name, strings.TrimSpace(string(e)))
+	}
+
+	b, err := ioutil.ReadAll(stdout)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read output of %q", name)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
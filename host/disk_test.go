@@ -0,0 +1,132 @@
+package host
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+)
+
+// NOTE Host has Host method, so it is embedded by alias.
+type embedHost = Host
+
+// shellHost records the shell commands instead of running them.
+type shellHost struct {
+	embedHost
+	commands []string
+	fail     string // NOTE the command, which fails
+}
+
+func (h *shellHost) ShellExec(_ context.Context, name string, args []string) (io.ReadCloser, io.ReadCloser, error) {
+	arg := args[0]
+	if strings.HasPrefix(arg, "/") {
+		arg = filepath.Dir(arg) // NOTE device name is random
+	}
+
+	c := name + " " + arg
+	h.commands = append(h.commands, c)
+
+	if c == h.fail {
+		return nil, ioutil.NopCloser(strings.NewReader("showme")), errors.Errorf("exit status 1")
+	}
+
+	var out string
+	switch name {
+	case "losetup":
+		out = "/dev/loop9"
+	case "blockdev":
+		out = "2048"
+	}
+
+	return ioutil.NopCloser(strings.NewReader(out)), nil, nil
+}
+
+type testDisk struct {
+	suite.Suite
+}
+
+func (t *testDisk) TestMountLoop() {
+	cases := []struct {
+		name     string
+		fail     string
+		expected []string
+	}{
+		{
+			name: "mounted",
+			expected: []string{
+				"truncate -s", "mkfs.ext4 -q", "losetup --find", "blockdev --getsz", "dmsetup create", "mount /dev/mapper",
+			},
+		},
+		{
+			name:     "mkfs failed",
+			fail:     "mkfs.ext4 -q",
+			expected: []string{"truncate -s", "mkfs.ext4 -q"},
+		},
+		{
+			name:     "losetup failed",
+			fail:     "losetup --find",
+			expected: []string{"truncate -s", "mkfs.ext4 -q", "losetup --find"},
+		},
+		{
+			name: "dmsetup failed",
+			fail: "dmsetup create",
+			expected: []string{
+				"truncate -s", "mkfs.ext4 -q", "losetup --find", "blockdev --getsz", "dmsetup create",
+				"losetup -d",
+			},
+		},
+		{
+			name: "mount failed",
+			fail: "mount /dev/mapper",
+			expected: []string{
+				"truncate -s", "mkfs.ext4 -q", "losetup --find", "blockdev --getsz", "dmsetup create", "mount /dev/mapper",
+				"dmsetup remove", "losetup -d",
+			},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			dir := filepath.Join(t.T().TempDir(), "no0")
+
+			h := &shellHost{fail: c.fail}
+			d := NewDisk(h, config.DesignDisk{Type: config.DiskTypeLoop, Size: 1 << 20}, dir)
+
+			// NOTE the image is created by truncate
+			t.NoError(os.MkdirAll(dir, 0o700))
+			t.NoError(ioutil.WriteFile(dir+".disk", nil, 0o600))
+
+			err := d.Mount(context.Background())
+			t.Equal(c.expected, h.commands)
+
+			_, serr := os.Stat(dir + ".disk")
+
+			if len(c.fail) < 1 {
+				t.NoError(err)
+				t.NoError(serr)
+
+				return
+			}
+
+			t.Error(err)
+			t.Contains(err.Error(), "showme")
+			t.True(os.IsNotExist(serr), "disk image not removed")
+
+			h.fail = ""
+			t.NoError(d.Mount(context.Background()), "mount again")
+		})
+	}
+}
+
+func TestDisk(t *testing.T) {
+	suite.Run(t, new(testDisk))
+}
@@ -0,0 +1,236 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	dockerClient "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+)
+
+var (
+	dockerEventsRetryInterval = time.Second
+	dockerEventActions        = map[string]struct{}{
+		"create":  {},
+		"start":   {},
+		"restart": {},
+		"kill":    {},
+		"oom":     {},
+		"die":     {},
+		"stop":    {},
+		"pause":   {},
+		"unpause": {},
+		"destroy": {},
+	}
+)
+
+// DockerEvents saves the docker events of the contest containers as contest
+// log entries, like `{"docker": "die", "node": "no1", "exit_code": 137, "oom":
+// true}`. The events of node run containers also update the node states.
+type DockerEvents struct {
+	*logging.Logging
+	*util.ContextDaemon
+	hosts *Hosts
+	lo    *LogSaver
+	oomL  sync.Mutex
+	ooms  map[ /* container id */ string]struct{}
+}
+
+func NewDockerEvents(hosts *Hosts, lo *LogSaver) *DockerEvents {
+	de := &DockerEvents{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "docker-events")
+		}),
+		hosts: hosts,
+		lo:    lo,
+		ooms:  map[string]struct{}{},
+	}
+
+	de.ContextDaemon = util.NewContextDaemon("docker-events", de.start)
+
+	return de
+}
+
+func (de *DockerEvents) start(ctx context.Context) error {
+	var wg sync.WaitGroup
+
+	_ = de.hosts.TraverseHosts(func(h Host) (bool, error) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			de.watch(ctx, h)
+		}()
+
+		return true, nil
+	})
+
+	wg.Wait()
+
+	return nil
+}
+
+// watch subscribes the events of host until context is done; when the
+// subscription is broken, it subscribes again since the last event.
+func (de *DockerEvents) watch(ctx context.Context, h Host) {
+	l := de.Log().With().Str("host", h.Host()).Logger()
+
+	since := time.Now()
+
+	for {
+		last, err := de.subscribe(ctx, h.DockerClient(), h.TestName(), since)
+		if !last.IsZero() {
+			since = last
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+			l.Error().Err(err).Msg("docker events stopped; will subscribe again")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dockerEventsRetryInterval):
+		}
+	}
+}
+
+func (de *DockerEvents) subscribe(
+	ctx context.Context, client *dockerClient.Client, testName string, since time.Time,
+) (time.Time, error) {
+	msgs, errs := client.Events(ctx, dockerTypes.EventsOptions{
+		Since: fmt.Sprintf("%d.%09d", since.Unix(), since.Nanosecond()),
+		Filters: filters.NewArgs(
+			filters.Arg("type", events.ContainerEventType),
+			filters.Arg("label", fmt.Sprintf("%s=%s", ContainerLabelTest, testName)),
+		),
+	})
+
+	var last time.Time
+	for {
+		select {
+		case err := <-errs:
+			return last, err
+		case msg := <-msgs:
+			last = time.Unix(0, msg.TimeNano)
+
+			de.handle(msg)
+		}
+	}
+}
+
+func (de *DockerEvents) handle(msg events.Message) {
+	action := msg.Action
+	var health string
+	if strings.HasPrefix(action, "health_status:") {
+		action, health = "health_status", strings.TrimSpace(strings.TrimPrefix(action, "health_status:"))
+	} else if _, found := dockerEventActions[action]; !found {
+		return
+	}
+
+	at := time.Unix(0, msg.TimeNano)
+	attrs := msg.Actor.Attributes
+	alias := attrs[ContainerLabelNodeAlias]
+
+	e := map[string]interface{}{
+		"docker":       action,
+		"container":    attrs["name"],
+		"container_id": msg.Actor.ID,
+		"role":         attrs[ContainerLabel],
+		"t":            at.UTC(),
+	}
+
+	if len(alias) > 0 {
+		e["node"] = alias
+	}
+
+	if t := attrs[ContainerLabelNodeType]; len(t) > 0 {
+		e["type"] = t
+	}
+
+	if len(health) > 0 {
+		e["health"] = health
+	}
+
+	var isError bool
+	switch action {
+	case "kill":
+		e["signal"] = attrs["signal"]
+	case "oom":
+		de.oomL.Lock()
+		de.ooms[msg.Actor.ID] = struct{}{}
+		de.oomL.Unlock()
+
+		isError = true
+	case "die":
+		code, _ := strconv.ParseInt(attrs["exitCode"], 10, 64)
+		e["exit_code"] = code
+		e["oom"] = de.popOOM(msg.Actor.ID)
+
+		isError = code != 0
+	}
+
+	de.updateNodeState(msg, action, alias, at)
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		de.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	de.lo.LogEntryChan() <- NewContestLogEntry(b, isError)
+}
+
+func (de *DockerEvents) popOOM(id string) bool {
+	de.oomL.Lock()
+	defer de.oomL.Unlock()
+
+	_, found := de.ooms[id]
+	delete(de.ooms, id)
+
+	return found
+}
+
+func (de *DockerEvents) updateNodeState(msg events.Message, action, alias string, at time.Time) {
+	if len(alias) < 1 || msg.Actor.Attributes[ContainerLabelNodeType] != ContainerLabelNodeRunType {
+		return
+	}
+
+	states := de.hosts.NodeStates()
+
+	switch action {
+	case "start", "unpause":
+		_ = states.Observe(alias, config.NodeStateRunning, 0, at, "docker")
+	case "pause":
+		_ = states.Observe(alias, config.NodeStatePaused, 0, at, "docker")
+	case "die":
+		code, _ := strconv.ParseInt(msg.Actor.Attributes["exitCode"], 10, 64)
+		_ = states.Observe(alias, config.NodeStateExited, code, at, "docker")
+	}
+}
+
+func (de *DockerEvents) Stop() error {
+	if err := de.ContextDaemon.Stop(); err != nil && !errors.Is(err, util.DaemonAlreadyStoppedError) {
+		return err
+	}
+
+	return nil
+}
@@ -26,3 +26,18 @@ func (e NodeStderrError) String() string {
 %s
 ================================================================================`, e.Node, string(e.Err))
 }
+
+// NodeExitError is the exit of node, which is waited by the exit code
+// expect; the exit code can be 0.
+type NodeExitError struct {
+	Node     string
+	ExitCode int64
+}
+
+func NewNodeExitError(node string, exitCode int64) NodeExitError {
+	return NodeExitError{Node: node, ExitCode: exitCode}
+}
+
+func (e NodeExitError) Error() string {
+	return fmt.Sprintf("node, %q exited with exit code, %d", e.Node, e.ExitCode)
+}
@@ -12,13 +12,18 @@ type Host interface {
 	Host() string
 	DockerClient() *dockerClient.Client
 	BaseDir() string
+	TestName() string
 	Connect() error
 	Close(context.Context) error
 	Clean(context.Context, bool /* dry run */, bool /* if true, clean runnings */) error
+	CleanStale(context.Context) ([]string /* test names */, error)
 	Prepare(string /* common node config */, *config.Vars) (map[string]interface{}, error)
 	AvailablePort(string /* id */, string /* network */) (string, error)
 	Nodes() map[ /* node alias */ string]*Node
-	MongodbContainerID() string
-	MongodbURI() string
+	AddNode(string /* node alias */, string /* node config */, *config.Vars) (*Node, error)
+	MongodbContainerID(string /* node alias */) string
+	MongodbContainerIDs() []string
+	MongodbURI(string /* node alias */) string
+	MongodbExternalURI(string /* node alias */) string
 	ShellExec(context.Context, string, []string) (io.ReadCloser /* stdout */, io.ReadCloser /* stderr */, error)
 }
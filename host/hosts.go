@@ -7,25 +7,33 @@ import (
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
 )
 
 type Hosts struct {
 	sync.RWMutex
 	*logging.Logging
-	lo    *LogSaver
-	hosts map[ /* Host.Host() */ string]Host
+	lo     *LogSaver
+	hosts  map[ /* Host.Host() */ string]Host
+	states *NodeStates
 }
 
-func NewHosts(lo *LogSaver) *Hosts {
+func NewHosts(lo *LogSaver, vars *config.Vars) *Hosts {
 	return &Hosts{
 		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
 			return c.Str("module", "hosts")
 		}),
-		lo:    lo,
-		hosts: map[string]Host{},
+		lo:     lo,
+		hosts:  map[string]Host{},
+		states: NewNodeStates(lo, vars),
 	}
 }
 
+func (hs *Hosts) NodeStates() *NodeStates {
+	return hs.states
+}
+
 func (hs *Hosts) LenHosts() int {
 	return len(hs.hosts)
 }
@@ -98,6 +106,8 @@ func (hs *Hosts) AddHost(h Host) error {
 	for node := range h.Nodes() {
 		nodes[i] = node
 		i++
+
+		hs.states.Add(node)
 	}
 
 	hs.Log().Debug().Str("host", h.Host()).Strs("nodes", nodes).Msg("host added with nodes")
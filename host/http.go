@@ -0,0 +1,119 @@
+package host
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/contest/config"
+)
+
+// NOTE the http clients are shared by the insecure setting, so the idle
+// connections are reused by the next requests.
+var httpClients = map[bool]*http.Client{
+	false: newHTTPClient(false),
+	true:  newHTTPClient(true),
+}
+
+func newHTTPClient(insecure bool) *http.Client {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecure} // nolint:gosec
+
+	return &http.Client{Transport: t}
+}
+
+type HTTPResponse struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers"`
+	Body    interface{}         `json:"body"` // NOTE parsed json or string
+}
+
+func (r HTTPResponse) Map() map[string]interface{} {
+	headers := map[string]interface{}{}
+	for k := range r.Headers {
+		headers[k] = strings.Join(r.Headers[k], ", ")
+	}
+
+	return map[string]interface{}{
+		"status":  r.Status,
+		"headers": headers,
+		"body":    r.Body,
+	}
+}
+
+// HTTPRequest requests with the given design; url, header values and body are
+// compiled with vars.
+func HTTPRequest(ctx context.Context, design config.DesignHTTP, vars *config.Vars) (HTTPResponse, error) {
+	var res HTTPResponse
+
+	u, err := compileHTTPTemplate(design.URL, vars)
+	if err != nil {
+		return res, errors.Wrap(err, "failed to compile http url")
+	}
+
+	body, err := compileHTTPTemplate(design.Body, vars)
+	if err != nil {
+		return res, errors.Wrap(err, "failed to compile http body")
+	}
+
+	nctx, cancel := context.WithTimeout(ctx, design.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(nctx, design.Method, u, bytes.NewBufferString(body))
+	if err != nil {
+		return res, errors.Wrap(err, "failed to make http request")
+	}
+
+	for k := range design.Headers {
+		v, err := compileHTTPTemplate(design.Headers[k], vars)
+		if err != nil {
+			return res, errors.Wrapf(err, "failed to compile http header, %q", k)
+		}
+
+		req.Header.Set(k, v)
+	}
+
+	r, err := httpClients[design.Insecure].Do(req)
+	if err != nil {
+		return res, errors.Wrapf(err, "failed to request, %s %q", design.Method, u)
+	}
+
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return res, errors.Wrap(err, "failed to read http response body")
+	}
+
+	res.Status = r.StatusCode
+	res.Headers = r.Header
+
+	var j interface{}
+	if err := json.Unmarshal(b, &j); err == nil {
+		res.Body = j
+	} else {
+		res.Body = string(b)
+	}
+
+	return res, nil
+}
+
+func compileHTTPTemplate(s string, vars *config.Vars) (string, error) {
+	if len(s) < 1 {
+		return s, nil
+	}
+
+	b, err := config.CompileTemplate(s, vars)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
@@ -0,0 +1,143 @@
+package host
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spikeekips/contest/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type testHTTPRequest struct {
+	suite.Suite
+	server *httptest.Server
+	conns  int64
+}
+
+func (t *testHTTPRequest) SetupTest() {
+	t.conns = 0
+
+	t.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Token", r.Header.Get("X-Token"))
+
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not found"))
+		default:
+			_, _ = w.Write([]byte(`{"method": "` + r.Method + `", "body": "` + string(b) +
+				`", "block": {"height": 3, "hash": "showme"}}`))
+		}
+	}))
+	t.server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&t.conns, 1)
+		}
+	}
+	t.server.Start()
+}
+
+func (t *testHTTPRequest) TearDownTest() {
+	t.server.Close()
+}
+
+func (t *testHTTPRequest) design(path string) config.DesignHTTP {
+	return config.DesignHTTP{
+		Method:  http.MethodPost,
+		URL:     t.server.URL + path,
+		Timeout: time.Second * 3,
+	}
+}
+
+func (t *testHTTPRequest) TestResponse() {
+	vars := config.NewVars(map[string]interface{}{"Token": "findme", "Path": "/block"})
+
+	design := t.design("{{ .Path }}")
+	design.Headers = map[string]string{"X-Token": "{{ .Token }}"}
+	design.Body = "{{ .Token }}"
+
+	res, err := HTTPRequest(context.Background(), design, vars)
+	t.NoError(err)
+
+	t.Equal(http.StatusOK, res.Status)
+	t.Equal([]string{"findme"}, res.Headers["X-Token"])
+	t.Equal(map[string]interface{}{
+		"method": "POST",
+		"body":   "findme",
+		"block":  map[string]interface{}{"height": float64(3), "hash": "showme"},
+	}, res.Body)
+
+	t.Equal("findme", res.Map()["headers"].(map[string]interface{})["X-Token"])
+}
+
+func (t *testHTTPRequest) TestCheckResponse() {
+	res, err := HTTPRequest(context.Background(), t.design("/block"), config.NewVars(nil))
+	t.NoError(err)
+
+	cases := []struct {
+		name   string
+		status int
+		json   map[string]interface{}
+		err    string
+	}{
+		{name: "default status"},
+		{name: "status", status: http.StatusOK},
+		{name: "unexpected status", status: http.StatusCreated, err: "unexpected http status"},
+		{name: "json path", json: map[string]interface{}{"block.height": 3, "block.hash": "showme"}},
+		{name: "json path not matched", json: map[string]interface{}{"block.height": 4}, err: "not matched"},
+		{name: "json path not found", json: map[string]interface{}{"block.round": 0}, err: "not found"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			design := t.design("/block")
+			design.Status = c.status
+			design.JSON = c.json
+
+			err := design.CheckResponse(res.Status, res.Body)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+		})
+	}
+}
+
+func (t *testHTTPRequest) TestNotJSONBody() {
+	res, err := HTTPRequest(context.Background(), t.design("/missing"), config.NewVars(nil))
+	t.NoError(err)
+
+	t.Equal(http.StatusNotFound, res.Status)
+	t.Equal("not found", res.Body)
+
+	err = t.design("/missing").CheckResponse(res.Status, res.Body)
+	t.Error(err)
+	t.Contains(err.Error(), "unexpected http status, 404")
+}
+
+func (t *testHTTPRequest) TestReuseConnection() {
+	for i := 0; i < 3; i++ {
+		_, err := HTTPRequest(context.Background(), t.design("/block"), config.NewVars(nil))
+		t.NoError(err)
+	}
+
+	t.Equal(int64(1), atomic.LoadInt64(&t.conns))
+}
+
+func TestHTTPRequest(t *testing.T) {
+	suite.Run(t, new(testHTTPRequest))
+}
@@ -0,0 +1,399 @@
+package host
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/mitum/util/logging"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	colLoad                = "load"
+	loadRecordsFlushSize   = 100
+	loadRecordsFlushPeriod = time.Second
+)
+
+// LoadFunc is the single request of load. The returned map is saved with the
+// record of request.
+type LoadFunc func(context.Context) (map[string]interface{}, error)
+
+type LoadSummary struct {
+	Name       string        `json:"name"`
+	Requests   uint64        `json:"requests"`
+	Errors     uint64        `json:"errors"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Throughput float64       `json:"throughput"` // NOTE requests per second
+	P50        time.Duration `json:"p50"`
+	P90        time.Duration `json:"p90"`
+	P99        time.Duration `json:"p99"`
+	Max        time.Duration `json:"max"`
+}
+
+func (s LoadSummary) Map() map[string]interface{} {
+	return map[string]interface{}{
+		"name":       s.Name,
+		"requests":   s.Requests,
+		"errors":     s.Errors,
+		"elapsed":    s.Elapsed.String(),
+		"throughput": s.Throughput,
+		"p50":        s.P50.String(),
+		"p90":        s.P90.String(),
+		"p99":        s.P99.String(),
+		"max":        s.Max.String(),
+	}
+}
+
+// Load runs LoadFunc repeatedly at the fixed rate or with the fixed
+// concurrency. Every request is recorded in the load collection.
+type Load struct {
+	sync.Mutex
+	*logging.Logging
+	name        string
+	rate        float64
+	concurrency int
+	duration    time.Duration
+	f           LoadFunc
+	mg          *Mongodb
+	recordChan  chan bson.M
+	cancel      func()
+	done        chan struct{}
+	latencies   []time.Duration
+	errors      uint64
+	started     time.Time
+	stopped     time.Time
+}
+
+func NewLoad(
+	name string, rate float64, concurrency int, duration time.Duration, f LoadFunc, mg *Mongodb,
+) (*Load, error) {
+	if rate <= 0 && concurrency < 1 {
+		return nil, errors.Errorf("load, %q needs rate or concurrency", name)
+	}
+
+	return &Load{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "load").Str("load", name)
+		}),
+		name:        name,
+		rate:        rate,
+		concurrency: concurrency,
+		duration:    duration,
+		f:           f,
+		mg:          mg,
+		recordChan:  make(chan bson.M, loadRecordsFlushSize*10),
+		done:        make(chan struct{}),
+	}, nil
+}
+
+func (lo *Load) Name() string {
+	return lo.name
+}
+
+// Start starts load in background. Load stops when ctx is done, duration
+// passed or Stop is called.
+func (lo *Load) Start(ctx context.Context) error {
+	lo.Lock()
+	defer lo.Unlock()
+
+	if !lo.started.IsZero() {
+		return errors.Errorf("load, %q already started", lo.name)
+	}
+
+	var nctx context.Context
+	var cancel func()
+	if lo.duration > 0 {
+		nctx, cancel = context.WithTimeout(ctx, lo.duration)
+	} else {
+		nctx, cancel = context.WithCancel(ctx)
+	}
+
+	lo.cancel = cancel
+	lo.started = time.Now()
+
+	writerDone := make(chan struct{})
+	go func() {
+		lo.writeRecords()
+
+		close(writerDone)
+	}()
+
+	go func() {
+		if lo.rate > 0 {
+			lo.runRate(nctx)
+		} else {
+			lo.runConcurrency(nctx)
+		}
+
+		close(lo.recordChan)
+		<-writerDone
+
+		lo.Lock()
+		lo.stopped = time.Now()
+		lo.Unlock()
+
+		close(lo.done)
+
+		lo.Log().Debug().Msg("load finished")
+	}()
+
+	lo.Log().Debug().Float64("rate", lo.rate).Int("concurrency", lo.concurrency).Dur("duration", lo.duration).
+		Msg("load started")
+
+	return nil
+}
+
+// Stop stops load and waits until the running requests are finished.
+func (lo *Load) Stop() LoadSummary {
+	lo.Lock()
+	cancel := lo.cancel
+	lo.Unlock()
+
+	if cancel != nil {
+		cancel()
+
+		<-lo.done
+	}
+
+	return lo.Summary()
+}
+
+func (lo *Load) Summary() LoadSummary {
+	lo.Lock()
+	defer lo.Unlock()
+
+	s := LoadSummary{
+		Name:     lo.name,
+		Requests: uint64(len(lo.latencies)),
+		Errors:   lo.errors,
+	}
+
+	if lo.started.IsZero() {
+		return s
+	}
+
+	end := lo.stopped
+	if end.IsZero() {
+		end = time.Now()
+	}
+	s.Elapsed = end.Sub(lo.started)
+
+	if s.Elapsed > 0 {
+		s.Throughput = float64(s.Requests) / s.Elapsed.Seconds()
+	}
+
+	if len(lo.latencies) < 1 {
+		return s
+	}
+
+	l := make([]time.Duration, len(lo.latencies))
+	copy(l, lo.latencies)
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+
+	s.P50 = percentile(l, 0.5)
+	s.P90 = percentile(l, 0.9)
+	s.P99 = percentile(l, 0.99)
+	s.Max = l[len(l)-1]
+
+	return s
+}
+
+func (lo *Load) runRate(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / lo.rate))
+	defer ticker.Stop()
+
+	var sem chan struct{}
+	if lo.concurrency > 0 {
+		sem = make(chan struct{}, lo.concurrency)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if sem != nil {
+				select {
+				case sem <- struct{}{}:
+				default:
+					// NOTE concurrency limit reached; skip this tick
+					continue
+				}
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				lo.request(ctx)
+
+				if sem != nil {
+					<-sem
+				}
+			}()
+		}
+	}
+}
+
+func (lo *Load) runConcurrency(ctx context.Context) {
+	var wg sync.WaitGroup
+	wg.Add(lo.concurrency)
+
+	for i := 0; i < lo.concurrency; i++ {
+		go func() {
+			defer wg.Done()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+					lo.request(ctx)
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func (lo *Load) request(ctx context.Context) {
+	started := time.Now()
+	result, err := lo.f(ctx)
+	latency := time.Since(started)
+
+	if err != nil && ctx.Err() != nil {
+		// NOTE the request canceled by stop is not counted
+		return
+	}
+
+	lo.Lock()
+	lo.latencies = append(lo.latencies, latency)
+	if err != nil {
+		lo.errors++
+	}
+	lo.Unlock()
+
+	r := bson.M{
+		"_id":     config.ULID().String(),
+		"load":    lo.name,
+		"t":       started,
+		"latency": float64(latency) / float64(time.Millisecond),
+		"ok":      err == nil,
+	}
+
+	if err != nil {
+		r["error"] = err.Error()
+	}
+
+	for k := range result {
+		if _, found := r[k]; !found {
+			r[k] = result[k]
+		}
+	}
+
+	lo.recordChan <- r
+}
+
+func (lo *Load) writeRecords() {
+	ticker := time.NewTicker(loadRecordsFlushPeriod)
+	defer ticker.Stop()
+
+	var records []interface{}
+
+	flush := func() {
+		if len(records) < 1 {
+			return
+		}
+
+		if err := lo.mg.AddRecords(context.Background(), colLoad, records); err != nil {
+			lo.Log().Error().Err(err).Int("records", len(records)).Msg("failed to save load records")
+		}
+
+		records = nil
+	}
+
+	for {
+		select {
+		case r, ok := <-lo.recordChan:
+			if !ok {
+				flush()
+
+				return
+			}
+
+			records = append(records, r)
+			if len(records) >= loadRecordsFlushSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	return sorted[i]
+}
+
+// Loads keeps the loads of contest by name.
+type Loads struct {
+	sync.RWMutex
+	m     map[string]*Load
+	names []string
+}
+
+func NewLoads() *Loads {
+	return &Loads{m: map[string]*Load{}}
+}
+
+func (ls *Loads) Add(lo *Load) error {
+	ls.Lock()
+	defer ls.Unlock()
+
+	if _, found := ls.m[lo.Name()]; found {
+		return errors.Errorf("load, %q already exists", lo.Name())
+	}
+
+	ls.m[lo.Name()] = lo
+	ls.names = append(ls.names, lo.Name())
+
+	return nil
+}
+
+// Stop stops the loads by name; if no names given, all the loads are
+// stopped.
+func (ls *Loads) Stop(names ...string) ([]LoadSummary, error) {
+	ls.RLock()
+	defer ls.RUnlock()
+
+	if len(names) < 1 {
+		names = ls.names
+	}
+
+	summaries := make([]LoadSummary, len(names))
+	for i := range names {
+		lo, found := ls.m[names[i]]
+		if !found {
+			return nil, errors.Errorf("load, %q not found", names[i])
+		}
+
+		summaries[i] = lo.Stop()
+	}
+
+	return summaries, nil
+}
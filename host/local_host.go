@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,16 +27,25 @@ import (
 type LocalHost struct {
 	sync.RWMutex
 	*logging.Logging
-	design             config.DesignHost
-	vars               *config.Vars
-	nodeDesigns        map[string]string
-	runner             string
-	client             *dockerClient.Client
-	baseDir            string
-	ports              []string
-	nodes              map[string]*Node
-	mongodbContainerID string
-	mongodbURI         string
+	design      config.DesignHost
+	vars        *config.Vars
+	nodeDesigns map[string]string
+	common      string // NOTE common node config
+	runner      string
+	client      *dockerClient.Client
+	baseDir     string
+	testName    string
+	ports       *Ports
+	nodes       map[string]*Node
+	mongodbs    map[ /* node alias */ string]*mongodbContainer
+	clockServer *ClockServer
+}
+
+// mongodbContainer is the mongodb container, which nodes use as storage.
+type mongodbContainer struct {
+	id   string
+	uri  string
+	port string // NOTE published port of mongodb container
 }
 
 func NewLocalHost(
@@ -43,8 +53,14 @@ func NewLocalHost(
 	vars *config.Vars,
 	nodeDesigns map[string]string,
 	runner,
-	baseDir string,
+	baseDir,
+	testName string,
 ) *LocalHost {
+	portRange := design.PortRange
+	if portRange == [2]uint16{} {
+		portRange = config.DefaultPortRange
+	}
+
 	return &LocalHost{
 		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
 			return c.
@@ -56,6 +72,9 @@ func NewLocalHost(
 		nodeDesigns: nodeDesigns,
 		runner:      runner,
 		baseDir:     baseDir,
+		testName:    testName,
+		ports:       NewPorts(PortsFile, portRange, testName),
+		mongodbs:    map[string]*mongodbContainer{},
 	}
 }
 
@@ -71,6 +90,10 @@ func (ho *LocalHost) BaseDir() string {
 	return ho.baseDir
 }
 
+func (ho *LocalHost) TestName() string {
+	return ho.testName
+}
+
 func (ho *LocalHost) Connect() error {
 	c, err := dockerClient.NewClientWithOpts(
 		dockerClient.FromEnv,
@@ -80,6 +103,8 @@ func (ho *LocalHost) Connect() error {
 	}
 	ho.client = c
 
+	_ = ho.ports.SetLogging(ho.Logging)
+
 	return ho.setRunner(ho.runner)
 }
 
@@ -88,7 +113,7 @@ func (ho *LocalHost) Close(ctx context.Context) error {
 	defer ho.Unlock()
 
 	var cs []dockerTypes.Container
-	if err := TraverseContainers(ctx, ho.client, func(c dockerTypes.Container) (bool, error) {
+	if err := TraverseContainers(ctx, ho.client, ho.testName, func(c dockerTypes.Container) (bool, error) {
 		if c.State == "running" {
 			cs = append(cs, c)
 		}
@@ -97,7 +122,7 @@ func (ho *LocalHost) Close(ctx context.Context) error {
 	}); err != nil {
 		return err
 	} else if len(cs) < 1 {
-		return nil
+		return ho.releaseNodes(ctx)
 	}
 
 	if err := RunWaitGroup(len(cs), func(i int) error {
@@ -106,37 +131,47 @@ func (ho *LocalHost) Close(ctx context.Context) error {
 		return err
 	}
 
+	if err := ho.releaseNodes(ctx); err != nil {
+		return err
+	}
+
 	return ho.client.Close()
 }
 
-// Clean cleans the stopped containers. If the containers are still running,
-// returns error.
-func (ho *LocalHost) Clean(ctx context.Context, dryrun, force bool) error {
-	var cs []dockerTypes.Container
-	if err := TraverseContainers(ctx, ho.client, func(c dockerTypes.Container) (bool, error) {
-		if !force {
-			if c.State == "running" {
-				return false, errors.Errorf("founds still running node container, %q", c.ID)
-			}
-		}
+// releaseNodes stops the clock server, unmounts the disks of nodes and
+// releases the reserved ports.
+func (ho *LocalHost) releaseNodes(ctx context.Context) error {
+	if err := ho.ports.Release(); err != nil {
+		return errors.Wrap(err, "failed to release ports")
+	}
 
-		if !dryrun {
-			cs = append(cs, c)
+	if ho.clockServer != nil {
+		if err := ho.clockServer.Close(); err != nil {
+			return errors.Wrap(err, "failed to stop clock server")
 		}
+	}
 
-		return true, nil
-	}); err != nil {
-		return err
-	} else if len(cs) < 1 {
-		return nil
+	for alias := range ho.nodes {
+		if d := ho.nodes[alias].Disk(); d != nil {
+			if err := d.Unmount(ctx); err != nil {
+				return errors.Wrapf(err, "failed to unmount disk of node, %q", alias)
+			}
+		}
 	}
 
-	return RunWaitGroup(len(cs), func(i int) error {
-		return ho.client.ContainerRemove(ctx, cs[i].ID, dockerTypes.ContainerRemoveOptions{
-			RemoveVolumes: true,
-			Force:         force,
-		})
-	})
+	return nil
+}
+
+// Clean cleans the stopped containers and the network of the test. If the
+// containers are still running, returns error.
+func (ho *LocalHost) Clean(ctx context.Context, dryrun, force bool) error {
+	return CleanContainers(ctx, ho.client, ho.testName, dryrun, force)
+}
+
+// CleanStale removes the containers of the other tests, which are already
+// finished.
+func (ho *LocalHost) CleanStale(ctx context.Context) ([]string, error) {
+	return CleanStaleContainers(ctx, ho.client, ho.testName)
 }
 
 func (ho *LocalHost) Prepare(common string, vars *config.Vars) (map[string]interface{}, error) {
@@ -146,11 +181,16 @@ func (ho *LocalHost) Prepare(common string, vars *config.Vars) (map[string]inter
 
 	if err := PullImages(ho.client, []string{DefaultMongodbImage, DefaultNodeImage}, false); err != nil {
 		return nil, err
+	} else if err := ho.createNetwork(); err != nil {
+		return nil, err
 	} else if err := ho.launchMongodb(); err != nil {
 		return nil, err
+	} else if err := ho.startClockServer(); err != nil {
+		return nil, err
 	}
 
 	vars.Set("Runtime.Host.BaseDir", ho.baseDir)
+	ho.common = common
 
 	if _, err := os.Stat(filepath.Join(ho.baseDir, "runner")); os.IsNotExist(err) {
 		return nil, errors.Errorf("runner does not exist, setRunner()")
@@ -162,6 +202,7 @@ func (ho *LocalHost) Prepare(common string, vars *config.Vars) (map[string]inter
 
 	shared := map[string]interface{}{}
 	nodes := map[string]*Node{}
+	ho.nodes = nodes // NOTE mounted disks can be unmounted by Close
 
 	var previousVars *config.Vars
 	if vars != nil {
@@ -172,6 +213,8 @@ func (ho *LocalHost) Prepare(common string, vars *config.Vars) (map[string]inter
 		nvars := ho.newNodeVars(previousVars)
 		if c, err := NewNode(i, ho); err != nil {
 			return nil, err
+		} else if err := ho.prepareNode(c); err != nil {
+			return nil, err
 		} else if s, err := c.Prepare(common, ho.nodeDesigns[i], nvars); err != nil {
 			return nil, err
 		} else {
@@ -187,12 +230,11 @@ func (ho *LocalHost) Prepare(common string, vars *config.Vars) (map[string]inter
 
 			previousVars = nvars
 
-			vars.Set(fmt.Sprintf("Runtime.Node.%s.Storage.URI", c.Alias()), ho.MongodbURI())
+			vars.Set(fmt.Sprintf("Runtime.Node.%s.Storage.URI", c.Alias()), ho.MongodbURI(c.Alias()))
+			vars.Set(fmt.Sprintf("Runtime.Node.%s.Storage.ExternalURI", c.Alias()), ho.MongodbExternalURI(c.Alias()))
 		}
 	}
 
-	ho.nodes = nodes
-
 	for k := range previousVars.Map() {
 		vars.Set(fmt.Sprintf("Design.Common.%s", k), previousVars.Map()[k])
 	}
@@ -200,6 +242,100 @@ func (ho *LocalHost) Prepare(common string, vars *config.Vars) (map[string]inter
 	return shared, nil
 }
 
+// prepareNode sets the clock and mounts the disk of node.
+func (ho *LocalHost) prepareNode(node *Node) error {
+	ho.setClock(node)
+
+	return ho.mountDisk(node)
+}
+
+func (ho *LocalHost) mountDisk(node *Node) error {
+	design, found := ho.design.Disks[node.Alias()]
+	if !found {
+		design = config.DesignDisk{Type: config.DiskTypeBind}
+	}
+
+	node.disk = NewDisk(ho, design, node.DataDir())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := node.disk.Mount(ctx); err != nil {
+		return errors.Wrapf(err, "failed to mount disk of node, %q", node.Alias())
+	}
+
+	return nil
+}
+
+func (ho *LocalHost) setClock(node *Node) {
+	design, found := ho.design.Clocks[node.Alias()]
+	if !found {
+		return
+	}
+
+	node.clock = NewClock(design)
+	node.timeServer = ho.clockServer.IP()
+
+	ho.Log().Debug().
+		Str("node", node.Alias()).
+		Dur("offset", design.Offset).
+		Float64("drift", design.Drift).
+		Msg("clock set")
+}
+
+func (ho *LocalHost) createNetwork() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	if _, err := CreateNetwork(ctx, ho.client, ho.testName); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// startClockServer starts the clock server at the gateway of the test
+// network, which the node containers can reach.
+func (ho *LocalHost) startClockServer() error {
+	if len(ho.design.Clocks) < 1 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	defer cancel()
+
+	n, err := ho.client.NetworkInspect(ctx, NetworkName(ho.testName), dockerTypes.NetworkInspectOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to inspect test network")
+	} else if len(n.IPAM.Config) < 1 {
+		return errors.Errorf("empty gateway of test network")
+	}
+
+	cs := NewClockServer(n.IPAM.Config[0].Gateway, ho.lookupClock)
+	_ = cs.SetLogging(ho.Logging)
+
+	if err := cs.Start(); err != nil {
+		return err
+	}
+
+	ho.clockServer = cs
+
+	return nil
+}
+
+// lookupClock finds the clock of node by the ip address of node container.
+// The ip is set to node when the node container starts, so the ntp request
+// does not query docker.
+func (ho *LocalHost) lookupClock(ip net.IP) (string, *Clock) {
+	for alias, node := range ho.Nodes() {
+		if net.ParseIP(node.IP()).Equal(ip) {
+			return alias, node.Clock()
+		}
+	}
+
+	return "", nil
+}
+
 func (ho *LocalHost) newNodeVars(previous *config.Vars) *config.Vars {
 	m := map[string]interface{}{}
 
@@ -218,44 +354,125 @@ func (ho *LocalHost) newNodeVars(previous *config.Vars) *config.Vars {
 	return vars
 }
 
+// AvailablePort reserves the available port of host; the port is kept
+// reserved until host is closed.
 func (ho *LocalHost) AvailablePort(_, network string) (string, error) {
-	ho.Lock()
-	defer ho.Unlock()
+	return ho.ports.Reserve(network)
+}
+
+func (ho *LocalHost) Nodes() map[string]*Node {
+	ho.RLock()
+	defer ho.RUnlock()
+
+	nodes := make(map[string]*Node, len(ho.nodes))
+	for k := range ho.nodes {
+		nodes[k] = ho.nodes[k]
+	}
 
-	p, err := AvailablePort(network, ho.ports)
+	return nodes
+}
+
+// AddNode adds new node at runtime; the node config is compiled with the
+// current vars.
+func (ho *LocalHost) AddNode(alias, design string, vars *config.Vars) (*Node, error) {
+	if _, found := ho.Nodes()[alias]; found {
+		return nil, errors.Errorf("node, %q already exists", alias)
+	}
+
+	if ho.design.StoragePerNode {
+		if err := ho.createMongodb(alias, NodeMongodbContainerName(ho.testName, alias)); err != nil {
+			return nil, err
+		}
+	}
+
+	node, err := NewNode(alias, ho)
 	if err != nil {
-		return p, err
+		return nil, err
+	} else if err := ho.prepareNode(node); err != nil {
+		return nil, err
+	} else if _, err := node.Prepare(ho.common, design, ho.newNodeVars(vars)); err != nil {
+		return nil, err
 	}
 
-	ho.ports = append(ho.ports, p)
+	vars.Set(fmt.Sprintf("Runtime.Node.%s.Storage.URI", alias), ho.MongodbURI(alias))
+	vars.Set(fmt.Sprintf("Runtime.Node.%s.Storage.ExternalURI", alias), ho.MongodbExternalURI(alias))
 
-	return p, nil
+	ho.Lock()
+	ho.nodes[alias] = node
+	ho.nodeDesigns[alias] = design
+	ho.Unlock()
+
+	return node, nil
 }
 
-func (ho *LocalHost) Nodes() map[string]*Node {
-	return ho.nodes
+// MongodbContainerID returns the id of mongodb container of node. If storage
+// is not separated by node, the shared mongodb container is returned.
+func (ho *LocalHost) MongodbContainerID(alias string) string {
+	if c := ho.mongodb(alias); c != nil {
+		return c.id
+	}
+
+	return ""
 }
 
-func (ho *LocalHost) MongodbContainerID() string {
-	return ho.mongodbContainerID
+// MongodbContainerIDs returns the ids of all the mongodb containers for nodes.
+func (ho *LocalHost) MongodbContainerIDs() []string {
+	ho.RLock()
+	defer ho.RUnlock()
+
+	var ids []string // nolint:prealloc
+	for k := range ho.mongodbs {
+		ids = append(ids, ho.mongodbs[k].id)
+	}
+
+	return ids
+}
+
+// MongodbExternalURI returns the uri of mongodb container of node, which is
+// reachable from contest.
+func (ho *LocalHost) MongodbExternalURI(alias string) string {
+	c := ho.mongodb(alias)
+	if c == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("mongodb://127.0.0.1:%s", c.port)
 }
 
-func (ho *LocalHost) MongodbURI() string {
-	if len(ho.mongodbURI) < 1 {
-		ho.Log().Debug().Str("container_id", ho.mongodbContainerID).Msg("getting ip address of mongodb container")
+func (ho *LocalHost) MongodbURI(alias string) string {
+	c := ho.mongodb(alias)
+	if c == nil {
+		return ""
+	}
+
+	if len(c.uri) < 1 {
+		ho.Log().Debug().Str("container_id", c.id).Msg("getting ip address of mongodb container")
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
 		defer cancel()
 
-		if i, err := ContainerInspect(ctx, ho.client, ho.mongodbContainerID); err != nil {
+		if i, err := ContainerInspect(ctx, ho.client, c.id); err != nil {
 			panic(err)
+		} else if n, found := i.NetworkSettings.Networks[NetworkName(ho.testName)]; !found {
+			panic(errors.Errorf("mongodb container, %q not in test network", c.id))
 		} else {
-			ho.mongodbURI = fmt.Sprintf("mongodb://%s:27017", i.NetworkSettings.IPAddress)
+			c.uri = fmt.Sprintf("mongodb://%s:27017", n.IPAddress)
 
-			ho.Log().Debug().Str("uri", ho.mongodbURI).Msg("mongodb uri")
+			ho.Log().Debug().Str("uri", c.uri).Msg("mongodb uri")
 		}
 	}
 
-	return ho.mongodbURI
+	return c.uri
+}
+
+func (ho *LocalHost) mongodb(alias string) *mongodbContainer {
+	ho.RLock()
+	defer ho.RUnlock()
+
+	if c, found := ho.mongodbs[alias]; found {
+		return c
+	}
+
+	return ho.mongodbs[""]
 }
 
 func (*LocalHost) ShellExec(ctx context.Context, name string, args []string) (io.ReadCloser, io.ReadCloser, error) {
@@ -315,49 +532,65 @@ func (ho *LocalHost) setRunner(f string) error {
 }
 
 func (ho *LocalHost) launchMongodb() error {
-	if err := ho.createMongodb(); err != nil {
-		return err
-	} else if err := ho.startMongodb(); err != nil {
-		return err
+	if !ho.design.StoragePerNode {
+		return ho.createMongodb("", MongodbContainerName(ho.testName))
+	}
+
+	for alias := range ho.nodeDesigns {
+		if err := ho.createMongodb(alias, NodeMongodbContainerName(ho.testName, alias)); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (ho *LocalHost) createMongodb() error {
+func (ho *LocalHost) createMongodb(alias, name string) error {
 	source, _ := nat.NewPort("tcp", "27017")
 
+	// NOTE the mongodb container is published to the loopback, so contest can
+	// reach the node storages without the container network.
+	port, err := ho.AvailablePort("mongodb", "tcp")
+	if err != nil {
+		return errors.Wrap(err, "failed to find port for mongodb")
+	}
+
+	labels := ContainerLabels(ho.testName, ContainerLabelMongodb)
+	if len(alias) > 0 {
+		labels[ContainerLabelNodeAlias] = alias
+	}
+
 	r, err := ho.client.ContainerCreate(
 		context.Background(),
 		&container.Config{
-			Tty:   false,
-			Image: DefaultMongodbImage,
-			Labels: map[string]string{
-				ContainerLabel: ContainerLabelMongodb,
-			},
+			Tty:          false,
+			Image:        DefaultMongodbImage,
+			Labels:       labels,
 			ExposedPorts: nat.PortSet{source: struct{}{}},
 		},
+		&container.HostConfig{
+			PortBindings: nat.PortMap{source: []nat.PortBinding{{HostIP: "127.0.0.1", HostPort: port}}},
+			NetworkMode:  container.NetworkMode(NetworkName(ho.testName)),
+		},
 		nil,
 		nil,
-		nil,
-		MongodbContainerName(),
+		name,
 	)
 	if err != nil {
 		return errors.Wrap(err, "failed to create mongodb container")
 	}
-	ho.mongodbContainerID = r.ID
-
-	return nil
-}
 
-func (ho *LocalHost) startMongodb() error {
-	if len(ho.mongodbContainerID) < 1 {
-		return errors.Errorf("create mongodb container first")
-	}
-
-	return ho.client.ContainerStart(
+	if err := ho.client.ContainerStart(
 		context.Background(),
-		ho.mongodbContainerID,
+		r.ID,
 		dockerTypes.ContainerStartOptions{},
-	)
+	); err != nil {
+		return errors.Wrap(err, "failed to start mongodb container")
+	}
+
+	ho.Lock()
+	ho.mongodbs[alias] = &mongodbContainer{id: r.ID, port: port}
+	ho.Unlock()
+
+	return nil
 }
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -25,6 +26,8 @@ type LogSaver struct {
 	entryChan   chan LogEntry
 	ctx         context.Context
 	cancel      func()
+	logDir      string
+	logFilesL   sync.RWMutex
 	logFiles    map[string][2]io.WriteCloser // [stdout, stderr]
 }
 
@@ -47,38 +50,57 @@ func NewLogSaver(
 		entryChan:   make(chan LogEntry, 100),
 		ctx:         ctx,
 		cancel:      cancel,
+		logDir:      logDir,
+		logFiles:    map[string][2]io.WriteCloser{},
 	}
 
-	logFiles := map[string][2]io.WriteCloser{}
 	nodes = append(nodes, ContestLogName)
 	for _, alias := range nodes {
-		var n [2]io.WriteCloser
-		i, err := ls.createLogFile(logDir, fmt.Sprintf("%s.stdout", alias))
-		if err != nil {
+		if err := ls.AddNode(alias); err != nil {
 			return nil, err
 		}
-		n[0] = i
-
-		i, err = ls.createLogFile(logDir, fmt.Sprintf("%s.stderr", alias))
-		if err != nil {
-			return nil, err
-		}
-		n[1] = i
-
-		logFiles[alias] = n
 	}
 
-	ls.logFiles = logFiles
 	ls.ContextDaemon = util.NewContextDaemon("log-saver", ls.start)
 
 	return ls, nil
 }
 
+// AddNode creates the log files for node; the node can be added at runtime.
+func (ls *LogSaver) AddNode(alias string) error {
+	ls.logFilesL.Lock()
+	defer ls.logFilesL.Unlock()
+
+	if _, found := ls.logFiles[alias]; found {
+		return nil
+	}
+
+	var n [2]io.WriteCloser
+	i, err := ls.createLogFile(ls.logDir, fmt.Sprintf("%s.stdout", alias))
+	if err != nil {
+		return err
+	}
+	n[0] = i
+
+	i, err = ls.createLogFile(ls.logDir, fmt.Sprintf("%s.stderr", alias))
+	if err != nil {
+		return err
+	}
+	n[1] = i
+
+	ls.logFiles[alias] = n
+
+	return nil
+}
+
 func (ls *LogSaver) Stop() error {
 	if err := ls.ContextDaemon.Stop(); err != nil {
 		return err
 	}
 
+	ls.logFilesL.Lock()
+	defer ls.logFilesL.Unlock()
+
 	for k := range ls.logFiles {
 		for i := range ls.logFiles[k] {
 			if err := ls.logFiles[k][i].Close(); err != nil {
@@ -151,8 +173,12 @@ func (ls *LogSaver) saveToFile(entry LogEntry) (string, error) {
 		name = ContestLogName
 	}
 
+	ls.logFilesL.RLock()
+	n, found := ls.logFiles[name]
+	ls.logFilesL.RUnlock()
+
 	var w io.Writer
-	switch n, found := ls.logFiles[name]; {
+	switch {
 	case !found:
 		return "", errors.Errorf("log file for %q not found", name)
 	case entry.IsError():
@@ -181,6 +207,9 @@ func (*LogSaver) createLogFile(logDir, name string) (io.WriteCloser, error) {
 }
 
 func (ls *LogSaver) syncs(updated map[string]struct{}) error {
+	ls.logFilesL.RLock()
+	defer ls.logFilesL.RUnlock()
+
 	for k := range updated {
 		for i := range ls.logFiles[k] {
 			if s, ok := ls.logFiles[k][i].(interface{ Sync() error }); !ok {
@@ -18,15 +18,14 @@ type LogWatcher struct {
 	*logging.Logging
 	*util.ContextDaemon
 	mg          *Mongodb
-	sqs         []*Sequence
+	walker      *StepWalker
 	exitChan    chan error
 	vars        *config.Vars
-	cl          int
 	storagePool map[string]*Mongodb
 }
 
-func NewLogWatcher(mg *Mongodb, sqs []*Sequence, exitChan chan error, vars *config.Vars) (*LogWatcher, error) {
-	if len(sqs) < 1 {
+func NewLogWatcher(mg *Mongodb, steps []Step, exitChan chan error, vars *config.Vars) (*LogWatcher, error) {
+	if len(steps) < 1 {
 		return nil, errors.Errorf("empty conditions")
 	}
 
@@ -35,7 +34,7 @@ func NewLogWatcher(mg *Mongodb, sqs []*Sequence, exitChan chan error, vars *conf
 			return c.Str("module", "log-watcher")
 		}),
 		mg:          mg,
-		sqs:         sqs,
+		walker:      NewStepWalker(steps),
 		exitChan:    exitChan,
 		vars:        vars,
 		storagePool: map[string]*Mongodb{},
@@ -50,19 +49,25 @@ func (lw *LogWatcher) start(ctx context.Context) error {
 	ticker := time.NewTicker(10 * time.Millisecond)
 	defer ticker.Stop()
 
-	current, _ := lw.Current()
-	lw.Log().Debug().Str("condition", current.Condition().QueryString()).Msg("starts with sequence")
-
 	var stopError error
+	switch current, found, err := lw.next(ctx); {
+	case err != nil:
+		stopError = err
+	case !found:
+		lw.Log().Info().Msg("no sequences to watch")
+	default:
+		lw.Log().Debug().Str("condition", current.Condition().QueryString()).Msg("starts with sequence")
+	}
+
 end:
-	for {
+	for stopError == nil {
 		select {
 		case <-ctx.Done():
 			break end
 		case <-ticker.C:
 			sq, found := lw.Current()
 			if !found {
-				continue
+				break end
 			}
 
 			if finished, err := lw.evaluate(ctx, sq); err != nil {
@@ -111,11 +116,14 @@ func (lw *LogWatcher) Current() (*Sequence, bool) {
 }
 
 func (lw *LogWatcher) current() (*Sequence, bool) {
-	if lw.cl == len(lw.sqs) {
-		return nil, false
-	}
+	return lw.walker.Current()
+}
+
+func (lw *LogWatcher) next(ctx context.Context) (*Sequence, bool, error) {
+	lw.Lock()
+	defer lw.Unlock()
 
-	return lw.sqs[lw.cl], true
+	return lw.walker.Next(ctx, lw.vars, lw.getStorage)
 }
 
 func (lw *LogWatcher) evaluate(ctx context.Context, sq *Sequence) (bool, error) {
@@ -140,29 +148,30 @@ func (lw *LogWatcher) evaluate(ctx context.Context, sq *Sequence) (bool, error)
 
 	l.Info().Interface("matched", record).Msg("codition matched")
 
-	lw.cl++
-
-	finished := lw.cl == len(lw.sqs)
-
 	if _, ok := sq.Action().(NullAction); !ok {
 		l.Debug().Interface("action", sq.Action()).Msg("trying to run action")
 		if err := sq.Action().Run(ctx); err != nil {
 			l.Error().Err(err).Msg("failed to run action")
 
-			return finished, err
+			return false, err
 		}
 	}
 
-	if nsq, found := lw.current(); found {
-		_, err := nsq.Condition().Query(lw.vars)
-		if err != nil {
-			return false, err
-		}
+	nsq, found, err := lw.walker.Next(ctx, lw.vars, lw.getStorage)
+	switch {
+	case err != nil:
+		return false, err
+	case !found:
+		return true, nil
+	}
 
-		l.Debug().Interface("next_condition", nsq.Condition().QueryString()).Msg("will wait next sequence")
+	if _, err := nsq.Condition().Query(lw.vars); err != nil {
+		return false, err
 	}
 
-	return finished, nil
+	l.Debug().Interface("next_condition", nsq.Condition().QueryString()).Msg("will wait next sequence")
+
+	return false, nil
 }
 
 func (lw *LogWatcher) getStorage(uri string) (*Mongodb, error) {
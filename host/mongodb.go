@@ -1,8 +1,12 @@
 package host
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spikeekips/contest/config"
@@ -70,6 +74,11 @@ func (mg *Mongodb) Close(ctx context.Context) error {
 	return mg.client.Disconnect(ctx)
 }
 
+// Drop drops the database.
+func (mg *Mongodb) Drop(ctx context.Context) error {
+	return mg.db.Drop(ctx)
+}
+
 func (mg *Mongodb) Initialize(ctx context.Context) error {
 	return mg.createIndices(ctx, colLogEntry, logEntryIndexModel, "contest_")
 }
@@ -92,6 +101,18 @@ func (mg *Mongodb) AddLogEntries(ctx context.Context, entries []LogEntry) error
 	return nil
 }
 
+func (mg *Mongodb) AddRecords(ctx context.Context, col string, records []interface{}) error {
+	if mg.client == nil || mg.db == nil {
+		return errors.Errorf("not yet connected")
+	}
+
+	if _, err := mg.db.Collection(col).InsertMany(ctx, records); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (mg *Mongodb) Find(ctx context.Context, col string, query bson.M) (map[string]interface{}, bool, error) {
 	option := options.FindOne()
 	option = option.SetSort(bson.D{{Key: "_id", Value: -1}})
@@ -110,6 +131,103 @@ func (mg *Mongodb) Find(ctx context.Context, col string, query bson.M) (map[stri
 	}
 }
 
+func (mg *Mongodb) Count(ctx context.Context, col string, query bson.M) (int64, error) {
+	return mg.db.Collection(col).CountDocuments(ctx, query)
+}
+
+// Aggregate runs the pipeline and returns the first result.
+func (mg *Mongodb) Aggregate(
+	ctx context.Context, col string, pipeline bson.A,
+) (map[string]interface{}, bool, error) {
+	cursor, err := mg.db.Collection(col).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	if !cursor.Next(ctx) {
+		return nil, false, cursor.Err()
+	}
+
+	var record map[string]interface{}
+	if err := cursor.Decode(&record); err != nil {
+		return nil, true, err
+	}
+
+	return record, true, nil
+}
+
+// FindAll returns all the records of query. The records are decoded through
+// extended JSON, so they can be handled like JSON values; numbers are
+// json.Number.
+func (mg *Mongodb) FindAll(
+	ctx context.Context, col string, query bson.M, sort bson.D,
+) ([]map[string]interface{}, error) {
+	option := options.Find()
+	if len(sort) > 0 {
+		option = option.SetSort(sort)
+	}
+
+	return mg.findAll(ctx, col, query, option)
+}
+
+// FindLatest returns the last records of query in insertion order; the
+// records are limited by limit.
+func (mg *Mongodb) FindLatest(
+	ctx context.Context, col string, query bson.M, limit int64,
+) ([]map[string]interface{}, error) {
+	records, err := mg.findAll(ctx, col, query,
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	return records, nil
+}
+
+func (mg *Mongodb) findAll(
+	ctx context.Context, col string, query bson.M, option *options.FindOptions,
+) ([]map[string]interface{}, error) {
+	cursor, err := mg.db.Collection(col).Find(ctx, query, option)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = cursor.Close(ctx)
+	}()
+
+	var records []map[string]interface{}
+	for cursor.Next(ctx) {
+		b, err := bson.MarshalExtJSON(cursor.Current, false, false)
+		if err != nil {
+			return nil, err
+		}
+
+		var record map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&record); err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
 func (mg *Mongodb) createIndices(ctx context.Context, col string, models []mongo.IndexModel, prefix string) error {
 	iv := mg.db.Collection(col).Indexes()
 
@@ -152,6 +270,49 @@ func (mg *Mongodb) createIndices(ctx context.Context, col string, models []mongo
 	return nil
 }
 
+// NewStorageGetter returns the function, which connects and keeps the storages
+// by uri, and the function to close them.
+func NewStorageGetter() (func(string) (*Mongodb, error), func()) {
+	var lock sync.Mutex
+	pool := map[string]*Mongodb{}
+
+	get := func(uri string) (*Mongodb, error) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if i, found := pool[uri]; found {
+			return i, nil
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+		defer cancel()
+
+		i, err := NewMongodbFromString(uri)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to ready storage")
+		} else if err := i.Connect(ctx); err != nil {
+			return nil, errors.Wrap(err, "failed to connect storage")
+		}
+
+		pool[uri] = i
+
+		return i, nil
+	}
+
+	closeAll := func() {
+		lock.Lock()
+		defer lock.Unlock()
+
+		for uri := range pool {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+			_ = pool[uri].Close(ctx)
+			cancel()
+		}
+	}
+
+	return get, closeAll
+}
+
 type LogEntryBSON struct {
 	l LogEntry
 }
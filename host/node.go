@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"sync"
 
 	"github.com/docker/go-connections/nat"
+	"github.com/pkg/errors"
 	"github.com/spikeekips/mitum/base/key"
 	"gopkg.in/yaml.v3"
 
@@ -42,6 +45,13 @@ type Node struct {
 	templateLock sync.RWMutex
 	configData   []byte
 	configMap    map[string]interface{}
+	rawConfig    map[string]interface{}
+	nodesConfig  []byte
+	disk         *Disk
+	clock        *Clock
+	timeServer   string
+	ip           string // NOTE ip of running node container
+	shared       map[string]interface{}
 }
 
 func NewNode(alias string, host Host) (*Node, error) {
@@ -71,6 +81,37 @@ func (no *Node) ConfigFile() string {
 	return filepath.Join(no.host.BaseDir(), fmt.Sprintf("%s.yml", no.alias))
 }
 
+// DataDir is the host directory, which is mounted at "/data" of node
+// container.
+func (no *Node) DataDir() string {
+	return filepath.Join(no.host.BaseDir(), no.alias)
+}
+
+func (no *Node) Disk() *Disk {
+	return no.disk
+}
+
+// Clock returns the clock of node; if nil, the node uses the time of host.
+func (no *Node) Clock() *Clock {
+	return no.clock
+}
+
+// IP returns the ip address of the node container in test network; it is set
+// when the node container starts.
+func (no *Node) IP() string {
+	no.RLock()
+	defer no.RUnlock()
+
+	return no.ip
+}
+
+func (no *Node) SetIP(ip string) {
+	no.Lock()
+	defer no.Unlock()
+
+	no.ip = ip
+}
+
 func (no *Node) LogFile() string {
 	return filepath.Join(no.host.BaseDir(), fmt.Sprintf("%s.log", no.alias))
 }
@@ -79,6 +120,31 @@ func (no *Node) ConfigMap() map[string]interface{} {
 	return no.configMap
 }
 
+// StorageURI returns the uri of node storage, which is reachable from contest.
+// The database is from "storage.database.uri" of node config; if not found,
+// "contest_<alias>" is used.
+func (no *Node) StorageURI() (string, error) {
+	db := fmt.Sprintf("contest_%s", no.alias)
+
+	if i, found := config.NewVars(no.configMap).Value("Storage.Database.URI"); found {
+		s, ok := i.(string)
+		if !ok {
+			return "", errors.Errorf("storage uri of node, %q is not string, %T", no.alias, i)
+		}
+
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid storage uri of node, %q", no.alias)
+		}
+
+		if p := strings.Trim(u.Path, "/"); len(p) > 0 {
+			db = p
+		}
+	}
+
+	return fmt.Sprintf("%s/%s", no.host.MongodbExternalURI(no.alias), db), nil
+}
+
 func (no *Node) PortMap() nat.PortMap {
 	return no.portMap
 }
@@ -92,7 +158,23 @@ func (no *Node) Prepare(commonDesign, design string, vars *config.Vars) (map[str
 
 	nvars := no.prepareVars(vars)
 
-	return no.prepareNodeConfig(nvars)
+	shared, err := no.prepareNodeConfig(nvars)
+	if err != nil {
+		return nil, err
+	}
+
+	no.shared = shared
+
+	return shared, nil
+}
+
+// Shared returns the values of node config, which are shared with the other
+// nodes, like "nodes-config".
+func (no *Node) Shared() map[string]interface{} {
+	no.RLock()
+	defer no.RUnlock()
+
+	return no.shared
 }
 
 func (no *Node) prepareNodeConfig(vars *config.Vars) (map[string]interface{}, error) {
@@ -119,16 +201,105 @@ func (no *Node) prepareNodeConfig(vars *config.Vars) (map[string]interface{}, er
 		shared[k[1:]] = merged[k]
 	}
 
-	b, err := yaml.Marshal(filtered)
-	if err != nil {
+	if len(no.timeServer) > 0 {
+		filtered["time-server"] = no.timeServer
+	}
+
+	if err := no.setConfig(filtered); err != nil {
 		return nil, err
 	}
-	no.configMap = config.SanitizeVarsMap(filtered).(map[string]interface{})
-	no.configData = bytes.TrimSpace(b)
 
 	return shared, nil
 }
 
+func (no *Node) setConfig(m map[string]interface{}) error {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	no.rawConfig = m
+	no.configMap = config.SanitizeVarsMap(m).(map[string]interface{})
+	no.configData = bytes.TrimSpace(b)
+
+	return nil
+}
+
+// Patch merges the patch into the node config; like the node design, the
+// keys prefixed with "_" are merged into the shared values. It returns the
+// changes of node config and shared values.
+func (no *Node) Patch(patch map[string]interface{}) ([]config.MapDiff, error) {
+	no.Lock()
+	defer no.Unlock()
+
+	filtered := map[string]interface{}{}
+	shared := map[string]interface{}{}
+	for k := range patch {
+		if !strings.HasPrefix(k, "_") {
+			filtered[k] = patch[k]
+
+			continue
+		}
+
+		shared[k[1:]] = patch[k]
+	}
+
+	if len(no.timeServer) > 0 {
+		delete(filtered, "time-server") // NOTE time server is managed by host
+	}
+
+	merged, err := config.MergeItem(no.rawConfig, filtered)
+	if err != nil {
+		return nil, err
+	}
+
+	mergedShared, err := config.MergeItem(no.shared, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := config.DiffMap(no.rawConfig, merged)
+	for _, d := range config.DiffMap(no.shared, mergedShared) {
+		d.Path = "_" + d.Path
+		diffs = append(diffs, d)
+	}
+
+	if err := no.setConfig(merged); err != nil {
+		return nil, err
+	}
+
+	no.shared = mergedShared
+
+	return diffs, nil
+}
+
+// NodesConfig returns the last saved nodes config.
+func (no *Node) NodesConfig() []byte {
+	no.RLock()
+	defer no.RUnlock()
+
+	return no.nodesConfig
+}
+
+// SaveConfig writes the node config with the nodes config to ConfigFile.
+func (no *Node) SaveConfig(nodesConfig []byte) error {
+	no.Lock()
+	defer no.Unlock()
+
+	c := make([]byte, 0, len(no.configData)+len(nodesConfig)+1)
+	c = append(c, no.configData...)
+	c = append(c, '\n')
+	c = append(c, nodesConfig...)
+
+	if err := ioutil.WriteFile(no.ConfigFile(), c, 0o600); err != nil {
+		return err
+	}
+
+	no.nodesConfig = nodesConfig
+
+	return nil
+}
+
 func (no *Node) prepareVars(vars *config.Vars) *config.Vars {
 	self := map[string]interface{}{
 		"Alias": no.alias,
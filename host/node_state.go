@@ -0,0 +1,284 @@
+package host
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+
+	"github.com/spikeekips/contest/config"
+)
+
+// NodeStateRecord is the state change of node.
+type NodeStateRecord struct {
+	State    string    `json:"state"`
+	ExitCode int64     `json:"exit_code,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	At       time.Time `json:"at"`
+}
+
+type nodeState struct {
+	run     uint64 // NOTE increased whenever node starts to run
+	history []NodeStateRecord
+	exited  chan struct{} // NOTE closed when the current run exits
+}
+
+func (s *nodeState) exitedChan() chan struct{} {
+	if s.exited == nil {
+		s.exited = make(chan struct{})
+
+		if s.current().State == config.NodeStateExited {
+			close(s.exited)
+		}
+	}
+
+	return s.exited
+}
+
+func (s *nodeState) current() NodeStateRecord {
+	return s.history[len(s.history)-1]
+}
+
+func (s *nodeState) restarts() uint64 {
+	if s.run < 1 {
+		return 0
+	}
+
+	return s.run - 1
+}
+
+// NodeStates keeps the lifecycle states of nodes. The current state is set to
+// vars, "Runtime.Node.<alias>.State", ".ExitCode" and ".Restarts" and every
+// change is saved as node log entry.
+type NodeStates struct {
+	sync.RWMutex
+	*logging.Logging
+	lo     *LogSaver
+	vars   *config.Vars
+	states map[ /* node alias */ string]*nodeState
+}
+
+func NewNodeStates(lo *LogSaver, vars *config.Vars) *NodeStates {
+	return &NodeStates{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "node-states")
+		}),
+		lo:     lo,
+		vars:   vars,
+		states: map[string]*nodeState{},
+	}
+}
+
+// Add adds the node, which is not started yet.
+func (ns *NodeStates) Add(alias string) {
+	ns.Lock()
+	defer ns.Unlock()
+
+	if _, found := ns.states[alias]; found {
+		return
+	}
+
+	ns.add(alias)
+}
+
+func (ns *NodeStates) add(alias string) *nodeState {
+	s := &nodeState{
+		history: []NodeStateRecord{{State: config.NodeStateNotStarted, At: time.Now().UTC()}},
+	}
+	ns.states[alias] = s
+
+	ns.setVars(alias, s)
+
+	return s
+}
+
+// Set changes the state of node and returns the run of node. The run is
+// increased when the stopped node starts to run, so the late exit of the
+// previous run can be ignored by Exit.
+func (ns *NodeStates) Set(alias, state, reason string) uint64 {
+	run, _ := ns.set(alias, NodeStateRecord{State: state, Reason: reason, At: time.Now().UTC()}, false)
+
+	return run
+}
+
+// Observe applies the state, which is observed at the given time, like the
+// docker events; it is ignored if the state of node was changed after it.
+func (ns *NodeStates) Observe(alias, state string, exitCode int64, at time.Time, reason string) bool {
+	_, changed := ns.set(alias, NodeStateRecord{State: state, ExitCode: exitCode, Reason: reason, At: at.UTC()}, true)
+
+	return changed
+}
+
+func (ns *NodeStates) set(alias string, r NodeStateRecord, observed bool) (uint64, bool) {
+	run, changed := func() (uint64, bool) {
+		ns.Lock()
+		defer ns.Unlock()
+
+		s, found := ns.states[alias]
+		if !found {
+			s = ns.add(alias)
+		}
+
+		switch c := s.current(); {
+		case observed && r.At.Before(c.At):
+			return s.run, false
+		case c.State == r.State:
+			return s.run, false
+		case r.State == config.NodeStateRunning && c.State != config.NodeStatePaused:
+			s.run++
+		}
+
+		_ = ns.append(alias, s, r)
+
+		return s.run, true
+	}()
+
+	if changed {
+		ns.save(alias, r)
+	}
+
+	return run, changed
+}
+
+// Exit changes the state of node to exited. If run is not 0, only the exit of
+// the given run is accepted; the exited node is ignored.
+func (ns *NodeStates) Exit(alias string, run uint64, exitCode int64, reason string) bool {
+	r, changed := func() (NodeStateRecord, bool) {
+		ns.Lock()
+		defer ns.Unlock()
+
+		s, found := ns.states[alias]
+		if !found {
+			s = ns.add(alias)
+		}
+
+		if (run > 0 && run != s.run) || s.current().State == config.NodeStateExited {
+			return NodeStateRecord{}, false
+		}
+
+		return ns.append(alias, s, NodeStateRecord{
+			State:    config.NodeStateExited,
+			ExitCode: exitCode,
+			Reason:   reason,
+			At:       time.Now().UTC(),
+		}), true
+	}()
+
+	if changed {
+		ns.save(alias, r)
+	}
+
+	return changed
+}
+
+// State returns the current state and the number of restarts of node.
+func (ns *NodeStates) State(alias string) (NodeStateRecord, uint64) {
+	ns.RLock()
+	defer ns.RUnlock()
+
+	s, found := ns.states[alias]
+	if !found {
+		return NodeStateRecord{State: config.NodeStateNotStarted}, 0
+	}
+
+	return s.current(), s.restarts()
+}
+
+// Exited returns the channel, which is closed when the current run of node
+// exits. For the exited node, the closed channel is returned.
+func (ns *NodeStates) Exited(alias string) <-chan struct{} {
+	ns.Lock()
+	defer ns.Unlock()
+
+	s, found := ns.states[alias]
+	if !found {
+		s = ns.add(alias)
+	}
+
+	return s.exitedChan()
+}
+
+// History returns the all the state changes of node.
+func (ns *NodeStates) History(alias string) []NodeStateRecord {
+	ns.RLock()
+	defer ns.RUnlock()
+
+	s, found := ns.states[alias]
+	if !found {
+		return nil
+	}
+
+	h := make([]NodeStateRecord, len(s.history))
+	copy(h, s.history)
+
+	return h
+}
+
+// Aliases returns the sorted aliases of nodes.
+func (ns *NodeStates) Aliases() []string {
+	ns.RLock()
+	defer ns.RUnlock()
+
+	aliases := make([]string, 0, len(ns.states))
+	for alias := range ns.states {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	return aliases
+}
+
+func (ns *NodeStates) append(alias string, s *nodeState, r NodeStateRecord) NodeStateRecord {
+	previous := s.current().State
+	s.history = append(s.history, r)
+
+	switch {
+	case r.State == config.NodeStateExited && previous != config.NodeStateExited:
+		if s.exited != nil {
+			close(s.exited)
+		}
+	case r.State != config.NodeStateExited && previous == config.NodeStateExited:
+		s.exited = nil // NOTE new run
+	}
+
+	ns.setVars(alias, s)
+
+	return r
+}
+
+func (ns *NodeStates) setVars(alias string, s *nodeState) {
+	if ns.vars == nil {
+		return
+	}
+
+	c := s.current()
+
+	ns.vars.Set(fmt.Sprintf("Runtime.Node.%s.State", alias), c.State)
+	ns.vars.Set(fmt.Sprintf("Runtime.Node.%s.ExitCode", alias), c.ExitCode)
+	ns.vars.Set(fmt.Sprintf("Runtime.Node.%s.Restarts", alias), s.restarts())
+}
+
+func (ns *NodeStates) save(alias string, r NodeStateRecord) {
+	ns.Log().Debug().Str("node", alias).Interface("state", r).Msg("node state changed")
+
+	if ns.lo == nil {
+		return
+	}
+
+	e, err := NewNodeLogEntryWithInterface(alias, map[string]interface{}{
+		"m":         "node state",
+		"state":     r.State,
+		"exit_code": r.ExitCode,
+		"reason":    r.Reason,
+	}, false)
+	if err != nil {
+		ns.Log().Error().Err(err).Msg("failed to make log entry")
+
+		return
+	}
+
+	ns.lo.LogEntryChan() <- e
+}
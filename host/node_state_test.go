@@ -0,0 +1,187 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/spikeekips/contest/config"
+)
+
+type testNodeStates struct {
+	suite.Suite
+	vars *config.Vars
+	ns   *NodeStates
+}
+
+func (t *testNodeStates) SetupTest() {
+	t.vars = config.NewVars(nil)
+	t.ns = NewNodeStates(nil, t.vars)
+}
+
+func (t *testNodeStates) checkVars(alias, state string, exitCode int64, restarts uint64) {
+	v, found := t.vars.Value("Runtime.Node." + alias + ".State")
+	t.True(found)
+	t.Equal(state, v)
+
+	v, found = t.vars.Value("Runtime.Node." + alias + ".ExitCode")
+	t.True(found)
+	t.Equal(exitCode, v)
+
+	v, found = t.vars.Value("Runtime.Node." + alias + ".Restarts")
+	t.True(found)
+	t.Equal(restarts, v)
+}
+
+func (t *testNodeStates) TestAdd() {
+	t.ns.Add("no0")
+	t.checkVars("no0", config.NodeStateNotStarted, 0, 0)
+
+	s, restarts := t.ns.State("no0")
+	t.Equal(config.NodeStateNotStarted, s.State)
+	t.Equal(uint64(0), restarts)
+
+	t.Equal(uint64(1), t.ns.Set("no0", config.NodeStateRunning, ""))
+
+	t.ns.Add("no0") // NOTE already added
+	s, _ = t.ns.State("no0")
+	t.Equal(config.NodeStateRunning, s.State)
+
+	s, restarts = t.ns.State("no1") // NOTE unknown node
+	t.Equal(config.NodeStateNotStarted, s.State)
+	t.Equal(uint64(0), restarts)
+	t.Nil(t.ns.History("no1"))
+
+	t.ns.Add("no1")
+	t.Equal([]string{"no0", "no1"}, t.ns.Aliases())
+}
+
+func (t *testNodeStates) TestRestarts() {
+	t.Equal(uint64(1), t.ns.Set("no0", config.NodeStateRunning, ""))
+	t.checkVars("no0", config.NodeStateRunning, 0, 0)
+
+	t.True(t.ns.Exit("no0", 1, 3, "killed"))
+	t.checkVars("no0", config.NodeStateExited, 3, 0)
+
+	t.Equal(uint64(2), t.ns.Set("no0", config.NodeStateRunning, ""))
+	t.checkVars("no0", config.NodeStateRunning, 0, 1)
+
+	t.Equal(uint64(2), t.ns.Set("no0", config.NodeStateRunning, ""), "same state")
+
+	t.True(t.ns.Exit("no0", 0, 0, ""))
+	t.Equal(uint64(3), t.ns.Set("no0", config.NodeStateRunning, ""))
+
+	s, restarts := t.ns.State("no0")
+	t.Equal(config.NodeStateRunning, s.State)
+	t.Equal(uint64(2), restarts)
+	t.checkVars("no0", config.NodeStateRunning, 0, 2)
+
+	states := make([]string, 0)
+	for _, r := range t.ns.History("no0") {
+		states = append(states, r.State)
+	}
+
+	t.Equal([]string{
+		config.NodeStateNotStarted,
+		config.NodeStateRunning, config.NodeStateExited,
+		config.NodeStateRunning, config.NodeStateExited,
+		config.NodeStateRunning,
+	}, states)
+}
+
+func (t *testNodeStates) TestPause() {
+	t.Equal(uint64(1), t.ns.Set("no0", config.NodeStateRunning, ""))
+
+	t.Equal(uint64(1), t.ns.Set("no0", config.NodeStatePaused, "paused"))
+	t.checkVars("no0", config.NodeStatePaused, 0, 0)
+
+	t.Equal(uint64(1), t.ns.Set("no0", config.NodeStateRunning, "unpaused"))
+	t.checkVars("no0", config.NodeStateRunning, 0, 0)
+
+	// NOTE exit of the current run is accepted after unpause
+	t.True(t.ns.Exit("no0", 1, 1, ""))
+	t.checkVars("no0", config.NodeStateExited, 1, 0)
+}
+
+func (t *testNodeStates) TestStaleExit() {
+	run := t.ns.Set("no0", config.NodeStateRunning, "")
+	t.True(t.ns.Exit("no0", run, 1, "stopped"))
+
+	t.Equal(run+1, t.ns.Set("no0", config.NodeStateRunning, "restarted"))
+
+	// NOTE late exit of the previous run
+	t.False(t.ns.Exit("no0", run, 2, "late"))
+	t.checkVars("no0", config.NodeStateRunning, 0, 1)
+
+	t.True(t.ns.Exit("no0", run+1, 3, ""))
+	t.False(t.ns.Exit("no0", 0, 4, ""), "already exited")
+	t.checkVars("no0", config.NodeStateExited, 3, 1)
+}
+
+func (t *testNodeStates) isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(time.Millisecond * 10):
+		return false
+	}
+}
+
+func (t *testNodeStates) TestExited() {
+	t.ns.Add("no0")
+
+	notStarted := t.ns.Exited("no0")
+	t.False(t.isClosed(notStarted))
+
+	run := t.ns.Set("no0", config.NodeStateRunning, "")
+
+	first := t.ns.Exited("no0")
+	t.False(t.isClosed(first))
+
+	go func() {
+		<-time.After(time.Millisecond * 100)
+		_ = t.ns.Exit("no0", run, 3, "")
+	}()
+
+	select {
+	case <-first:
+	case <-time.After(time.Second * 2):
+		t.Fail("failed to wait exit")
+	}
+
+	t.True(t.isClosed(notStarted))
+	t.True(t.isClosed(t.ns.Exited("no0")), "exited node")
+
+	// NOTE new run
+	run = t.ns.Set("no0", config.NodeStateRunning, "")
+
+	second := t.ns.Exited("no0")
+	t.False(t.isClosed(second))
+
+	t.ns.Set("no0", config.NodeStatePaused, "")
+	t.False(t.isClosed(second))
+
+	t.True(t.ns.Exit("no0", run, 0, ""))
+	t.True(t.isClosed(second))
+
+	t.False(t.isClosed(t.ns.Exited("no9")), "unknown node")
+}
+
+func (t *testNodeStates) TestObserve() {
+	before := time.Now().Add(-time.Minute)
+
+	t.Equal(uint64(1), t.ns.Set("no0", config.NodeStateRunning, ""))
+
+	t.False(t.ns.Observe("no0", config.NodeStateExited, 1, before, "old event"))
+	t.checkVars("no0", config.NodeStateRunning, 0, 0)
+
+	t.True(t.ns.Observe("no0", config.NodeStateExited, 137, time.Now(), "die"))
+	t.checkVars("no0", config.NodeStateExited, 137, 0)
+
+	t.False(t.ns.Observe("no0", config.NodeStateExited, 0, time.Now(), "die again"))
+}
+
+func TestNodeStates(t *testing.T) {
+	suite.Run(t, new(testNodeStates))
+}
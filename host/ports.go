@@ -0,0 +1,227 @@
+package host
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util/logging"
+)
+
+var (
+	// PortsFile keeps the reserved ports of the contest processes in the same
+	// host.
+	PortsFile = filepath.Join(os.TempDir(), "mitum-contest-ports.json")
+	// NOTE portsMaxTries is the number of random tries to find the
+	// available port.
+	portsMaxTries = 1000
+)
+
+// PortReservation is the reserved port by contest process.
+type PortReservation struct {
+	PID      int       `json:"pid"`
+	TestName string    `json:"test_name"`
+	At       time.Time `json:"at"`
+}
+
+// Ports reserves the host ports for the containers. The reservations are
+// kept in the file, which is locked while updating, so the contest processes
+// in the same host do not pick the same port. The reservation is kept until
+// Release, so the port is not taken before docker binds it; the reservations
+// of the dead processes are ignored.
+type Ports struct {
+	sync.Mutex
+	*logging.Logging
+	f        string
+	r        [2]uint16
+	testName string
+	reserved map[ /* network/port */ string]string
+}
+
+func NewPorts(f string, r [2]uint16, testName string) *Ports {
+	return &Ports{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "ports")
+		}),
+		f:        f,
+		r:        r,
+		testName: testName,
+		reserved: map[string]string{},
+	}
+}
+
+// Reserve finds the available port in the range and reserves it.
+func (ps *Ports) Reserve(network string) (string, error) {
+	switch network {
+	case "tcp", "udp":
+	default:
+		return "", errors.Errorf("unknown network, %q", network)
+	}
+
+	ps.Lock()
+	defer ps.Unlock()
+
+	var port string
+	if err := ps.update(func(rs map[string]PortReservation) (bool, error) {
+		for i := 0; i < portsMaxTries; i++ {
+			p := ps.randPort()
+
+			key := fmt.Sprintf("%s/%d", network, p)
+			if _, found := rs[key]; found {
+				continue
+			}
+
+			if err := checkPort(network, p); err != nil {
+				continue
+			}
+
+			rs[key] = PortReservation{PID: os.Getpid(), TestName: ps.testName, At: time.Now().UTC()}
+			ps.reserved[key] = fmt.Sprintf("%d", p)
+			port = ps.reserved[key]
+
+			return true, nil
+		}
+
+		return false, errors.Errorf("no available %s port in range, %d-%d", network, ps.r[0], ps.r[1])
+	}); err != nil {
+		return "", err
+	}
+
+	ps.Log().Debug().Str("network", network).Str("port", port).Msg("port reserved")
+
+	return port, nil
+}
+
+// Release releases all the reserved ports.
+func (ps *Ports) Release() error {
+	ps.Lock()
+	defer ps.Unlock()
+
+	if len(ps.reserved) < 1 {
+		return nil
+	}
+
+	if err := ps.update(func(rs map[string]PortReservation) (bool, error) {
+		for key := range ps.reserved {
+			if r, found := rs[key]; found && r.PID == os.Getpid() {
+				delete(rs, key)
+			}
+		}
+
+		return true, nil
+	}); err != nil {
+		return err
+	}
+
+	ps.Log().Debug().Int("ports", len(ps.reserved)).Msg("ports released")
+
+	ps.reserved = map[string]string{}
+
+	return nil
+}
+
+// Reserved returns the reserved ports, like "tcp/20001".
+func (ps *Ports) Reserved() []string {
+	ps.Lock()
+	defer ps.Unlock()
+
+	keys := make([]string, 0, len(ps.reserved))
+	for key := range ps.reserved {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (ps *Ports) randPort() uint16 {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(ps.r[1])-int64(ps.r[0])+1))
+	if err != nil {
+		panic(err)
+	}
+
+	return uint16(n.Int64() + int64(ps.r[0]))
+}
+
+// update loads the reservations under the file lock and saves them, if
+// callback returns true.
+func (ps *Ports) update(callback func(map[string]PortReservation) (bool, error)) error {
+	f, err := os.OpenFile(filepath.Clean(ps.f), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open ports file")
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		return errors.Wrap(err, "failed to lock ports file")
+	}
+
+	defer func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	}()
+
+	rs := map[string]PortReservation{}
+
+	switch b, err := io.ReadAll(f); {
+	case err != nil:
+		return errors.Wrap(err, "failed to read ports file")
+	case len(b) > 0:
+		if err := json.Unmarshal(b, &rs); err != nil {
+			ps.Log().Error().Err(err).Msg("broken ports file; reset")
+
+			rs = map[string]PortReservation{}
+		}
+	}
+
+	for key := range rs {
+		if !processAlive(rs[key].PID) {
+			delete(rs, key)
+		}
+	}
+
+	switch save, err := callback(rs); {
+	case err != nil:
+		return err
+	case !save:
+		return nil
+	}
+
+	b, err := json.Marshal(rs)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Truncate(0); err != nil {
+		return errors.Wrap(err, "failed to write ports file")
+	}
+
+	if _, err := f.WriteAt(b, 0); err != nil {
+		return errors.Wrap(err, "failed to write ports file")
+	}
+
+	return nil
+}
+
+func processAlive(pid int) bool {
+	if pid < 1 {
+		return false
+	}
+
+	err := syscall.Kill(pid, 0)
+
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
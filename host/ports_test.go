@@ -0,0 +1,170 @@
+package host
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// NOTE deadPID is over the maximum pid_max of linux.
+var deadPID = 1<<22 + 1
+
+type testPorts struct {
+	suite.Suite
+	f string
+	r [2]uint16
+}
+
+func (t *testPorts) SetupTest() {
+	t.f = filepath.Join(t.T().TempDir(), "ports.json")
+	t.r = [2]uint16{45000, 45049}
+}
+
+func (t *testPorts) load() map[string]PortReservation {
+	b, err := ioutil.ReadFile(t.f)
+	t.NoError(err)
+
+	rs := map[string]PortReservation{}
+	t.NoError(json.Unmarshal(b, &rs))
+
+	return rs
+}
+
+func (t *testPorts) save(rs map[string]PortReservation) {
+	b, err := json.Marshal(rs)
+	t.NoError(err)
+	t.NoError(ioutil.WriteFile(t.f, b, 0o600))
+}
+
+func (t *testPorts) freePort() uint16 {
+	l, err := net.Listen("tcp", ":0")
+	t.NoError(err)
+	defer func() {
+		_ = l.Close()
+	}()
+
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
+func (t *testPorts) TestUnknownNetwork() {
+	ps := NewPorts(t.f, t.r, "a")
+
+	_, err := ps.Reserve("sctp")
+	t.Error(err)
+	t.Contains(err.Error(), "unknown network")
+
+	_, err = os.Stat(t.f)
+	t.True(os.IsNotExist(err), "ports file touched")
+}
+
+func (t *testPorts) TestNoDuplicate() {
+	a := NewPorts(t.f, t.r, "a")
+	b := NewPorts(t.f, t.r, "b")
+
+	ports := map[string]struct{}{}
+	for i := 0; i < 10; i++ {
+		for _, ps := range []*Ports{a, b} {
+			p, err := ps.Reserve("tcp")
+			t.NoError(err)
+
+			_, found := ports[p]
+			t.False(found, "port, %q reserved twice", p)
+
+			ports[p] = struct{}{}
+		}
+	}
+
+	t.Equal(20, len(t.load()))
+	t.Equal(10, len(a.Reserved()))
+	t.Equal(10, len(b.Reserved()))
+
+	// NOTE only one port in range; it is still available for the other network
+	p := t.freePort()
+	a.r = [2]uint16{p, p}
+	b.r = [2]uint16{p, p}
+
+	_, err := a.Reserve("tcp")
+	t.NoError(err)
+
+	_, err = b.Reserve("tcp")
+	t.Error(err)
+	t.Contains(err.Error(), "no available tcp port")
+
+	_, err = b.Reserve("udp")
+	t.NoError(err)
+}
+
+func (t *testPorts) TestRelease() {
+	other := PortReservation{PID: os.Getppid(), TestName: "other", At: time.Now().UTC()}
+	t.save(map[string]PortReservation{"tcp/1": other})
+
+	a := NewPorts(t.f, t.r, "a")
+	b := NewPorts(t.f, t.r, "b")
+
+	for _, ps := range []*Ports{a, b} {
+		for i := 0; i < 3; i++ {
+			_, err := ps.Reserve("tcp")
+			t.NoError(err)
+		}
+	}
+
+	// NOTE port of a is taken by the other process
+	taken := a.Reserved()[0]
+	rs := t.load()
+	rs[taken] = other
+	t.save(rs)
+
+	t.NoError(a.Release())
+	t.Empty(a.Reserved())
+
+	rs = t.load()
+	t.Equal(5, len(rs))
+	t.Equal("other", rs["tcp/1"].TestName)
+	t.Equal("other", rs[taken].TestName)
+
+	for _, key := range b.Reserved() {
+		t.Equal("b", rs[key].TestName)
+	}
+
+	t.NoError(b.Release())
+	t.Equal(2, len(t.load()))
+}
+
+func (t *testPorts) TestPruneDead() {
+	t.save(map[string]PortReservation{
+		"tcp/1": {PID: deadPID, TestName: "dead"},
+		"tcp/2": {PID: 0, TestName: "zero"},
+		"tcp/3": {PID: os.Getppid(), TestName: "alive"},
+	})
+
+	ps := NewPorts(t.f, t.r, "a")
+	p, err := ps.Reserve("tcp")
+	t.NoError(err)
+
+	rs := t.load()
+	t.Equal(2, len(rs))
+	t.Equal("alive", rs["tcp/3"].TestName)
+	t.Equal("a", rs["tcp/"+p].TestName)
+}
+
+func (t *testPorts) TestBrokenFile() {
+	t.NoError(ioutil.WriteFile(t.f, []byte(`{"tcp/1": {"pid": `), 0o600))
+
+	ps := NewPorts(t.f, t.r, "a")
+	p, err := ps.Reserve("udp")
+	t.NoError(err)
+
+	rs := t.load()
+	t.Equal(1, len(rs))
+	t.Equal(os.Getpid(), rs["udp/"+p].PID)
+}
+
+func TestPorts(t *testing.T) {
+	suite.Run(t, new(testPorts))
+}
@@ -0,0 +1,362 @@
+package host
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	"github.com/pkg/errors"
+	"github.com/spikeekips/mitum/util"
+
+	"github.com/spikeekips/contest/config"
+)
+
+var (
+	snapshotDataFile = "data.tar.gz"
+	snapshotDBFile   = "db.archive"
+	snapshotMetaFile = "snapshot.json"
+)
+
+// SnapshotMeta describes the snapshot of node.
+type SnapshotMeta struct {
+	Node     string    `json:"node"`
+	Database string    `json:"database"`
+	Created  time.Time `json:"created"`
+}
+
+// SnapshotDir returns the directory of snapshot of node.
+func SnapshotDir(h Host, name, alias string) string {
+	return filepath.Join(h.BaseDir(), "snapshots", name, alias)
+}
+
+// SnapshotNode archives the data directory and database of node under the
+// snapshot name. The node should be stopped.
+func SnapshotNode(ctx context.Context, node *Node, name string) (SnapshotMeta, error) {
+	db, err := nodeDatabase(node)
+	if err != nil {
+		return SnapshotMeta{}, err
+	}
+
+	dir := SnapshotDir(node.Host(), name, node.Alias())
+	if err := os.RemoveAll(dir); err != nil {
+		return SnapshotMeta{}, errors.Wrap(err, "failed to clean snapshot directory")
+	} else if err := os.MkdirAll(dir, 0o700); err != nil {
+		return SnapshotMeta{}, errors.Wrap(err, "failed to create snapshot directory")
+	}
+
+	if err := archiveDir(node.DataDir(), filepath.Join(dir, snapshotDataFile)); err != nil {
+		return SnapshotMeta{}, err
+	}
+
+	b, err := mongodbExec(ctx, node, []string{"mongodump", "--quiet", "--gzip", "--archive", "--db", db})
+	if err != nil {
+		return SnapshotMeta{}, errors.Wrap(err, "failed to dump database")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, snapshotDBFile), b, 0o600); err != nil {
+		return SnapshotMeta{}, errors.Wrap(err, "failed to save database archive")
+	}
+
+	meta := SnapshotMeta{Node: node.Alias(), Database: db, Created: time.Now().UTC()}
+
+	mb, err := json.Marshal(meta)
+	if err != nil {
+		return SnapshotMeta{}, err
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, snapshotMetaFile), mb, 0o600); err != nil {
+		return SnapshotMeta{}, errors.Wrap(err, "failed to save snapshot meta")
+	}
+
+	return meta, nil
+}
+
+// RestoreNode restores the snapshot of node, from into node; from can be
+// different with node. The node should be stopped.
+func RestoreNode(ctx context.Context, node *Node, name, from string) (SnapshotMeta, error) {
+	dir := SnapshotDir(node.Host(), name, from)
+
+	var meta SnapshotMeta
+	if b, err := ioutil.ReadFile(filepath.Clean(filepath.Join(dir, snapshotMetaFile))); err != nil {
+		return meta, errors.Wrapf(err, "failed to load snapshot, %q of node, %q", name, from)
+	} else if err := json.Unmarshal(b, &meta); err != nil {
+		return meta, errors.Wrap(err, "invalid snapshot meta")
+	}
+
+	db, err := nodeDatabase(node)
+	if err != nil {
+		return meta, err
+	}
+
+	if err := cleanDir(node.DataDir()); err != nil {
+		return meta, err
+	} else if err := extractDir(filepath.Join(dir, snapshotDataFile), node.DataDir()); err != nil {
+		return meta, err
+	}
+
+	archive := fmt.Sprintf("/tmp/contest-restore-%s.archive", util.UUID().String())
+	if err := copyToMongodb(ctx, node, filepath.Join(dir, snapshotDBFile), archive); err != nil {
+		return meta, err
+	}
+
+	defer func() {
+		_, _ = mongodbExec(context.Background(), node, []string{"rm", "-f", archive})
+	}()
+
+	if _, err := mongodbExec(ctx, node, []string{
+		"mongorestore", "--quiet", "--gzip", "--drop",
+		"--archive=" + archive,
+		"--nsFrom", meta.Database + ".*",
+		"--nsTo", db + ".*",
+	}); err != nil {
+		return meta, errors.Wrap(err, "failed to restore database")
+	}
+
+	return meta, nil
+}
+
+// ResetNode wipes the data directory and database of node. The node should
+// be stopped.
+func ResetNode(ctx context.Context, node *Node) error {
+	db, err := nodeDatabase(node)
+	if err != nil {
+		return err
+	}
+
+	if err := cleanDir(node.DataDir()); err != nil {
+		return err
+	}
+
+	uri, err := node.StorageURI()
+	if err != nil {
+		return err
+	}
+
+	mg, err := NewMongodbFromString(uri)
+	if err != nil {
+		return err
+	} else if err := mg.Connect(ctx); err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = mg.Close(context.Background())
+	}()
+
+	if err := mg.Drop(ctx); err != nil {
+		return errors.Wrapf(err, "failed to drop database, %q", db)
+	}
+
+	return nil
+}
+
+func nodeDatabase(node *Node) (string, error) {
+	uri, err := node.StorageURI()
+	if err != nil {
+		return "", err
+	}
+
+	cs, err := config.CheckMongodbURI(uri)
+	if err != nil {
+		return "", err
+	}
+
+	return cs.Database, nil
+}
+
+func mongodbExec(ctx context.Context, node *Node, cmd []string) ([]byte, error) {
+	id := node.Host().MongodbContainerID(node.Alias())
+
+	stdout, stderr, exitCode, err := ContainerExec(ctx, node.Host().DockerClient(), id, cmd)
+	if err != nil {
+		return nil, err
+	} else if exitCode != 0 {
+		return nil, errors.Errorf("%q failed with exit code, %d: %s",
+			cmd[0], exitCode, strings.TrimSpace(string(stderr)))
+	}
+
+	return stdout, nil
+}
+
+func copyToMongodb(ctx context.Context, node *Node, source, dest string) error {
+	b, err := ioutil.ReadFile(filepath.Clean(source))
+	if err != nil {
+		return errors.Wrap(err, "failed to read database archive")
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{Name: filepath.Base(dest), Mode: 0o600, Size: int64(len(b))}); err != nil {
+		return err
+	} else if _, err := tw.Write(b); err != nil {
+		return err
+	} else if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return node.Host().DockerClient().CopyToContainer(
+		ctx,
+		node.Host().MongodbContainerID(node.Alias()),
+		filepath.Dir(dest),
+		&buf,
+		dockerTypes.CopyToContainerOptions{},
+	)
+}
+
+// cleanDir removes the contents of directory; the directory itself is kept,
+// because it may be the mount point of disk.
+func cleanDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return os.MkdirAll(dir, 0o700)
+		}
+
+		return errors.Wrapf(err, "failed to read directory, %q", dir)
+	}
+
+	for i := range files {
+		if err := os.RemoveAll(filepath.Join(dir, files[i].Name())); err != nil {
+			return errors.Wrapf(err, "failed to clean directory, %q", dir)
+		}
+	}
+
+	return nil
+}
+
+func archiveDir(dir, f string) error {
+	o, err := os.Create(filepath.Clean(f))
+	if err != nil {
+		return errors.Wrap(err, "failed to create archive")
+	}
+
+	defer func() {
+		_ = o.Close()
+	}()
+
+	gw := gzip.NewWriter(o)
+	tw := tar.NewWriter(gw)
+
+	if err := filepath.Walk(dir, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		if !fi.Mode().IsDir() && !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		hd, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		hd.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(hd); err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			return nil
+		}
+
+		r, err := os.Open(filepath.Clean(p))
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = r.Close()
+		}()
+
+		_, err = io.Copy(tw, r)
+
+		return err
+	}); err != nil {
+		return errors.Wrapf(err, "failed to archive directory, %q", dir)
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func extractDir(f, dir string) error {
+	i, err := os.Open(filepath.Clean(f))
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+
+	defer func() {
+		_ = i.Close()
+	}()
+
+	gr, err := gzip.NewReader(i)
+	if err != nil {
+		return errors.Wrap(err, "invalid archive")
+	}
+
+	tr := tar.NewReader(gr)
+
+	for {
+		hd, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return errors.Wrap(err, "failed to read archive")
+		}
+
+		p := filepath.Join(dir, filepath.Clean(hd.Name)) // nolint:gosec
+		if !strings.HasPrefix(p, filepath.Clean(dir)+string(os.PathSeparator)) {
+			return errors.Errorf("invalid path in archive, %q", hd.Name)
+		}
+
+		if hd.Typeflag == tar.TypeDir {
+			if err := os.MkdirAll(p, os.FileMode(hd.Mode)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := extractFile(tr, p, os.FileMode(hd.Mode)); err != nil {
+			return err
+		}
+	}
+}
+
+func extractFile(r io.Reader, p string, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return err
+	}
+
+	o, err := os.OpenFile(filepath.Clean(p), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = o.Close()
+	}()
+
+	_, err = io.Copy(o, r) // nolint:gosec
+
+	return err
+}
@@ -0,0 +1,379 @@
+package host
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dockerTypes "github.com/docker/docker/api/types"
+	dockerClient "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/mitum/util"
+	"github.com/spikeekips/mitum/util/logging"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/spikeekips/contest/config"
+)
+
+var colStats = "stats"
+
+// StatsSample is the resource usage of node container. The network and block
+// io are the accumulated bytes since the container started.
+type StatsSample struct {
+	Node        string    `json:"node"`
+	Container   string    `json:"container"`
+	T           time.Time `json:"t"`
+	CPU         float64   `json:"cpu"` // NOTE percent of one cpu
+	Memory      uint64    `json:"memory"`
+	MemoryLimit uint64    `json:"memory_limit"`
+	NetworkRx   uint64    `json:"network_rx"`
+	NetworkTx   uint64    `json:"network_tx"`
+	BlockRead   uint64    `json:"block_read"`
+	BlockWrite  uint64    `json:"block_write"`
+}
+
+// Value returns the value of resource metric.
+func (s StatsSample) Value(metric string) float64 {
+	switch metric {
+	case config.ResourceCPU:
+		return s.CPU
+	case config.ResourceMemory:
+		return float64(s.Memory)
+	case config.ResourceNetworkRx:
+		return float64(s.NetworkRx)
+	case config.ResourceNetworkTx:
+		return float64(s.NetworkTx)
+	case config.ResourceBlockRead:
+		return float64(s.BlockRead)
+	case config.ResourceBlockWrite:
+		return float64(s.BlockWrite)
+	default:
+		return 0
+	}
+}
+
+func (s StatsSample) record() bson.M {
+	return bson.M{
+		"_id":          config.ULID().String(),
+		"node":         s.Node,
+		"container":    s.Container,
+		"t":            s.T,
+		"cpu":          s.CPU,
+		"memory":       int64(s.Memory),
+		"memory_limit": int64(s.MemoryLimit),
+		"network_rx":   int64(s.NetworkRx),
+		"network_tx":   int64(s.NetworkTx),
+		"block_read":   int64(s.BlockRead),
+		"block_write":  int64(s.BlockWrite),
+	}
+}
+
+// StatsSummary is the peak and average resource usage of node.
+type StatsSummary struct {
+	Node       string  `json:"node"`
+	Samples    uint64  `json:"samples"`
+	CPUAvg     float64 `json:"cpu_avg"`
+	CPUPeak    float64 `json:"cpu_peak"`
+	MemoryAvg  uint64  `json:"memory_avg"`
+	MemoryPeak uint64  `json:"memory_peak"`
+	NetworkRx  uint64  `json:"network_rx"`
+	NetworkTx  uint64  `json:"network_tx"`
+	BlockRead  uint64  `json:"block_read"`
+	BlockWrite uint64  `json:"block_write"`
+}
+
+func (s StatsSummary) Map() map[string]interface{} {
+	var m map[string]interface{}
+	b, _ := json.Marshal(s)
+	_ = json.Unmarshal(b, &m)
+
+	return m
+}
+
+type statsSum struct {
+	samples    uint64
+	cpu        float64
+	cpuPeak    float64
+	memory     float64
+	memoryPeak uint64
+	last       StatsSample
+}
+
+// StatsSampler polls the stats of the running node containers by interval.
+// The samples are saved in the "stats" collection and the last samples are
+// kept for the resource condition.
+type StatsSampler struct {
+	sync.RWMutex
+	*logging.Logging
+	*util.ContextDaemon
+	hosts    *Hosts
+	mg       *Mongodb
+	interval time.Duration
+	latest   map[ /* node alias */ string]StatsSample
+	sums     map[ /* node alias */ string]*statsSum
+}
+
+func NewStatsSampler(hosts *Hosts, mg *Mongodb, interval time.Duration) *StatsSampler {
+	ss := &StatsSampler{
+		Logging: logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+			return c.Str("module", "stats-sampler")
+		}),
+		hosts:    hosts,
+		mg:       mg,
+		interval: interval,
+		latest:   map[string]StatsSample{},
+		sums:     map[string]*statsSum{},
+	}
+
+	ss.ContextDaemon = util.NewContextDaemon("stats-sampler", ss.start)
+
+	return ss
+}
+
+func (ss *StatsSampler) start(ctx context.Context) error {
+	ticker := time.NewTicker(ss.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := ss.sample(ctx); err != nil {
+				ss.Log().Error().Err(err).Msg("failed to sample stats")
+			}
+		}
+	}
+}
+
+func (ss *StatsSampler) Stop() error {
+	if err := ss.ContextDaemon.Stop(); err != nil && !errors.Is(err, util.DaemonAlreadyStoppedError) {
+		return err
+	}
+
+	return nil
+}
+
+// Latest returns the last sample of node.
+func (ss *StatsSampler) Latest(alias string) (StatsSample, bool) {
+	ss.RLock()
+	defer ss.RUnlock()
+
+	s, found := ss.latest[alias]
+
+	return s, found
+}
+
+// LatestAll returns the last samples of all the nodes.
+func (ss *StatsSampler) LatestAll() []StatsSample {
+	ss.RLock()
+	defer ss.RUnlock()
+
+	samples := make([]StatsSample, 0, len(ss.latest))
+	for alias := range ss.latest {
+		samples = append(samples, ss.latest[alias])
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Node < samples[j].Node })
+
+	return samples
+}
+
+// Summaries returns the summaries of nodes, sorted by node alias.
+func (ss *StatsSampler) Summaries() []StatsSummary {
+	ss.RLock()
+	defer ss.RUnlock()
+
+	summaries := make([]StatsSummary, 0, len(ss.sums))
+	for alias := range ss.sums {
+		sum := ss.sums[alias]
+
+		summaries = append(summaries, StatsSummary{
+			Node:       alias,
+			Samples:    sum.samples,
+			CPUAvg:     sum.cpu / float64(sum.samples),
+			CPUPeak:    sum.cpuPeak,
+			MemoryAvg:  uint64(sum.memory / float64(sum.samples)),
+			MemoryPeak: sum.memoryPeak,
+			NetworkRx:  sum.last.NetworkRx,
+			NetworkTx:  sum.last.NetworkTx,
+			BlockRead:  sum.last.BlockRead,
+			BlockWrite: sum.last.BlockWrite,
+		})
+	}
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Node < summaries[j].Node })
+
+	return summaries
+}
+
+type statsTarget struct {
+	alias  string
+	id     string
+	client *dockerClient.Client
+}
+
+func (ss *StatsSampler) sample(ctx context.Context) error {
+	var targets []statsTarget
+	if err := ss.hosts.TraverseHosts(func(h Host) (bool, error) {
+		return true, TraverseContainers(ctx, h.DockerClient(), h.TestName(), func(c dockerTypes.Container) (bool, error) {
+			if c.Labels[ContainerLabelNodeType] != ContainerLabelNodeRunType || c.State != "running" {
+				return true, nil
+			}
+
+			targets = append(targets, statsTarget{
+				alias:  c.Labels[ContainerLabelNodeAlias],
+				id:     c.ID,
+				client: h.DockerClient(),
+			})
+
+			return true, nil
+		})
+	}); err != nil {
+		return err
+	}
+
+	if len(targets) < 1 {
+		return nil
+	}
+
+	samples := make([]*StatsSample, len(targets))
+	_ = RunWaitGroup(len(targets), func(i int) error {
+		s, err := ss.fetch(ctx, targets[i])
+		if err != nil {
+			ss.Log().Error().Err(err).Str("node", targets[i].alias).Msg("failed to fetch stats")
+
+			return nil
+		}
+
+		samples[i] = &s
+
+		return nil
+	})
+
+	var records []interface{}
+
+	ss.Lock()
+	for i := range samples {
+		if samples[i] == nil {
+			continue
+		}
+
+		s := *samples[i]
+		ss.add(s)
+
+		records = append(records, s.record())
+	}
+	ss.Unlock()
+
+	if len(records) < 1 {
+		return nil
+	}
+
+	return ss.mg.AddRecords(ctx, colStats, records)
+}
+
+func (ss *StatsSampler) add(s StatsSample) {
+	ss.latest[s.Node] = s
+
+	sum, found := ss.sums[s.Node]
+	if !found {
+		sum = &statsSum{}
+		ss.sums[s.Node] = sum
+	}
+
+	sum.samples++
+	sum.cpu += s.CPU
+	sum.memory += float64(s.Memory)
+	sum.last = s
+
+	if s.CPU > sum.cpuPeak {
+		sum.cpuPeak = s.CPU
+	}
+
+	if s.Memory > sum.memoryPeak {
+		sum.memoryPeak = s.Memory
+	}
+}
+
+func (*StatsSampler) fetch(ctx context.Context, t statsTarget) (StatsSample, error) {
+	r, err := t.client.ContainerStats(ctx, t.id, false)
+	if err != nil {
+		return StatsSample{}, err
+	}
+
+	defer func() {
+		_ = r.Body.Close()
+	}()
+
+	var st dockerTypes.StatsJSON
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+		return StatsSample{}, errors.Wrap(err, "failed to decode stats")
+	}
+
+	s := StatsSample{
+		Node:        t.alias,
+		Container:   t.id,
+		T:           st.Read.UTC(),
+		CPU:         statsCPUPercent(st),
+		Memory:      statsMemory(st),
+		MemoryLimit: st.MemoryStats.Limit,
+	}
+
+	for k := range st.Networks {
+		s.NetworkRx += st.Networks[k].RxBytes
+		s.NetworkTx += st.Networks[k].TxBytes
+	}
+
+	for _, e := range st.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(e.Op) {
+		case "read":
+			s.BlockRead += e.Value
+		case "write":
+			s.BlockWrite += e.Value
+		}
+	}
+
+	return s, nil
+}
+
+func statsCPUPercent(st dockerTypes.StatsJSON) float64 {
+	cpu := float64(st.CPUStats.CPUUsage.TotalUsage) - float64(st.PreCPUStats.CPUUsage.TotalUsage)
+	system := float64(st.CPUStats.SystemUsage) - float64(st.PreCPUStats.SystemUsage)
+
+	if cpu <= 0 || system <= 0 {
+		return 0
+	}
+
+	online := float64(st.CPUStats.OnlineCPUs)
+	if online < 1 {
+		online = float64(len(st.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	return cpu / system * online * 100
+}
+
+// statsMemory returns the memory usage without the page cache, like "docker
+// stats".
+func statsMemory(st dockerTypes.StatsJSON) uint64 {
+	usage := st.MemoryStats.Usage
+
+	var cache uint64
+	for _, k := range []string{"total_inactive_file", "inactive_file"} {
+		if i, found := st.MemoryStats.Stats[k]; found {
+			cache = i
+
+			break
+		}
+	}
+
+	if cache > usage {
+		return 0
+	}
+
+	return usage - cache
+}
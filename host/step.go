@@ -0,0 +1,224 @@
+package host
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spikeekips/contest/config"
+)
+
+// Step is the item of sequences tree; *Sequence is the leaf and the others
+// are control blocks which contain nested steps.
+type Step interface {
+	StepType() string
+}
+
+func (*Sequence) StepType() string {
+	return "sequence"
+}
+
+type RepeatStep struct {
+	count uint
+	v     string
+	steps []Step
+}
+
+func NewRepeatStep(count uint, v string, steps []Step) *RepeatStep {
+	return &RepeatStep{count: count, v: v, steps: steps}
+}
+
+func (*RepeatStep) StepType() string {
+	return "repeat"
+}
+
+type UntilStep struct {
+	condition *Condition
+	max       uint
+	v         string
+	steps     []Step
+}
+
+func NewUntilStep(condition *Condition, max uint, v string, steps []Step) *UntilStep {
+	return &UntilStep{condition: condition, max: max, v: v, steps: steps}
+}
+
+func (*UntilStep) StepType() string {
+	return "until"
+}
+
+type IfStep struct {
+	expr string
+	then []Step
+	els  []Step
+}
+
+func NewIfStep(expr string, then, els []Step) *IfStep {
+	return &IfStep{expr: expr, then: then, els: els}
+}
+
+func (*IfStep) StepType() string {
+	return "if"
+}
+
+func (st *IfStep) evaluate(vars *config.Vars) (bool, error) {
+	b, err := config.CompileTemplate(st.expr, vars)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to compile if expression, %q", st.expr)
+	}
+
+	s := strings.TrimSpace(string(b))
+	if len(s) < 1 {
+		return false, nil
+	}
+
+	i, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, errors.Wrapf(err, "if expression, %q should be boolean, not %q", st.expr, s)
+	}
+
+	return i, nil
+}
+
+type stepFrame struct {
+	step      Step // NOTE nil for root
+	steps     []Step
+	index     int
+	iteration uint
+	started   time.Time // NOTE start time of current iteration of loop
+}
+
+// StepWalker walks the tree of steps and returns the sequences in order.
+type StepWalker struct {
+	frames  []*stepFrame
+	current *Sequence
+}
+
+func NewStepWalker(steps []Step) *StepWalker {
+	return &StepWalker{frames: []*stepFrame{{steps: steps}}}
+}
+
+// Current returns the current sequence, which waits to be matched.
+func (sw *StepWalker) Current() (*Sequence, bool) {
+	return sw.current, sw.current != nil
+}
+
+// Next moves to the next sequence. The control blocks on the way are
+// evaluated with the given vars. If no more sequences, it returns false.
+func (sw *StepWalker) Next(
+	ctx context.Context, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) (*Sequence, bool, error) {
+	sw.current = nil
+
+	for len(sw.frames) > 0 {
+		f := sw.frames[len(sw.frames)-1]
+
+		if f.index >= len(f.steps) {
+			if err := sw.endFrame(ctx, f, vars, getStorage); err != nil {
+				return nil, false, err
+			}
+
+			continue
+		}
+
+		st := f.steps[f.index]
+		f.index++
+
+		switch t := st.(type) {
+		case *Sequence:
+			t.Condition().Reset(sw.anchor())
+			sw.current = t
+
+			return t, true, nil
+		case *RepeatStep:
+			sw.push(t, t.steps, t.v, vars)
+		case *UntilStep:
+			sw.push(t, t.steps, t.v, vars)
+		case *IfStep:
+			ok, err := t.evaluate(vars)
+			if err != nil {
+				return nil, false, err
+			}
+
+			if ok {
+				sw.push(t, t.then, "", vars)
+			} else {
+				sw.push(t, t.els, "", vars)
+			}
+		default:
+			return nil, false, errors.Errorf("unknown step, %T", st)
+		}
+	}
+
+	return nil, false, nil
+}
+
+func (sw *StepWalker) push(st Step, steps []Step, v string, vars *config.Vars) {
+	sw.frames = append(sw.frames, &stepFrame{step: st, steps: steps, started: time.Now()})
+
+	if len(v) > 0 {
+		vars.Set(v, uint(0))
+	}
+}
+
+// anchor returns the start time of the current iteration of the innermost
+// loop; outside of loop, it is zero.
+func (sw *StepWalker) anchor() time.Time {
+	for i := len(sw.frames) - 1; i >= 0; i-- {
+		switch sw.frames[i].step.(type) {
+		case *RepeatStep, *UntilStep:
+			return sw.frames[i].started
+		}
+	}
+
+	return time.Time{}
+}
+
+func (sw *StepWalker) pop() {
+	sw.frames = sw.frames[:len(sw.frames)-1]
+}
+
+func (sw *StepWalker) endFrame(
+	ctx context.Context, f *stepFrame, vars *config.Vars, getStorage func(string) (*Mongodb, error),
+) error {
+	switch t := f.step.(type) {
+	case *RepeatStep:
+		f.iteration++
+		if f.iteration >= t.count {
+			sw.pop()
+
+			return nil
+		}
+
+		f.index = 0
+		f.started = time.Now()
+		vars.Set(t.v, f.iteration)
+	case *UntilStep:
+		t.condition.Reset(f.started)
+
+		_, matched, err := t.condition.Check(ctx, vars, getStorage)
+		switch {
+		case err != nil:
+			return err
+		case matched:
+			sw.pop()
+
+			return nil
+		}
+
+		f.iteration++
+		if t.max > 0 && f.iteration >= t.max {
+			return errors.Errorf("until condition, %q not matched after %d iterations", t.condition.QueryString(), t.max)
+		}
+
+		f.index = 0
+		f.started = time.Now()
+		vars.Set(t.v, f.iteration)
+	default:
+		sw.pop()
+	}
+
+	return nil
+}
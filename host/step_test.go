@@ -0,0 +1,300 @@
+package host
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/spikeekips/contest/config"
+	"github.com/spikeekips/mitum/util/logging"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type testStepWalker struct {
+	suite.Suite
+}
+
+func (t *testStepWalker) condition(q string) *Condition {
+	return t.conditionWithCol(q, colLogEntry)
+}
+
+func (t *testStepWalker) conditionWithCol(q, col string) *Condition {
+	log := logging.NewLogging(func(c zerolog.Context) zerolog.Context {
+		return c.Str("module", "condition")
+	})
+
+	return &Condition{
+		Logging: log,
+		checker: &queryCondition{Logging: log, conditionStorage: conditionStorage{col: col}, queryString: q},
+	}
+}
+
+func (t *testStepWalker) sequence(name string) *Sequence {
+	return &Sequence{condition: t.condition(fmt.Sprintf(`{"name": %q}`, name))}
+}
+
+// walk walks all the steps and returns the names of the reached sequences
+// with the values of keys in vars, like "a:0,1".
+func (t *testStepWalker) walk(
+	steps []Step, vars *config.Vars, keys []string, reached func(string),
+) ([]string, error) {
+	getStorage := func(string) (*Mongodb, error) {
+		return nil, errors.Errorf("storage not allowed")
+	}
+
+	sw := NewStepWalker(steps)
+
+	var walked []string
+	for {
+		sq, found, err := sw.Next(context.Background(), vars, getStorage)
+		switch {
+		case err != nil:
+			return walked, err
+		case !found:
+			return walked, nil
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(sq.Condition().QueryString(), `{"name": "`), `"}`)
+		if reached != nil {
+			reached(name)
+		}
+
+		if len(keys) > 0 {
+			values := make([]string, len(keys))
+			for i := range keys {
+				v, _ := vars.Value(keys[i])
+				values[i] = fmt.Sprintf("%v", v)
+			}
+
+			name = fmt.Sprintf("%s:%s", name, strings.Join(values, ","))
+		}
+
+		walked = append(walked, name)
+	}
+}
+
+func (t *testStepWalker) TestWalk() {
+	cases := []struct {
+		name     string
+		steps    func() []Step
+		keys     []string
+		expected []string
+		err      string
+	}{
+		{
+			name: "sequences",
+			steps: func() []Step {
+				return []Step{t.sequence("a"), t.sequence("b")}
+			},
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "repeat",
+			steps: func() []Step {
+				return []Step{
+					t.sequence("a"),
+					NewRepeatStep(3, "I", []Step{t.sequence("b"), t.sequence("c")}),
+					t.sequence("d"),
+				}
+			},
+			keys:     []string{"I"},
+			expected: []string{"a:<nil>", "b:0", "c:0", "b:1", "c:1", "b:2", "c:2", "d:2"},
+		},
+		{
+			name: "nested repeat",
+			steps: func() []Step {
+				return []Step{
+					NewRepeatStep(2, "I", []Step{
+						NewRepeatStep(2, "J", []Step{t.sequence("a")}),
+						t.sequence("b"),
+					}),
+				}
+			},
+			keys:     []string{"I", "J"},
+			expected: []string{"a:0,0", "a:0,1", "b:0,1", "a:1,0", "a:1,1", "b:1,1"},
+		},
+		{
+			name: "if then",
+			steps: func() []Step {
+				return []Step{NewIfStep("true", []Step{t.sequence("a")}, []Step{t.sequence("b")})}
+			},
+			expected: []string{"a"},
+		},
+		{
+			name: "if else",
+			steps: func() []Step {
+				return []Step{NewIfStep("false", []Step{t.sequence("a")}, []Step{t.sequence("b")})}
+			},
+			expected: []string{"b"},
+		},
+		{
+			name: "if empty expression",
+			steps: func() []Step {
+				return []Step{NewIfStep(`{{ if false }}true{{ end }}`, []Step{t.sequence("a")}, nil)}
+			},
+			expected: nil,
+		},
+		{
+			name: "if in repeat",
+			steps: func() []Step {
+				return []Step{
+					NewRepeatStep(3, "I", []Step{
+						NewIfStep(`{{ eq .I 1 }}`, []Step{t.sequence("a")}, []Step{t.sequence("b")}),
+					}),
+				}
+			},
+			keys:     []string{"I"},
+			expected: []string{"b:0", "a:1", "b:2"},
+		},
+		{
+			name: "not boolean if expression",
+			steps: func() []Step {
+				return []Step{NewIfStep("showme", []Step{t.sequence("a")}, nil)}
+			},
+			err: "should be boolean",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			walked, err := t.walk(c.steps(), config.NewVars(nil), c.keys, nil)
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.expected, walked)
+		})
+	}
+}
+
+func (t *testStepWalker) untilStep(states *NodeStates, max uint, steps []Step) *UntilStep {
+	co := t.condition("")
+	co.checker = &nodeStateCondition{expected: map[string]string{"no0": config.NodeStateRunning}, states: states}
+
+	return NewUntilStep(co, max, "I", steps)
+}
+
+func (t *testStepWalker) TestUntil() {
+	cases := []struct {
+		name     string
+		max      uint
+		at       uint // NOTE the iteration, in which node starts
+		expected []string
+		err      string
+	}{
+		{name: "first iteration", at: 0, expected: []string{"a:0"}},
+		{name: "third iteration", at: 2, expected: []string{"a:0", "a:1", "a:2"}},
+		{name: "within max", max: 3, at: 2, expected: []string{"a:0", "a:1", "a:2"}},
+		{name: "over max", max: 2, at: 2, err: "not matched after 2 iterations"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func() {
+			vars := config.NewVars(nil)
+			states := NewNodeStates(nil, vars)
+			states.Add("no0")
+
+			steps := []Step{t.untilStep(states, c.max, []Step{t.sequence("a")})}
+
+			walked, err := t.walk(steps, vars, []string{"I"}, func(string) {
+				if i, _ := vars.Value("I"); i == c.at {
+					_ = states.Set("no0", config.NodeStateRunning, "")
+				}
+			})
+			if len(c.err) > 0 {
+				t.Error(err)
+				t.Contains(err.Error(), c.err)
+
+				return
+			}
+
+			t.NoError(err)
+			t.Equal(c.expected, walked)
+		})
+	}
+}
+
+func (t *testStepWalker) TestResetChildSequence() {
+	vars := config.NewVars(nil)
+
+	a := t.sequence("a")
+	b := t.sequence("b")
+
+	sw := NewStepWalker([]Step{a, NewRepeatStep(2, "I", []Step{b})})
+
+	var anchors []time.Time
+	for {
+		sq, found, err := sw.Next(context.Background(), vars, nil)
+		t.NoError(err)
+
+		if !found {
+			break
+		}
+
+		q, err := sq.Condition().Query(vars)
+		t.NoError(err)
+
+		if sq == a {
+			t.True(sq.Condition().checker.(*queryCondition).anchor.IsZero())
+			t.Equal(bson.M{"name": "a"}, q)
+
+			continue
+		}
+
+		anchor := sq.Condition().checker.(*queryCondition).anchor
+		t.False(anchor.IsZero())
+		t.Equal(bson.M{"$and": bson.A{
+			bson.M{"name": "b"},
+			bson.M{"_id": bson.M{"$gt": config.MaxULIDAt(anchor).String()}},
+		}}, q)
+
+		anchors = append(anchors, anchor)
+	}
+
+	t.Equal(2, len(anchors))
+	t.True(anchors[1].After(anchors[0]))
+}
+
+func (t *testStepWalker) TestNotAnchorOtherCollection() {
+	vars := config.NewVars(nil)
+
+	// NOTE the records of node storage have ObjectID _id
+	b := &Sequence{condition: t.conditionWithCol(`{"height": {"$gt": 3}}`, "block")}
+
+	sw := NewStepWalker([]Step{NewRepeatStep(2, "I", []Step{b})})
+
+	var n int
+	for {
+		sq, found, err := sw.Next(context.Background(), vars, nil)
+		t.NoError(err)
+
+		if !found {
+			break
+		}
+
+		t.False(sq.Condition().checker.(*queryCondition).anchor.IsZero())
+
+		q, err := sq.Condition().Query(vars)
+		t.NoError(err)
+		t.Equal(bson.M{"height": bson.M{"$gt": int32(3)}}, q)
+
+		n++
+	}
+
+	t.Equal(2, n)
+}
+
+func TestStepWalker(t *testing.T) {
+	suite.Run(t, new(testStepWalker))
+}
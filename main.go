@@ -27,7 +27,8 @@ var (
 )
 
 type mainflags struct {
-	RunContest cmds.RunCommand `cmd:"" name:"run" help:"run contest"`
+	RunContest cmds.RunCommand   `cmd:"" name:"run" help:"run contest"`
+	Clean      cmds.CleanCommand `cmd:"" name:"clean" help:"clean containers of contest"`
 }
 
 func main() {
@@ -40,6 +41,14 @@ func main() {
 	}
 	flags.RunContest = i
 
+	j, err := cmds.NewCleanCommand()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %+v\n", err)
+
+		os.Exit(1)
+	}
+	flags.Clean = j
+
 	ctx := kong.Parse(&flags, options...)
 
 	version := util.Version(Version)